@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/app"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/importer"
+	"os"
+	"strconv"
+)
+
+// runImport implements the "import" subcommand, which loads a customer file
+// synchronously instead of queueing it for the worker tier:
+//
+//	app import -file customers.csv [-format csv] [-mapping '{"phone":"msisdn"}'] [-report rejected.csv]
+func runImport(ctx context.Context, args []string, svc *app.Service, imp *importer.Importer, repo *repository.Repository) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	path := fs.String("file", "", "path to the CSV or NDJSON file to import")
+	format := fs.String("format", "", "file format, csv or ndjson (defaults to the file extension)")
+	mapping := fs.String("mapping", "", "JSON object mapping customer fields to source columns")
+	report := fs.String("report", "", "optional path to write rejected rows to as CSV")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	payload := domain.CreateCustomerImport{Format: *format}
+	if payload.Format == "" {
+		payload.Format = importer.FormatFromFilename(*path)
+	}
+
+	if *mapping != "" {
+		if err := json.Unmarshal([]byte(*mapping), &payload.Mapping); err != nil {
+			return fmt.Errorf("invalid -mapping: %w", err)
+		}
+	}
+
+	record, err := svc.StageCustomerImport(ctx, &payload, file)
+	if err != nil {
+		return err
+	}
+
+	record, err = imp.Run(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"import %d %s: %d processed, %d imported, %d rejected\n",
+		record.ID, record.Status, record.ProcessedRows, record.ImportedRows, record.RejectedRows,
+	)
+
+	if *report == "" || record.RejectedRows == 0 {
+		return nil
+	}
+
+	rejections, err := repo.ListCustomerImportRejections(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(*report)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{"row_number", "reason", "raw"})
+	for _, rej := range rejections {
+		_ = w.Write([]string{strconv.Itoa(int(rej.RowNumber)), rej.Reason, rej.Raw})
+	}
+	w.Flush()
+
+	return w.Error()
+}
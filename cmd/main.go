@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"focus-dev-challenge/internal/adapters/api"
+	"focus-dev-challenge/internal/adapters/relay"
 	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/adapters/scheduler"
+	"focus-dev-challenge/internal/adapters/sender"
 	"focus-dev-challenge/internal/adapters/worker"
 	"focus-dev-challenge/internal/config"
 	"focus-dev-challenge/internal/core/app"
+	"focus-dev-challenge/internal/core/importer"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 
@@ -39,17 +44,30 @@ func main() {
 		logger.Fatal("could not initialize data repository", zap.Error(err))
 	}
 
-	svc := app.NewService(cfg, repo, val)
+	svc := app.NewService(cfg, repo, val, logger)
+	imp := importer.NewImporter(repo, val)
 	ch := make(chan error, 1)
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		err := runImport(ctx, os.Args[2:], svc, imp, repo)
+		_ = repo.Close()
+		if err != nil {
+			logger.Fatal("customer import failed", zap.Error(err))
+		}
+
+		return
+	}
+
 	var srv *http.Server
 	var tasker *worker.TaskProcessor
+	var relayer *relay.Relay
+	var campaignScheduler *scheduler.Scheduler
 
 	switch cfg.AppTier {
 	case "web":
-		router := api.NewRouter(svc, logger, cfg.Debug)
+		router := api.NewRouter(cfg, svc, repo, logger)
 		srv = &http.Server{
 			Addr:    fmt.Sprintf(":%d", cfg.ServerPort),
 			Handler: router.Engine,
@@ -68,7 +86,12 @@ func main() {
 			}
 		}()
 	case "worker":
-		tasker = worker.NewTaskProcessor(cfg, repo, logger)
+		dispatcher, err := sender.NewSender(cfg)
+		if err != nil {
+			logger.Fatal("could not initialize message sender", zap.Error(err))
+		}
+
+		tasker = worker.NewTaskProcessor(cfg, repo, dispatcher, imp, svc, logger)
 
 		go func() {
 			logger.Info("Starting background task processor")
@@ -78,6 +101,28 @@ func main() {
 				ch <- err
 			}
 		}()
+	case "relay":
+		relayer = relay.NewRelay(cfg, repo, logger)
+
+		go func() {
+			logger.Info("Starting outbox relay")
+
+			if err := relayer.Start(); err != nil {
+				logger.Error("could not start outbox relay", zap.Error(err))
+				ch <- err
+			}
+		}()
+	case "scheduler":
+		campaignScheduler = scheduler.NewScheduler(cfg, svc, logger)
+
+		go func() {
+			logger.Info("Starting campaign scheduler")
+
+			if err := campaignScheduler.Start(); err != nil {
+				logger.Error("could not start campaign scheduler", zap.Error(err))
+				ch <- err
+			}
+		}()
 	default:
 		logger.Fatal("unsupported app tier", zap.String("tier", cfg.AppTier))
 	}
@@ -86,14 +131,21 @@ func main() {
 	case <-ctx.Done():
 		logger.Info("initiating graceful shutdown")
 
-		if cfg.AppTier == "web" {
+		switch cfg.AppTier {
+		case "web":
 			if err := srv.Shutdown(context.Background()); err != nil {
 				logger.Error("failed graceful shutdown", zap.Error(err))
 			}
-		} else {
+		case "worker":
 			tasker.Stop()
+		case "relay":
+			relayer.Stop()
+		case "scheduler":
+			campaignScheduler.Stop()
 		}
 
+		svc.Stop()
+
 		if err := repo.Close(); err != nil {
 			logger.Error("failed to close repository", zap.Error(err))
 		}
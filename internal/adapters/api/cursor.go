@@ -0,0 +1,56 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"strings"
+)
+
+var errInvalidCursor = stderrors.New("invalid cursor")
+
+// messageCursor is the position in a campaign's message listing.
+type messageCursor struct {
+	ID int64 `json:"id"`
+}
+
+// encodeCursor turns a listing position into the opaque token a client passes
+// back to get the next page: the JSON encoded position and its HMAC, both
+// base64 encoded and joined by a dot.
+func (r *Router) encodeCursor(position any) string {
+	payload, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(r.signCursor(payload))
+}
+
+// decodeCursor reads a token made by encodeCursor into position. Tokens that
+// were not signed with this server's secret are refused.
+func (r *Router) decodeCursor(cursor string, position any) error {
+	encodedPayload, encodedSignature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return errInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return errInvalidCursor
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, r.signCursor(payload)) {
+		return errInvalidCursor
+	}
+
+	if err := json.Unmarshal(payload, position); err != nil {
+		return errInvalidCursor
+	}
+
+	return nil
+}
+
+func (r *Router) signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, r.cursorSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"focus-dev-challenge/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	r := &Router{cursorSecret: []byte("secret")}
+	in := domain.CampaignCursor{CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC), ID: 42, Backward: true}
+
+	var out domain.CampaignCursor
+	if !assert.NoError(t, r.decodeCursor(r.encodeCursor(in), &out)) {
+		t.FailNow()
+	}
+	assert.True(t, in.CreatedAt.Equal(out.CreatedAt))
+	assert.Equal(t, in.ID, out.ID)
+	assert.True(t, out.Backward)
+}
+
+func TestCursor_Refused(t *testing.T) {
+	r := &Router{cursorSecret: []byte("secret")}
+	other := &Router{cursorSecret: []byte("other")}
+	valid := r.encodeCursor(messageCursor{ID: 7})
+	forged := other.encodeCursor(messageCursor{ID: 7})
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "other secret", cursor: forged},
+		{name: "no signature", cursor: valid[:len(valid)-44]},
+		{name: "tampered payload", cursor: "x" + valid},
+		{name: "not base64", cursor: "!!!.???"},
+		{name: "unsigned id", cursor: "Nw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out messageCursor
+			assert.ErrorIs(t, r.decodeCursor(tt.cursor, &out), errInvalidCursor)
+		})
+	}
+}
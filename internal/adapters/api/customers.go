@@ -0,0 +1,167 @@
+package api
+
+import (
+	"focus-dev-challenge/internal/core/domain"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+func (r *Router) GetCustomers(c *gin.Context) {
+	pageNumber := 1
+	pageSize := 10
+
+	if v, err := strconv.Atoi(c.Query("page_number")); err == nil {
+		if v > 0 {
+			pageNumber = v
+		}
+	}
+
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil {
+		if v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	// An unescaped "+" in the query string decodes to a space.
+	phone := c.Query("phone")
+	if strings.HasPrefix(phone, " ") {
+		phone = "+" + strings.TrimSpace(phone)
+	}
+
+	filter := domain.CustomersFilter{
+		Location:         c.Query("location"),
+		PreferredProduct: c.Query("preferred_product"),
+		Phone:            phone,
+	}
+
+	records, err := r.service.ListCustomers(c.Request.Context(), pageNumber, pageSize, &filter)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	totalCount := int64(0)
+	if len(records) > 0 {
+		totalCount = records[0].TotalCount
+	}
+
+	totalPages := int(math.Ceil(float64(totalCount) / float64(pageSize)))
+	res := gin.H{
+		"data": records,
+		"pagination": gin.H{
+			"page":        pageNumber,
+			"page_size":   pageSize,
+			"total_count": totalCount,
+			"total_pages": totalPages,
+		},
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (r *Router) CreateCustomer(c *gin.Context) {
+	var data domain.CreateCustomer
+	if err := c.ShouldBind(&data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	customer, err := r.service.AddCustomer(c.Request.Context(), &data)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, customer)
+}
+
+func (r *Router) GetCustomer(c *gin.Context) {
+	ID := c.Param("id")
+	customerID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	customer, err := r.service.RetrieveCustomer(c.Request.Context(), int64(customerID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, customer)
+}
+
+func (r *Router) UpdateCustomer(c *gin.Context) {
+	ID := c.Param("id")
+	customerID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var data domain.UpdateCustomer
+	if err := c.ShouldBind(&data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	customer, err := r.service.UpdateCustomer(c.Request.Context(), int64(customerID), &data)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, customer)
+}
+
+func (r *Router) DeleteCustomer(c *gin.Context) {
+	ID := c.Param("id")
+	customerID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := r.service.DeleteCustomer(c.Request.Context(), int64(customerID)); err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"focus-dev-challenge/internal/adapters/repository"
 	"focus-dev-challenge/internal/core/domain"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mwinyimoha/commons/pkg/errors"
 )
 
@@ -30,7 +35,14 @@ func (r *Router) GetCampaigns(c *gin.Context) {
 		Channel: c.Query("channel"),
 	}
 
-	records, err := r.service.ListCampaigns(pageNumber, pageSize, &filter)
+	// A cursor parameter, even an empty one for the first page, switches to
+	// keyset pagination.
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		r.getCampaignsByCursor(c, cursor, pageSize, &filter)
+		return
+	}
+
+	records, err := r.service.ListCampaigns(c.Request.Context(), pageNumber, pageSize, &filter)
 	if err != nil {
 		if cerr, ok := err.(*errors.Error); ok {
 			code, detail := cerr.HTTPStatus()
@@ -61,6 +73,52 @@ func (r *Router) GetCampaigns(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+func (r *Router) getCampaignsByCursor(c *gin.Context, cursor string, pageSize int, filter *domain.CampaignsFilter) {
+	var position *domain.CampaignCursor
+	if cursor != "" {
+		position = &domain.CampaignCursor{}
+		if err := r.decodeCursor(cursor, position); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	withTotal, _ := strconv.ParseBool(c.Query("include_total"))
+
+	records, cursors, err := r.service.ListCampaignsByCursor(c.Request.Context(), pageSize, filter, position, withTotal)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	if records == nil {
+		records = []*repository.Campaign{}
+	}
+
+	pagination := gin.H{
+		"page_size":   pageSize,
+		"next_cursor": nil,
+		"prev_cursor": nil,
+	}
+	if cursors.Next != nil {
+		pagination["next_cursor"] = r.encodeCursor(cursors.Next)
+	}
+	if cursors.Prev != nil {
+		pagination["prev_cursor"] = r.encodeCursor(cursors.Prev)
+	}
+	if cursors.TotalCount != nil {
+		pagination["total_count"] = *cursors.TotalCount
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": records, "pagination": pagination})
+}
+
 func (r *Router) CreateCampaign(c *gin.Context) {
 	var data domain.CreateCampaign
 	if err := c.ShouldBind(&data); err != nil {
@@ -68,7 +126,7 @@ func (r *Router) CreateCampaign(c *gin.Context) {
 		return
 	}
 
-	campaign, err := r.service.AddCampaign(&data)
+	campaign, warnings, err := r.service.AddCampaign(c.Request.Context(), &data)
 	if err != nil {
 		if cerr, ok := err.(*errors.Error); ok {
 			code, detail := cerr.HTTPStatus()
@@ -80,7 +138,10 @@ func (r *Router) CreateCampaign(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, campaign)
+	c.JSON(http.StatusOK, struct {
+		*repository.Campaign
+		Warnings []*domain.TemplateWarning `json:"warnings,omitempty"`
+	}{campaign, warnings})
 }
 
 func (r *Router) GetCampaign(c *gin.Context) {
@@ -91,7 +152,7 @@ func (r *Router) GetCampaign(c *gin.Context) {
 		return
 	}
 
-	campaign, err := r.service.RetrieveCampaign(int64(campaignID))
+	campaign, err := r.service.RetrieveCampaign(c.Request.Context(), int64(campaignID))
 	if err != nil {
 		if cerr, ok := err.(*errors.Error); ok {
 			code, detail := cerr.HTTPStatus()
@@ -110,18 +171,263 @@ func (r *Router) GetCampaign(c *gin.Context) {
 	}
 
 	result := gin.H{
-		"id":            campaign.ID,
-		"name":          campaign.Name,
-		"channel":       campaign.Channel,
-		"status":        campaign.Status,
-		"base_template": campaign.BaseTemplate,
-		"scheduled_at":  campaign.ScheduledAt,
-		"created_at":    campaign.CreatedAt,
-		"stats":         stats,
+		"id":                  campaign.ID,
+		"name":                campaign.Name,
+		"channel":             campaign.Channel,
+		"status":              campaign.Status,
+		"base_template":       campaign.BaseTemplate,
+		"scheduled_at":        campaign.ScheduledAt,
+		"segment_id":          campaign.SegmentID,
+		"customer_ids":        campaign.CustomerIds,
+		"recurrence":          campaign.Recurrence,
+		"timezone":            campaign.Timezone,
+		"parent_id":           campaign.ParentID,
+		"created_at":          campaign.CreatedAt,
+		"updated_at":          campaign.UpdatedAt,
+		"paused_at":           campaign.PausedAt,
+		"cancelled_at":        campaign.CancelledAt,
+		"archived_at":         campaign.ArchivedAt,
+		"recurrence_ended_at": campaign.RecurrenceEndedAt,
+		"stats":               stats,
 	}
+	c.Header("ETag", campaignETag(campaign.UpdatedAt))
 	c.JSON(http.StatusOK, result)
 }
 
+// UpdateCampaign edits a draft or scheduled campaign. An If-Match header with
+// the ETag of GET /campaigns/:id makes the edit conditional on nobody having
+// changed the campaign since.
+func (r *Router) UpdateCampaign(c *gin.Context) {
+	ID := c.Param("id")
+	campaignID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c.GetHeader("If-Match"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+
+	var data domain.UpdateCampaign
+	if err := c.ShouldBind(&data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	campaign, warnings, err := r.service.UpdateCampaign(c.Request.Context(), int64(campaignID), &data, version)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.Header("ETag", campaignETag(campaign.UpdatedAt))
+	c.JSON(http.StatusOK, struct {
+		*repository.Campaign
+		Warnings []*domain.TemplateWarning `json:"warnings,omitempty"`
+	}{campaign, warnings})
+}
+
+func (r *Router) CloneCampaign(c *gin.Context) {
+	ID := c.Param("id")
+	campaignID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	campaign, err := r.service.CloneCampaign(c.Request.Context(), int64(campaignID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// ArchiveCampaign is what deleting a campaign does: its record and messages
+// are kept for reporting.
+func (r *Router) ArchiveCampaign(c *gin.Context) {
+	ID := c.Param("id")
+	campaignID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if _, err := r.service.ArchiveCampaign(c.Request.Context(), int64(campaignID)); err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (r *Router) CancelCampaign(c *gin.Context) {
+	r.changeCampaignStatus(c, r.service.CancelCampaign)
+}
+
+func (r *Router) PauseCampaign(c *gin.Context) {
+	r.changeCampaignStatus(c, r.service.PauseCampaign)
+}
+
+func (r *Router) ResumeCampaign(c *gin.Context) {
+	r.changeCampaignStatus(c, r.service.ResumeCampaign)
+}
+
+func (r *Router) SkipCampaignOccurrence(c *gin.Context) {
+	r.changeCampaignStatus(c, r.service.SkipCampaignOccurrence)
+}
+
+func (r *Router) EndCampaignRecurrence(c *gin.Context) {
+	r.changeCampaignStatus(c, r.service.EndCampaignRecurrence)
+}
+
+// GetCampaignRuns lists the runs a recurring campaign started, latest first,
+// with the stats of each.
+func (r *Router) GetCampaignRuns(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	pageNumber := 1
+	pageSize := 10
+
+	if v, err := strconv.Atoi(c.Query("page_number")); err == nil {
+		if v > 0 {
+			pageNumber = v
+		}
+	}
+
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil {
+		if v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	records, err := r.service.ListCampaignRuns(c.Request.Context(), int64(campaignID), pageNumber, pageSize)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	runs := make([]gin.H, 0, len(records))
+	for _, run := range records {
+		runs = append(runs, gin.H{
+			"id":           run.ID,
+			"name":         run.Name,
+			"channel":      run.Channel,
+			"status":       run.Status,
+			"scheduled_at": run.ScheduledAt,
+			"created_at":   run.CreatedAt,
+			"updated_at":   run.UpdatedAt,
+			"paused_at":    run.PausedAt,
+			"cancelled_at": run.CancelledAt,
+			"stats":        json.RawMessage(run.Stats),
+		})
+	}
+
+	totalCount := int64(0)
+	if len(records) > 0 {
+		totalCount = records[0].TotalCount
+	}
+
+	totalPages := int(math.Ceil(float64(totalCount) / float64(pageSize)))
+	res := gin.H{
+		"data": runs,
+		"pagination": gin.H{
+			"page":        pageNumber,
+			"page_size":   pageSize,
+			"total_count": totalCount,
+			"total_pages": totalPages,
+		},
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// changeCampaignStatus runs one of the service's status changes on the
+// campaign named in the path and answers with the updated campaign.
+func (r *Router) changeCampaignStatus(c *gin.Context, change func(context.Context, int64) (*repository.Campaign, error)) {
+	ID := c.Param("id")
+	campaignID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	campaign, err := change(c.Request.Context(), int64(campaignID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// campaignETag identifies a version of a campaign by the time it was last
+// updated.
+func campaignETag(updatedAt pgtype.Timestamp) string {
+	return strconv.Quote(strconv.FormatInt(updatedAt.Time.UnixMicro(), 10))
+}
+
+// ifMatchVersion reads the campaign version an If-Match header asks for. It
+// returns nil when the header is absent or matches any version, and false
+// when it is not an ETag of campaignETag's form.
+func ifMatchVersion(header string) (*time.Time, bool) {
+	header = strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, false
+	}
+
+	micros, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	version := time.UnixMicro(micros).UTC()
+	return &version, true
+}
+
 func (r *Router) SendCampaign(c *gin.Context) {
 	ID := c.Param("id")
 	campaignID, err := strconv.Atoi(ID)
@@ -136,7 +442,7 @@ func (r *Router) SendCampaign(c *gin.Context) {
 		return
 	}
 
-	result, err := r.service.SendCampaign(int64(campaignID), &data)
+	result, err := r.service.SendCampaign(c.Request.Context(), int64(campaignID), &data)
 	if err != nil {
 		if cerr, ok := err.(*errors.Error); ok {
 			code, detail := cerr.HTTPStatus()
@@ -148,9 +454,136 @@ func (r *Router) SendCampaign(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusAccepted, result)
+}
+
+func (r *Router) GetCampaignDispatch(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	dispatchID, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	result, err := r.service.RetrieveCampaignDispatch(c.Request.Context(), int64(campaignID), int64(dispatchID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	// A finished job that left customers out is only a partial success.
+	if result.Partial() {
+		c.JSON(http.StatusMultiStatus, result)
+		return
+	}
+
 	c.JSON(http.StatusOK, result)
 }
 
+func (r *Router) GetDispatchOutcomes(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	dispatchID, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	pageNumber := 1
+	pageSize := 10
+
+	if v, err := strconv.Atoi(c.Query("page_number")); err == nil {
+		if v > 0 {
+			pageNumber = v
+		}
+	}
+
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil {
+		if v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	var outcomes []string
+	if v := c.Query("outcome"); v != "" {
+		outcomes = strings.Split(v, ",")
+	}
+
+	records, err := r.service.ListDispatchOutcomes(c.Request.Context(), int64(campaignID), int64(dispatchID), pageNumber, pageSize, outcomes)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	totalCount := int64(0)
+	if len(records) > 0 {
+		totalCount = records[0].TotalCount
+	}
+
+	totalPages := int(math.Ceil(float64(totalCount) / float64(pageSize)))
+	res := gin.H{
+		"data": records,
+		"pagination": gin.H{
+			"page":        pageNumber,
+			"page_size":   pageSize,
+			"total_count": totalCount,
+			"total_pages": totalPages,
+		},
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (r *Router) EstimateCampaign(c *gin.Context) {
+	ID := c.Param("id")
+	campaignID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var data domain.SendCampaign
+	if err := c.ShouldBind(&data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	estimate, err := r.service.EstimateCampaign(c.Request.Context(), int64(campaignID), &data)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
 func (r *Router) Preview(c *gin.Context) {
 	ID := c.Param("id")
 	campaignID, err := strconv.Atoi(ID)
@@ -165,7 +598,7 @@ func (r *Router) Preview(c *gin.Context) {
 		return
 	}
 
-	result, err := r.service.PreviewMessage(int64(campaignID), &data)
+	result, err := r.service.PreviewMessage(c.Request.Context(), int64(campaignID), &data)
 	if err != nil {
 		if cerr, ok := err.(*errors.Error); ok {
 			code, detail := cerr.HTTPStatus()
@@ -179,3 +612,26 @@ func (r *Router) Preview(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+func (r *Router) RetryMessage(c *gin.Context) {
+	ID := c.Param("id")
+	messageID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	message, err := r.service.RetryMessage(c.Request.Context(), int64(messageID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, message)
+}
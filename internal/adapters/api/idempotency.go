@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"focus-dev-challenge/internal/adapters/repository"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+)
+
+// responseRecorder keeps a copy of everything a handler writes so that the
+// response can be stored for replay.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent makes a mutating route safe to retry. A request carrying an
+// Idempotency-Key header runs once per key, method and path; retries with the
+// same body get the stored response back, a different body under the same key
+// is refused with 409, and so is a retry that arrives while the first attempt
+// is still running. Server errors release the key so that a retry runs again.
+// Requests without the header are not affected.
+func (r *Router) idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
+
+	// The body is buffered to fingerprint it, so its size is bounded. The
+	// routes this wraps take small JSON documents.
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, r.idempotencyMaxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	scope := c.Request.Method + " " + c.Request.URL.Path
+	fingerprint := requestFingerprint(body)
+
+	record, err := r.idempotency.ClaimIdempotencyKey(c.Request.Context(), &repository.ClaimIdempotencyKeyParams{
+		Scope:              scope,
+		IdempotencyKey:     key,
+		Fingerprint:        fingerprint,
+		TtlSeconds:         r.idempotencyTTL,
+		LockTimeoutSeconds: r.idempotencyLockTimeout,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if record == nil {
+		r.replay(c, scope, key, fingerprint)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// The outcome is stored even when the client has gone away, since that is
+	// exactly when it will retry.
+	ctx := context.WithoutCancel(c.Request.Context())
+	logger := r.logger.With(zap.String("scope", scope), zap.String("idempotency_key", key))
+
+	if recorder.Status() >= http.StatusInternalServerError {
+		if err := r.idempotency.ReleaseIdempotencyKey(ctx, record.ID); err != nil {
+			logger.Error("could not release idempotency key", zap.Error(err))
+		}
+		return
+	}
+
+	err = r.idempotency.SaveIdempotentResponse(ctx, &repository.SaveIdempotentResponseParams{
+		StatusCode:   pgtype.Int4{Int32: int32(recorder.Status()), Valid: true},
+		ContentType:  pgtype.Text{String: recorder.Header().Get("Content-Type"), Valid: true},
+		ResponseBody: recorder.body.Bytes(),
+		ID:           record.ID,
+	})
+	if err != nil {
+		logger.Error("could not store idempotent response", zap.Error(err))
+	}
+}
+
+// replay answers a request whose key is already held by an earlier one.
+func (r *Router) replay(c *gin.Context, scope, key, fingerprint string) {
+	record, err := r.idempotency.GetIdempotencyKey(c.Request.Context(), &repository.GetIdempotencyKeyParams{
+		Scope:          scope,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if record != nil && record.Fingerprint != fingerprint {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"detail": "Idempotency-Key was already used with a different request body"})
+		return
+	}
+
+	// A key released between the claim and this lookup is free again, but
+	// only after the client retries.
+	if record == nil || !record.StatusCode.Valid {
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"detail": "A request with this Idempotency-Key is still in progress"})
+		return
+	}
+
+	c.Header(idempotentReplayHeader, "true")
+	c.Data(int(record.StatusCode.Int32), record.ContentType.String, record.ResponseBody)
+	c.Abort()
+}
+
+func requestFingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func abortWithError(c *gin.Context, err error) {
+	if cerr, ok := err.(*errors.Error); ok {
+		code, detail := cerr.HTTPStatus()
+		c.AbortWithStatusJSON(code, detail)
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeIdempotencyStore keeps keys in memory. Claims never take over a held
+// key, which is what the database does until a key expires or goes stale.
+type fakeIdempotencyStore struct {
+	records map[string]*repository.IdempotencyKey
+	nextID  int64
+}
+
+func (f *fakeIdempotencyStore) ClaimIdempotencyKey(_ context.Context, arg *repository.ClaimIdempotencyKeyParams) (*repository.IdempotencyKey, error) {
+	id := arg.Scope + "|" + arg.IdempotencyKey
+	if _, ok := f.records[id]; ok {
+		return nil, nil
+	}
+
+	f.nextID++
+	record := &repository.IdempotencyKey{ID: f.nextID, Scope: arg.Scope, IdempotencyKey: arg.IdempotencyKey, Fingerprint: arg.Fingerprint}
+	f.records[id] = record
+	return record, nil
+}
+
+func (f *fakeIdempotencyStore) GetIdempotencyKey(_ context.Context, arg *repository.GetIdempotencyKeyParams) (*repository.IdempotencyKey, error) {
+	return f.records[arg.Scope+"|"+arg.IdempotencyKey], nil
+}
+
+func (f *fakeIdempotencyStore) SaveIdempotentResponse(_ context.Context, arg *repository.SaveIdempotentResponseParams) error {
+	for _, record := range f.records {
+		if record.ID == arg.ID {
+			record.StatusCode = arg.StatusCode
+			record.ContentType = arg.ContentType
+			record.ResponseBody = append([]byte(nil), arg.ResponseBody...)
+		}
+	}
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, ID int64) error {
+	for id, record := range f.records {
+		if record.ID == ID && !record.StatusCode.Valid {
+			delete(f.records, id)
+		}
+	}
+	return nil
+}
+
+func newIdempotentEngine(store *fakeIdempotencyStore, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := &Router{idempotency: store, logger: zap.NewNop(), idempotencyTTL: 60, idempotencyLockTimeout: 60, idempotencyMaxBody: 64}
+
+	engine := gin.New()
+	engine.POST("/campaigns", r.idempotent, handler)
+	return engine
+}
+
+func post(engine *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/campaigns", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotent_ReplaysStoredResponse(t *testing.T) {
+	store := &fakeIdempotencyStore{records: map[string]*repository.IdempotencyKey{}}
+	calls := 0
+	engine := newIdempotentEngine(store, func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusCreated, gin.H{"id": calls, "body": string(body)})
+	})
+
+	first := post(engine, "abc", `{"name":"x"}`)
+	second := post(engine, "abc", `{"name":"x"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(idempotentReplayHeader))
+	assert.Contains(t, first.Body.String(), `{\"name\":\"x\"}`)
+}
+
+func TestIdempotent_RejectsDifferentBody(t *testing.T) {
+	store := &fakeIdempotencyStore{records: map[string]*repository.IdempotencyKey{}}
+	engine := newIdempotentEngine(store, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	post(engine, "abc", `{"name":"x"}`)
+	w := post(engine, "abc", `{"name":"y"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestIdempotent_RejectsRequestInProgress(t *testing.T) {
+	store := &fakeIdempotencyStore{records: map[string]*repository.IdempotencyKey{}}
+	var retry *httptest.ResponseRecorder
+	var engine *gin.Engine
+	engine = newIdempotentEngine(store, func(c *gin.Context) {
+		if retry == nil {
+			retry = post(engine, "abc", `{}`)
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	w := post(engine, "abc", `{}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusConflict, retry.Code)
+	assert.Equal(t, "1", retry.Header().Get("Retry-After"))
+}
+
+func TestIdempotent_ReleasesKeyOnServerError(t *testing.T) {
+	store := &fakeIdempotencyStore{records: map[string]*repository.IdempotencyKey{}}
+	calls := 0
+	engine := newIdempotentEngine(store, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	post(engine, "abc", `{}`)
+	w := post(engine, "abc", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestIdempotent_IgnoresRequestsWithoutKey(t *testing.T) {
+	store := &fakeIdempotencyStore{records: map[string]*repository.IdempotencyKey{}}
+	calls := 0
+	engine := newIdempotentEngine(store, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	post(engine, "", `{}`)
+	post(engine, "", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotent_RejectsLargeBody(t *testing.T) {
+	store := &fakeIdempotencyStore{records: map[string]*repository.IdempotencyKey{}}
+	engine := newIdempotentEngine(store, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	w := post(engine, "abc", `{"name":"`+strings.Repeat("x", 64)+`"}`)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Empty(t, store.records, "the key is not claimed")
+}
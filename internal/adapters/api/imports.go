@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/importer"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// ImportCustomers accepts a multipart upload with the file under "file". The
+// format defaults to the file extension and "mapping" may carry a JSON object
+// of customer field to source column. An upload retried with the
+// Idempotency-Key of an earlier one gets that import back.
+func (r *Router) ImportCustomers(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	payload := domain.CreateCustomerImport{
+		Format:         c.PostForm("format"),
+		IdempotencyKey: c.GetHeader(idempotencyKeyHeader),
+	}
+	if payload.Format == "" {
+		payload.Format = importer.FormatFromFilename(header.Filename)
+	}
+
+	if mapping := c.PostForm("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &payload.Mapping); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	// The file is streamed into storage; gin keeps large uploads on disk
+	// rather than in memory.
+	record, replayed, err := r.service.ImportCustomers(c.Request.Context(), &payload, file)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	if replayed {
+		c.Header(idempotentReplayHeader, "true")
+	}
+
+	c.JSON(http.StatusAccepted, record)
+}
+
+func (r *Router) GetCustomerImport(c *gin.Context) {
+	ID := c.Param("id")
+	importID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	record, err := r.service.RetrieveCustomerImport(c.Request.Context(), int64(importID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// GetCustomerImportReport downloads the rows rejected by an import as CSV.
+func (r *Router) GetCustomerImportReport(c *gin.Context) {
+	ID := c.Param("id")
+	importID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	records, err := r.service.CustomerImportReport(c.Request.Context(), int64(importID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=customer-import-%d-rejections.csv", importID))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"row_number", "reason", "raw"})
+	for _, rec := range records {
+		_ = w.Write([]string{strconv.Itoa(int(rec.RowNumber)), rec.Reason, rec.Raw})
+	}
+	w.Flush()
+}
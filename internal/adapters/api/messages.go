@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func (r *Router) GetMessage(c *gin.Context) {
+	ID := c.Param("id")
+	messageID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	message, err := r.service.RetrieveMessage(c.Request.Context(), int64(messageID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	result := gin.H{
+		"id":                  message.ID,
+		"status":              message.Status,
+		"rendered_content":    message.RenderedContent,
+		"encoding":            message.Encoding,
+		"segments":            message.Segments,
+		"last_error":          message.LastError,
+		"retry_count":         message.RetryCount,
+		"resend_reason":       message.ResendReason,
+		"provider_message_id": message.ProviderMessageID,
+		"created_at":          message.CreatedAt,
+		"updated_at":          message.UpdatedAt,
+		"campaign": gin.H{
+			"id":      message.CampaignID,
+			"name":    message.CampaignName,
+			"channel": message.Channel,
+		},
+		"customer": gin.H{
+			"id":         message.CustomerID,
+			"phone":      message.Phone,
+			"first_name": message.FirstName,
+			"last_name":  message.LastName,
+		},
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (r *Router) GetMessageEvents(c *gin.Context) {
+	ID := c.Param("id")
+	messageID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	events, err := r.service.ListMessageEvents(c.Request.Context(), int64(messageID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	data := make([]gin.H, 0, len(events))
+	for _, event := range events {
+		var detail any
+		if len(event.Detail) > 0 {
+			if err := json.Unmarshal(event.Detail, &detail); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+				return
+			}
+		}
+
+		data = append(data, gin.H{
+			"id":          event.ID,
+			"event_type":  event.EventType,
+			"status":      event.Status,
+			"detail":      detail,
+			"occurred_at": event.OccurredAt,
+			"created_at":  event.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// GetCampaignMessages lists the messages of a campaign one page at a time, or
+// downloads all of them as CSV with format=csv.
+func (r *Router) GetCampaignMessages(c *gin.Context) {
+	ID := c.Param("id")
+	campaignID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	filter := domain.MessagesFilter{
+		CreatedAfter:  c.Query("created_after"),
+		CreatedBefore: c.Query("created_before"),
+		Error:         c.Query("error"),
+	}
+
+	if v := c.Query("status"); v != "" {
+		filter.Statuses = strings.Split(v, ",")
+	}
+
+	if v := c.Query("customer_id"); v != "" {
+		customerID, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+			return
+		}
+		filter.CustomerID = int64(customerID)
+	}
+
+	if c.Query("format") == "csv" {
+		r.exportCampaignMessages(c, int64(campaignID), &filter)
+		return
+	}
+
+	pageSize := 10
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil {
+		if v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	var after messageCursor
+	if v := c.Query("cursor"); v != "" {
+		if err := r.decodeCursor(v, &after); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	// One extra row tells whether there is a next page.
+	records, err := r.service.ListCampaignMessages(c.Request.Context(), int64(campaignID), &filter, after.ID, pageSize+1)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	var nextCursor *string
+	if len(records) > pageSize {
+		records = records[:pageSize]
+		cursor := r.encodeCursor(messageCursor{ID: records[pageSize-1].ID})
+		nextCursor = &cursor
+	}
+
+	if records == nil {
+		records = []*repository.ListCampaignMessagesRow{}
+	}
+
+	res := gin.H{
+		"data": records,
+		"pagination": gin.H{
+			"page_size":   pageSize,
+			"next_cursor": nextCursor,
+		},
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (r *Router) exportCampaignMessages(c *gin.Context, campaignID int64, filter *domain.MessagesFilter) {
+	var w *csv.Writer
+	err := r.service.ExportCampaignMessages(c.Request.Context(), campaignID, filter, func(records []*repository.ListCampaignMessagesRow) error {
+		if w == nil {
+			c.Header("Content-Type", "text/csv")
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=campaign-%d-messages.csv", campaignID))
+			c.Status(http.StatusOK)
+
+			w = csv.NewWriter(c.Writer)
+			_ = w.Write([]string{
+				"message_id", "customer_id", "phone", "first_name", "last_name", "status",
+				"last_error", "retry_count", "provider_message_id", "created_at", "updated_at",
+			})
+		}
+
+		for _, rec := range records {
+			_ = w.Write([]string{
+				strconv.FormatInt(rec.ID, 10),
+				strconv.FormatInt(rec.CustomerID, 10),
+				rec.Phone,
+				csvText(rec.FirstName.String),
+				csvText(rec.LastName.String),
+				rec.Status,
+				csvText(rec.LastError.String),
+				strconv.Itoa(int(rec.RetryCount)),
+				csvText(rec.ProviderMessageID.String),
+				csvTimestamp(rec.CreatedAt),
+				csvTimestamp(rec.UpdatedAt),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err == nil {
+		return
+	}
+
+	// Once the first batch is out the status line is gone; the client sees a
+	// truncated file.
+	if w != nil {
+		r.logger.Error("campaign messages export failed", zap.Int64("campaign_id", campaignID), zap.Error(err))
+		return
+	}
+
+	if cerr, ok := err.(*errors.Error); ok {
+		code, detail := cerr.HTTPStatus()
+		c.JSON(code, detail)
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+}
+
+// csvText keeps a cell that spreadsheets would read as a formula as plain text
+// by prefixing it with a quote. Phones are E.164 and written as they are.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+func csvTimestamp(ts pgtype.Timestamp) string {
+	if !ts.Valid {
+		return ""
+	}
+
+	return ts.Time.Format(time.RFC3339)
+}
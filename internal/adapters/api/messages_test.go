@@ -0,0 +1,16 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVText(t *testing.T) {
+	for _, s := range []string{"=HYPERLINK(\"x\")", "+1+1", "-2+3", "@SUM(A1)", "\tcmd"} {
+		assert.Equal(t, "'"+s, csvText(s), s)
+	}
+
+	assert.Equal(t, "Jane", csvText("Jane"))
+	assert.Equal(t, "", csvText(""))
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"focus-dev-challenge/internal/config"
 	"focus-dev-challenge/internal/core/ports"
 	"time"
 
@@ -10,22 +11,50 @@ import (
 )
 
 type Router struct {
-	Engine  *gin.Engine
-	service ports.AppService
+	Engine      *gin.Engine
+	service     ports.AppService
+	idempotency ports.IdempotencyStore
+	logger      *zap.Logger
+
+	// webhookSecrets holds the receipt signing secret of every channel that
+	// has one configured.
+	webhookSecrets map[string]string
+
+	// cursorSecret signs pagination cursors.
+	cursorSecret []byte
+
+	idempotencyTTL         int32 // seconds
+	idempotencyLockTimeout int32 // seconds
+	idempotencyMaxBody     int64 // bytes
 }
 
-func NewRouter(svc ports.AppService, logger *zap.Logger, debug bool) *Router {
-	if !debug {
+func NewRouter(cfg *config.Config, svc ports.AppService, store ports.IdempotencyStore, logger *zap.Logger) *Router {
+	if !cfg.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	engine := gin.New()
+	// Customer import uploads beyond this are buffered on disk, not in memory.
+	engine.MaxMultipartMemory = 8 << 20
 	engine.Use(ginzap.Ginzap(logger, time.RFC3339, true))
 	engine.Use(ginzap.RecoveryWithZap(logger, true))
 
 	router := Router{
-		Engine:  engine,
-		service: svc,
+		Engine:                 engine,
+		service:                svc,
+		idempotency:            store,
+		logger:                 logger,
+		idempotencyTTL:         int32(cfg.IdempotencyTTL),
+		idempotencyLockTimeout: int32(cfg.IdempotencyLockTimeout),
+		idempotencyMaxBody:     int64(cfg.IdempotencyMaxBody),
+		webhookSecrets:         map[string]string{},
+		cursorSecret:           []byte(cfg.CursorSecret),
+	}
+	if cfg.SMSWebhookSecret != "" {
+		router.webhookSecrets["sms"] = cfg.SMSWebhookSecret
+	}
+	if cfg.WhatsAppWebhookSecret != "" {
+		router.webhookSecrets["whatsapp"] = cfg.WhatsAppWebhookSecret
 	}
 
 	router.attachRoutes()
@@ -36,9 +65,44 @@ func (r *Router) attachRoutes() {
 	v1 := r.Engine.Group("/")
 	{
 		v1.GET("campaigns", r.GetCampaigns)
-		v1.POST("campaigns", r.CreateCampaign)
+		v1.POST("campaigns", r.idempotent, r.CreateCampaign)
 		v1.GET("campaigns/:id", r.GetCampaign)
-		v1.POST("campaigns/:id/send", r.SendCampaign)
+		v1.PATCH("campaigns/:id", r.idempotent, r.UpdateCampaign)
+		v1.DELETE("campaigns/:id", r.idempotent, r.ArchiveCampaign)
+		v1.POST("campaigns/:id/clone", r.idempotent, r.CloneCampaign)
+		v1.POST("campaigns/:id/pause", r.idempotent, r.PauseCampaign)
+		v1.POST("campaigns/:id/resume", r.idempotent, r.ResumeCampaign)
+		v1.POST("campaigns/:id/cancel", r.idempotent, r.CancelCampaign)
+		v1.GET("campaigns/:id/runs", r.GetCampaignRuns)
+		v1.POST("campaigns/:id/skip", r.idempotent, r.SkipCampaignOccurrence)
+		v1.DELETE("campaigns/:id/recurrence", r.idempotent, r.EndCampaignRecurrence)
+		v1.POST("campaigns/:id/send", r.idempotent, r.SendCampaign)
+		v1.GET("campaigns/:id/dispatches/:job_id", r.GetCampaignDispatch)
+		v1.GET("campaigns/:id/dispatches/:job_id/outcomes", r.GetDispatchOutcomes)
+		v1.GET("campaigns/:id/messages", r.GetCampaignMessages)
+		v1.POST("campaigns/:id/estimate", r.EstimateCampaign)
 		v1.POST("campaigns/:id/personalized-preview", r.Preview)
+
+		v1.GET("customers", r.GetCustomers)
+		v1.POST("customers", r.idempotent, r.CreateCustomer)
+		v1.GET("customers/:id", r.GetCustomer)
+		v1.PUT("customers/:id", r.idempotent, r.UpdateCustomer)
+		v1.DELETE("customers/:id", r.idempotent, r.DeleteCustomer)
+		v1.POST("customers/imports", r.ImportCustomers)
+		v1.GET("customers/imports/:id", r.GetCustomerImport)
+		v1.GET("customers/imports/:id/report", r.GetCustomerImportReport)
+
+		v1.GET("segments", r.GetSegments)
+		v1.POST("segments", r.idempotent, r.CreateSegment)
+		v1.GET("segments/:id", r.GetSegment)
+		v1.PUT("segments/:id", r.idempotent, r.UpdateSegment)
+		v1.DELETE("segments/:id", r.idempotent, r.DeleteSegment)
+		v1.GET("segments/:id/count", r.CountSegment)
+
+		v1.GET("messages/:id", r.GetMessage)
+		v1.GET("messages/:id/events", r.GetMessageEvents)
+		v1.POST("messages/:id/retry", r.idempotent, r.RetryMessage)
+
+		v1.POST("webhooks/:channel/receipts", r.ReceiveDeliveryReceipt)
 	}
 }
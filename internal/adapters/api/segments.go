@@ -0,0 +1,177 @@
+package api
+
+import (
+	"focus-dev-challenge/internal/core/domain"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+func (r *Router) GetSegments(c *gin.Context) {
+	pageNumber := 1
+	pageSize := 10
+
+	if v, err := strconv.Atoi(c.Query("page_number")); err == nil {
+		if v > 0 {
+			pageNumber = v
+		}
+	}
+
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil {
+		if v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	records, err := r.service.ListSegments(c.Request.Context(), pageNumber, pageSize)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	totalCount := int64(0)
+	if len(records) > 0 {
+		totalCount = records[0].TotalCount
+	}
+
+	totalPages := int(math.Ceil(float64(totalCount) / float64(pageSize)))
+	res := gin.H{
+		"data": records,
+		"pagination": gin.H{
+			"page":        pageNumber,
+			"page_size":   pageSize,
+			"total_count": totalCount,
+			"total_pages": totalPages,
+		},
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (r *Router) CreateSegment(c *gin.Context) {
+	var data domain.CreateSegment
+	if err := c.ShouldBind(&data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	segment, err := r.service.AddSegment(c.Request.Context(), &data)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+func (r *Router) GetSegment(c *gin.Context) {
+	ID := c.Param("id")
+	segmentID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	segment, err := r.service.RetrieveSegment(c.Request.Context(), int64(segmentID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+func (r *Router) UpdateSegment(c *gin.Context) {
+	ID := c.Param("id")
+	segmentID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var data domain.UpdateSegment
+	if err := c.ShouldBind(&data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	segment, err := r.service.UpdateSegment(c.Request.Context(), int64(segmentID), &data)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+func (r *Router) DeleteSegment(c *gin.Context) {
+	ID := c.Param("id")
+	segmentID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := r.service.DeleteSegment(c.Request.Context(), int64(segmentID)); err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (r *Router) CountSegment(c *gin.Context) {
+	ID := c.Param("id")
+	segmentID, err := strconv.Atoi(ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	result, err := r.service.CountSegment(c.Request.Context(), int64(segmentID))
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
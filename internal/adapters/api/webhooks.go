@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"focus-dev-challenge/internal/core/domain"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+const (
+	webhookSignatureHeader = "X-Webhook-Signature"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+
+	// webhookTolerance bounds how far a signed timestamp may drift from now,
+	// so a captured receipt cannot be replayed later.
+	webhookTolerance = 5 * time.Minute
+
+	maxWebhookBody = 1 << 20
+)
+
+var (
+	errMissingSignature = stderrors.New("missing webhook signature")
+	errInvalidTimestamp = stderrors.New("invalid webhook timestamp")
+	errStaleTimestamp   = stderrors.New("webhook timestamp outside the accepted window")
+	errInvalidSignature = stderrors.New("invalid webhook signature")
+)
+
+func (r *Router) ReceiveDeliveryReceipt(c *gin.Context) {
+	channel := c.Param("channel")
+	secret, ok := r.webhookSecrets[channel]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown channel"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+		return
+	}
+
+	err = verifyWebhookSignature(secret, c.GetHeader(webhookTimestampHeader), c.GetHeader(webhookSignatureHeader), body, time.Now())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var data domain.DeliveryReceipt
+	if err := json.Unmarshal(body, &data); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"detail": err.Error()})
+		return
+	}
+
+	msg, err := r.service.RecordDeliveryReceipt(c.Request.Context(), channel, &data)
+	if err != nil {
+		if cerr, ok := err.(*errors.Error); ok {
+			code, detail := cerr.HTTPStatus()
+			c.JSON(code, detail)
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message_id": msg.ID, "status": msg.Status})
+}
+
+// verifyWebhookSignature checks a "sha256=<hex>" signature over
+// "<timestamp>.<body>", keyed with the channel's webhook secret. The timestamp
+// is in Unix seconds and must lie within webhookTolerance of now.
+func verifyWebhookSignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return errMissingSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidTimestamp
+	}
+
+	if drift := now.Sub(time.Unix(ts, 0)); drift > webhookTolerance || drift < -webhookTolerance {
+		return errStaleTimestamp
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return errInvalidSignature
+	}
+
+	if !hmac.Equal(got, signWebhook(secret, timestamp, body)) {
+		return errInvalidSignature
+	}
+
+	return nil
+}
+
+func signWebhook(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"message_id":"abc","status":"delivered"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := "sha256=" + hex.EncodeToString(signWebhook("secret", timestamp, body))
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		expected  error
+	}{
+		{name: "valid", secret: "secret", timestamp: timestamp, signature: signature, body: body},
+		{name: "missing signature", secret: "secret", timestamp: timestamp, body: body, expected: errMissingSignature},
+		{name: "bad timestamp", secret: "secret", timestamp: "yesterday", signature: signature, body: body, expected: errInvalidTimestamp},
+		{
+			name:      "stale timestamp",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			signature: signature,
+			body:      body,
+			expected:  errStaleTimestamp,
+		},
+		{name: "wrong secret", secret: "other", timestamp: timestamp, signature: signature, body: body, expected: errInvalidSignature},
+		{name: "tampered body", secret: "secret", timestamp: timestamp, signature: signature, body: []byte(`{}`), expected: errInvalidSignature},
+		{name: "not hex", secret: "secret", timestamp: timestamp, signature: "sha256=zz", body: body, expected: errInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWebhookSignature(tt.secret, tt.timestamp, tt.signature, tt.body, now)
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
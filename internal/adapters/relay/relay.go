@@ -0,0 +1,134 @@
+package relay
+
+import (
+	"context"
+	stderrors "errors"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/config"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/ports"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+type enqueuer interface {
+	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// Relay publishes outbox entries to the task queue. Entries are written in the
+// same transaction as the rows they refer to, so a task is only ever published
+// for data that was committed.
+type Relay struct {
+	broker     enqueuer
+	logger     *zap.Logger
+	repository ports.AppRepository
+	interval   time.Duration
+	batchSize  int32
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewRelay(cfg *config.Config, repo ports.AppRepository, logger *zap.Logger) *Relay {
+	client := asynq.NewClient(&asynq.RedisClientOpt{
+		Addr:        cfg.RedisHost,
+		DB:          cfg.RedisDB,
+		DialTimeout: time.Duration(cfg.DefaultTimeout) * time.Second,
+	})
+
+	return &Relay{
+		broker:     client,
+		logger:     logger,
+		repository: repo,
+		interval:   time.Duration(cfg.RelayInterval) * time.Millisecond,
+		batchSize:  int32(cfg.RelayBatchSize),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start polls the outbox in the background until Stop is called. Full batches
+// are followed immediately by another pass so that a backlog drains without
+// waiting for the next tick.
+func (r *Relay) Start() error {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			for r.poll() == int(r.batchSize) {
+				select {
+				case <-r.stop:
+					return
+				default:
+				}
+			}
+
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop waits for the pass in flight to finish.
+func (r *Relay) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// poll runs a single dispatch pass and returns the number of entries claimed
+// and published.
+func (r *Relay) poll() int {
+	n, err := r.repository.DispatchOutbox(context.Background(), r.batchSize, r.publish)
+	if err != nil {
+		r.logger.Error("could not dispatch outbox", zap.Error(err))
+		return 0
+	}
+
+	if n > 0 {
+		r.logger.Debug("dispatched outbox entries", zap.Int("count", n))
+	}
+
+	return n
+}
+
+// publish enqueues an outbox entry under a task ID derived from the entry, so
+// an entry published by a pass that failed to commit is not enqueued twice.
+func (r *Relay) publish(entry *repository.Outbox) error {
+	task := asynq.NewTask(entry.TaskType, entry.Payload)
+	_, err := r.broker.Enqueue(task, taskOptions(entry)...)
+	if err != nil && !stderrors.Is(err, asynq.ErrTaskIDConflict) {
+		r.logger.Warn(
+			"could not publish outbox entry",
+			zap.Int64("entry_id", entry.ID),
+			zap.String("task_type", entry.TaskType),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func taskOptions(entry *repository.Outbox) []asynq.Option {
+	opts := []asynq.Option{
+		asynq.TaskID(domain.OutboxTaskID(entry.ID)),
+		asynq.Queue(entry.Queue),
+	}
+	if entry.ProcessAt.Valid {
+		opts = append(opts, asynq.ProcessAt(entry.ProcessAt.Time))
+	}
+	if entry.MaxRetry.Valid {
+		opts = append(opts, asynq.MaxRetry(int(entry.MaxRetry.Int32)))
+	}
+
+	return opts
+}
@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"errors"
+	"focus-dev-challenge/internal/adapters/repository"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeBroker struct {
+	err   error
+	tasks []*asynq.Task
+	opts  [][]asynq.Option
+}
+
+func (f *fakeBroker) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	f.tasks = append(f.tasks, task)
+	f.opts = append(f.opts, opts)
+	return &asynq.TaskInfo{}, f.err
+}
+
+func TestPublish(t *testing.T) {
+	entry := &repository.Outbox{ID: 42, TaskType: "task:send_message", Queue: "tasks", Payload: []byte(`{"message_id":7}`)}
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "published", err: nil},
+		{name: "already published", err: asynq.ErrTaskIDConflict},
+		{name: "broker error", err: errors.New("redis unavailable"), wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := &fakeBroker{err: tc.err}
+			r := &Relay{broker: broker, logger: zap.NewNop()}
+
+			err := r.publish(entry)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if assert.Len(t, broker.tasks, 1) {
+				assert.Equal(t, entry.TaskType, broker.tasks[0].Type())
+				assert.Equal(t, entry.Payload, broker.tasks[0].Payload())
+			}
+		})
+	}
+}
+
+func TestTaskOptions(t *testing.T) {
+	at := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+	entry := &repository.Outbox{
+		ID:        42,
+		Queue:     "tasks",
+		ProcessAt: pgtype.Timestamptz{Time: at, Valid: true},
+		MaxRetry:  pgtype.Int4{Int32: 3, Valid: true},
+	}
+
+	values := map[asynq.OptionType]any{}
+	for _, opt := range taskOptions(entry) {
+		values[opt.Type()] = opt.Value()
+	}
+
+	assert.Equal(t, "outbox:42", values[asynq.TaskIDOpt])
+	assert.Equal(t, "tasks", values[asynq.QueueOpt])
+	assert.Equal(t, at, values[asynq.ProcessAtOpt])
+	assert.Equal(t, 3, values[asynq.MaxRetryOpt])
+
+	plain := taskOptions(&repository.Outbox{ID: 1, Queue: "tasks"})
+	assert.Len(t, plain, 2)
+}
@@ -11,18 +11,195 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const advanceCampaignRecurrence = `-- name: AdvanceCampaignRecurrence :one
+UPDATE campaigns
+SET
+    scheduled_at = $1::timestamptz,
+    updated_at = NOW()
+WHERE id = $2
+    AND recurrence IS NOT NULL
+    AND status = ANY($3::text[])
+    AND scheduled_at = $4::timestamptz
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type AdvanceCampaignRecurrenceParams struct {
+	NextRunAt     pgtype.Timestamptz `json:"next_run_at"`
+	CampaignID    int64              `json:"campaign_id"`
+	FromStatuses  []string           `json:"from_statuses"`
+	ExpectedRunAt pgtype.Timestamptz `json:"expected_run_at"`
+}
+
+// Moves a recurring campaign on to its next occurrence, provided it is still
+// due at expected_run_at.
+func (q *Queries) AdvanceCampaignRecurrence(ctx context.Context, arg *AdvanceCampaignRecurrenceParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, advanceCampaignRecurrence,
+		arg.NextRunAt,
+		arg.CampaignID,
+		arg.FromStatuses,
+		arg.ExpectedRunAt,
+	)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const archiveCampaign = `-- name: ArchiveCampaign :one
+UPDATE campaigns c
+SET
+    status = 'archived',
+    archived_at = NOW(),
+    updated_at = NOW()
+WHERE c.id = $1
+    AND c.status = ANY($2::text[])
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d
+        WHERE d.campaign_id = c.id AND d.status IN ('pending', 'running')
+    )
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type ArchiveCampaignParams struct {
+	CampaignID   int64    `json:"campaign_id"`
+	FromStatuses []string `json:"from_statuses"`
+}
+
+func (q *Queries) ArchiveCampaign(ctx context.Context, arg *ArchiveCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, archiveCampaign, arg.CampaignID, arg.FromStatuses)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const cancelCampaign = `-- name: CancelCampaign :one
+UPDATE campaigns
+SET
+    status = 'cancelled',
+    cancelled_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+    AND status = ANY($2::text[])
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type CancelCampaignParams struct {
+	CampaignID   int64    `json:"campaign_id"`
+	FromStatuses []string `json:"from_statuses"`
+}
+
+func (q *Queries) CancelCampaign(ctx context.Context, arg *CancelCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, cancelCampaign, arg.CampaignID, arg.FromStatuses)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const countCampaigns = `-- name: CountCampaigns :one
+SELECT COUNT(*)
+FROM campaigns c
+WHERE
+    (
+        (($1::text IS NULL OR $1::text = '') AND c.status <> 'archived')
+        OR c.status = $1
+    )
+    AND (
+        $2::text IS NULL 
+        OR $2::text = '' 
+        OR c.channel = $2
+    )
+    AND c.parent_id IS NULL
+`
+
+type CountCampaignsParams struct {
+	Status  string `json:"status"`
+	Channel string `json:"channel"`
+}
+
+func (q *Queries) CountCampaigns(ctx context.Context, arg *CountCampaignsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countCampaigns, arg.Status, arg.Channel)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createCampaign = `-- name: CreateCampaign :one
-INSERT INTO campaigns (name, channel, status, base_template, scheduled_at)
-VALUES ($1, $2, $3, $4, $5) 
-RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at
+INSERT INTO campaigns (name, channel, status, base_template, scheduled_at, lenient_templates, max_segments, segment_id, customer_ids, recurrence, timezone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) 
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
 `
 
 type CreateCampaignParams struct {
-	Name         string           `json:"name"`
-	Channel      string           `json:"channel"`
-	Status       string           `json:"status"`
-	BaseTemplate string           `json:"base_template"`
-	ScheduledAt  pgtype.Timestamp `json:"scheduled_at"`
+	Name             string             `json:"name"`
+	Channel          string             `json:"channel"`
+	Status           string             `json:"status"`
+	BaseTemplate     string             `json:"base_template"`
+	ScheduledAt      pgtype.Timestamptz `json:"scheduled_at"`
+	LenientTemplates bool               `json:"lenient_templates"`
+	MaxSegments      pgtype.Int4        `json:"max_segments"`
+	SegmentID        pgtype.Int8        `json:"segment_id"`
+	CustomerIds      []int64            `json:"customer_ids"`
+	Recurrence       pgtype.Text        `json:"recurrence"`
+	Timezone         string             `json:"timezone"`
 }
 
 func (q *Queries) CreateCampaign(ctx context.Context, arg *CreateCampaignParams) (*Campaign, error) {
@@ -32,6 +209,12 @@ func (q *Queries) CreateCampaign(ctx context.Context, arg *CreateCampaignParams)
 		arg.Status,
 		arg.BaseTemplate,
 		arg.ScheduledAt,
+		arg.LenientTemplates,
+		arg.MaxSegments,
+		arg.SegmentID,
+		arg.CustomerIds,
+		arg.Recurrence,
+		arg.Timezone,
 	)
 	var i Campaign
 	err := row.Scan(
@@ -43,18 +226,186 @@ func (q *Queries) CreateCampaign(ctx context.Context, arg *CreateCampaignParams)
 		&i.ScheduledAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const createCampaignRun = `-- name: CreateCampaignRun :one
+INSERT INTO campaigns (name, channel, status, base_template, scheduled_at, lenient_templates, max_segments, segment_id, customer_ids, timezone, parent_id)
+SELECT p.name, p.channel, 'sending', p.base_template, $1::timestamptz, p.lenient_templates, p.max_segments, p.segment_id, p.customer_ids, p.timezone, p.id
+FROM campaigns p
+WHERE p.id = $2
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type CreateCampaignRunParams struct {
+	RunAt      pgtype.Timestamptz `json:"run_at"`
+	CampaignID int64              `json:"campaign_id"`
+}
+
+// Starts the occurrence of a recurring campaign due at run_at as a campaign of
+// its own, with the content and audience of its parent.
+func (q *Queries) CreateCampaignRun(ctx context.Context, arg *CreateCampaignRunParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, createCampaignRun, arg.RunAt, arg.CampaignID)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const endCampaignRecurrence = `-- name: EndCampaignRecurrence :one
+UPDATE campaigns
+SET
+    status = 'draft',
+    scheduled_at = NULL,
+    paused_at = NULL,
+    recurrence = NULL,
+    recurrence_ended_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+    AND recurrence IS NOT NULL
+    AND status = ANY($2::text[])
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type EndCampaignRecurrenceParams struct {
+	CampaignID   int64    `json:"campaign_id"`
+	FromStatuses []string `json:"from_statuses"`
+}
+
+// Stops a recurring campaign from starting more runs and returns it to draft.
+// Runs already started are left alone.
+func (q *Queries) EndCampaignRecurrence(ctx context.Context, arg *EndCampaignRecurrenceParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, endCampaignRecurrence, arg.CampaignID, arg.FromStatuses)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const finalizeCampaign = `-- name: FinalizeCampaign :one
+UPDATE campaigns c
+SET
+    status = CASE
+        WHEN EXISTS (
+            SELECT 1 FROM outbound_messages om
+            WHERE om.campaign_id = c.id AND om.status IN ('sent', 'delivered', 'undelivered', 'read')
+        ) THEN 'sent'
+        ELSE 'failed'
+    END,
+    updated_at = NOW()
+WHERE c.id = $1
+    AND c.status = 'sending'
+    AND NOT EXISTS (
+        SELECT 1 FROM outbound_messages om
+        WHERE om.campaign_id = c.id AND om.status IN ('pending', 'sending')
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d
+        WHERE d.campaign_id = c.id AND d.status IN ('pending', 'running')
+    )
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+func (q *Queries) FinalizeCampaign(ctx context.Context, campaignID int64) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, finalizeCampaign, campaignID)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
 	)
 	return &i, err
 }
 
 const getCampaign = `-- name: GetCampaign :one
 SELECT
-    c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at,
+    c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at, c.lenient_templates, c.max_segments, c.archived_at, c.paused_at, c.cancelled_at, c.segment_id, c.customer_ids, c.recurrence, c.timezone, c.recurrence_ended_at, c.parent_id,
     jsonb_build_object(
         'total_messages', COALESCE(COUNT(om.id), 0),
         'pending',        COALESCE(SUM(CASE WHEN om.status = 'pending' THEN 1 ELSE 0 END), 0),
+        'sending',        COALESCE(SUM(CASE WHEN om.status = 'sending' THEN 1 ELSE 0 END), 0),
         'sent',           COALESCE(SUM(CASE WHEN om.status = 'sent' THEN 1 ELSE 0 END), 0),
-        'failed',         COALESCE(SUM(CASE WHEN om.status = 'failed' THEN 1 ELSE 0 END), 0)
+        'delivered',      COALESCE(SUM(CASE WHEN om.status = 'delivered' THEN 1 ELSE 0 END), 0),
+        'undelivered',    COALESCE(SUM(CASE WHEN om.status = 'undelivered' THEN 1 ELSE 0 END), 0),
+        'read',           COALESCE(SUM(CASE WHEN om.status = 'read' THEN 1 ELSE 0 END), 0),
+        'failed',         COALESCE(SUM(CASE WHEN om.status = 'failed' THEN 1 ELSE 0 END), 0),
+        'cancelled',      COALESCE(SUM(CASE WHEN om.status = 'cancelled' THEN 1 ELSE 0 END), 0),
+        'delivery_rate',  ROUND(
+            SUM(CASE WHEN om.status IN ('delivered', 'read') THEN 1 ELSE 0 END)::numeric
+            / NULLIF(SUM(CASE WHEN om.status IN ('sent', 'delivered', 'undelivered', 'read') THEN 1 ELSE 0 END), 0),
+            4
+        ),
+        'read_rate',      ROUND(
+            SUM(CASE WHEN om.status = 'read' THEN 1 ELSE 0 END)::numeric
+            / NULLIF(SUM(CASE WHEN om.status IN ('sent', 'delivered', 'undelivered', 'read') THEN 1 ELSE 0 END), 0),
+            4
+        )
     ) AS stats
 FROM campaigns c
 LEFT JOIN outbound_messages om ON om.campaign_id = c.id
@@ -63,15 +414,26 @@ GROUP BY c.id
 `
 
 type GetCampaignRow struct {
-	ID           int64            `json:"id"`
-	Name         string           `json:"name"`
-	Channel      string           `json:"channel"`
-	Status       string           `json:"status"`
-	BaseTemplate string           `json:"base_template"`
-	ScheduledAt  pgtype.Timestamp `json:"scheduled_at"`
-	CreatedAt    pgtype.Timestamp `json:"created_at"`
-	UpdatedAt    pgtype.Timestamp `json:"updated_at"`
-	Stats        []byte           `json:"stats"`
+	ID                int64              `json:"id"`
+	Name              string             `json:"name"`
+	Channel           string             `json:"channel"`
+	Status            string             `json:"status"`
+	BaseTemplate      string             `json:"base_template"`
+	ScheduledAt       pgtype.Timestamptz `json:"scheduled_at"`
+	CreatedAt         pgtype.Timestamp   `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp   `json:"updated_at"`
+	LenientTemplates  bool               `json:"lenient_templates"`
+	MaxSegments       pgtype.Int4        `json:"max_segments"`
+	ArchivedAt        pgtype.Timestamp   `json:"archived_at"`
+	PausedAt          pgtype.Timestamp   `json:"paused_at"`
+	CancelledAt       pgtype.Timestamp   `json:"cancelled_at"`
+	SegmentID         pgtype.Int8        `json:"segment_id"`
+	CustomerIds       []int64            `json:"customer_ids"`
+	Recurrence        pgtype.Text        `json:"recurrence"`
+	Timezone          string             `json:"timezone"`
+	RecurrenceEndedAt pgtype.Timestamp   `json:"recurrence_ended_at"`
+	ParentID          pgtype.Int8        `json:"parent_id"`
+	Stats             []byte             `json:"stats"`
 }
 
 func (q *Queries) GetCampaign(ctx context.Context, campaignID int64) (*GetCampaignRow, error) {
@@ -86,20 +448,139 @@ func (q *Queries) GetCampaign(ctx context.Context, campaignID int64) (*GetCampai
 		&i.ScheduledAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
 		&i.Stats,
 	)
 	return &i, err
 }
 
+const getCampaignStatus = `-- name: GetCampaignStatus :one
+SELECT status FROM campaigns WHERE id = $1
+`
+
+func (q *Queries) GetCampaignStatus(ctx context.Context, campaignID int64) (string, error) {
+	row := q.db.QueryRow(ctx, getCampaignStatus, campaignID)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
+
+const listCampaignRuns = `-- name: ListCampaignRuns :many
+SELECT
+    c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at, c.lenient_templates, c.max_segments, c.archived_at, c.paused_at, c.cancelled_at, c.segment_id, c.customer_ids, c.recurrence, c.timezone, c.recurrence_ended_at, c.parent_id,
+    jsonb_build_object(
+        'total_messages', COALESCE(COUNT(om.id), 0),
+        'pending',        COALESCE(SUM(CASE WHEN om.status = 'pending' THEN 1 ELSE 0 END), 0),
+        'sending',        COALESCE(SUM(CASE WHEN om.status = 'sending' THEN 1 ELSE 0 END), 0),
+        'sent',           COALESCE(SUM(CASE WHEN om.status = 'sent' THEN 1 ELSE 0 END), 0),
+        'delivered',      COALESCE(SUM(CASE WHEN om.status = 'delivered' THEN 1 ELSE 0 END), 0),
+        'undelivered',    COALESCE(SUM(CASE WHEN om.status = 'undelivered' THEN 1 ELSE 0 END), 0),
+        'read',           COALESCE(SUM(CASE WHEN om.status = 'read' THEN 1 ELSE 0 END), 0),
+        'failed',         COALESCE(SUM(CASE WHEN om.status = 'failed' THEN 1 ELSE 0 END), 0),
+        'cancelled',      COALESCE(SUM(CASE WHEN om.status = 'cancelled' THEN 1 ELSE 0 END), 0)
+    ) AS stats,
+    COUNT(*) OVER() AS total_count
+FROM campaigns c
+LEFT JOIN outbound_messages om ON om.campaign_id = c.id
+WHERE c.parent_id = $1
+GROUP BY c.id
+ORDER BY c.scheduled_at DESC, c.id DESC
+LIMIT $2
+OFFSET (($3 - 1) * $2)
+`
+
+type ListCampaignRunsParams struct {
+	CampaignID int64       `json:"campaign_id"`
+	PageSize   int32       `json:"page_size"`
+	PageNumber interface{} `json:"-page_number"`
+}
+
+type ListCampaignRunsRow struct {
+	ID                int64              `json:"id"`
+	Name              string             `json:"name"`
+	Channel           string             `json:"channel"`
+	Status            string             `json:"status"`
+	BaseTemplate      string             `json:"base_template"`
+	ScheduledAt       pgtype.Timestamptz `json:"scheduled_at"`
+	CreatedAt         pgtype.Timestamp   `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp   `json:"updated_at"`
+	LenientTemplates  bool               `json:"lenient_templates"`
+	MaxSegments       pgtype.Int4        `json:"max_segments"`
+	ArchivedAt        pgtype.Timestamp   `json:"archived_at"`
+	PausedAt          pgtype.Timestamp   `json:"paused_at"`
+	CancelledAt       pgtype.Timestamp   `json:"cancelled_at"`
+	SegmentID         pgtype.Int8        `json:"segment_id"`
+	CustomerIds       []int64            `json:"customer_ids"`
+	Recurrence        pgtype.Text        `json:"recurrence"`
+	Timezone          string             `json:"timezone"`
+	RecurrenceEndedAt pgtype.Timestamp   `json:"recurrence_ended_at"`
+	ParentID          pgtype.Int8        `json:"parent_id"`
+	Stats             []byte             `json:"stats"`
+	TotalCount        int64              `json:"total_count"`
+}
+
+// The runs a recurring campaign started, latest occurrence first, each with
+// the stats of its own messages.
+func (q *Queries) ListCampaignRuns(ctx context.Context, arg *ListCampaignRunsParams) ([]*ListCampaignRunsRow, error) {
+	rows, err := q.db.Query(ctx, listCampaignRuns, arg.CampaignID, arg.PageSize, arg.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListCampaignRunsRow
+	for rows.Next() {
+		var i ListCampaignRunsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Channel,
+			&i.Status,
+			&i.BaseTemplate,
+			&i.ScheduledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LenientTemplates,
+			&i.MaxSegments,
+			&i.ArchivedAt,
+			&i.PausedAt,
+			&i.CancelledAt,
+			&i.SegmentID,
+			&i.CustomerIds,
+			&i.Recurrence,
+			&i.Timezone,
+			&i.RecurrenceEndedAt,
+			&i.ParentID,
+			&i.Stats,
+			&i.TotalCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listCampaigns = `-- name: ListCampaigns :many
 SELECT
-    c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at,
+    c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at, c.lenient_templates, c.max_segments, c.archived_at, c.paused_at, c.cancelled_at, c.segment_id, c.customer_ids, c.recurrence, c.timezone, c.recurrence_ended_at, c.parent_id,
     COUNT(*) OVER() AS total_count
 FROM campaigns c
 WHERE
     (
-        $1::text IS NULL 
-        OR $1::text = '' 
+        (($1::text IS NULL OR $1::text = '') AND c.status <> 'archived')
         OR c.status = $1
     )
     AND (
@@ -107,6 +588,7 @@ WHERE
         OR $2::text = '' 
         OR c.channel = $2
     )
+    AND c.parent_id IS NULL
 ORDER BY c.id DESC
 LIMIT $4
 OFFSET (($3- 1) * $4)
@@ -120,15 +602,26 @@ type ListCampaignsParams struct {
 }
 
 type ListCampaignsRow struct {
-	ID           int64            `json:"id"`
-	Name         string           `json:"name"`
-	Channel      string           `json:"channel"`
-	Status       string           `json:"status"`
-	BaseTemplate string           `json:"base_template"`
-	ScheduledAt  pgtype.Timestamp `json:"scheduled_at"`
-	CreatedAt    pgtype.Timestamp `json:"created_at"`
-	UpdatedAt    pgtype.Timestamp `json:"updated_at"`
-	TotalCount   int64            `json:"total_count"`
+	ID                int64              `json:"id"`
+	Name              string             `json:"name"`
+	Channel           string             `json:"channel"`
+	Status            string             `json:"status"`
+	BaseTemplate      string             `json:"base_template"`
+	ScheduledAt       pgtype.Timestamptz `json:"scheduled_at"`
+	CreatedAt         pgtype.Timestamp   `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp   `json:"updated_at"`
+	LenientTemplates  bool               `json:"lenient_templates"`
+	MaxSegments       pgtype.Int4        `json:"max_segments"`
+	ArchivedAt        pgtype.Timestamp   `json:"archived_at"`
+	PausedAt          pgtype.Timestamp   `json:"paused_at"`
+	CancelledAt       pgtype.Timestamp   `json:"cancelled_at"`
+	SegmentID         pgtype.Int8        `json:"segment_id"`
+	CustomerIds       []int64            `json:"customer_ids"`
+	Recurrence        pgtype.Text        `json:"recurrence"`
+	Timezone          string             `json:"timezone"`
+	RecurrenceEndedAt pgtype.Timestamp   `json:"recurrence_ended_at"`
+	ParentID          pgtype.Int8        `json:"parent_id"`
+	TotalCount        int64              `json:"total_count"`
 }
 
 func (q *Queries) ListCampaigns(ctx context.Context, arg *ListCampaignsParams) ([]*ListCampaignsRow, error) {
@@ -154,6 +647,17 @@ func (q *Queries) ListCampaigns(ctx context.Context, arg *ListCampaignsParams) (
 			&i.ScheduledAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.LenientTemplates,
+			&i.MaxSegments,
+			&i.ArchivedAt,
+			&i.PausedAt,
+			&i.CancelledAt,
+			&i.SegmentID,
+			&i.CustomerIds,
+			&i.Recurrence,
+			&i.Timezone,
+			&i.RecurrenceEndedAt,
+			&i.ParentID,
 			&i.TotalCount,
 		); err != nil {
 			return nil, err
@@ -165,3 +669,482 @@ func (q *Queries) ListCampaigns(ctx context.Context, arg *ListCampaignsParams) (
 	}
 	return items, nil
 }
+
+const listCampaignsAfter = `-- name: ListCampaignsAfter :many
+SELECT c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at, c.lenient_templates, c.max_segments, c.archived_at, c.paused_at, c.cancelled_at, c.segment_id, c.customer_ids, c.recurrence, c.timezone, c.recurrence_ended_at, c.parent_id
+FROM campaigns c
+WHERE
+    (
+        (($1::text IS NULL OR $1::text = '') AND c.status <> 'archived')
+        OR c.status = $1
+    )
+    AND (
+        $2::text IS NULL 
+        OR $2::text = '' 
+        OR c.channel = $2
+    )
+    AND c.parent_id IS NULL
+    AND (
+        $3::timestamp IS NULL
+        OR (c.created_at, c.id) < ($3::timestamp, $4::bigint)
+    )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $5
+`
+
+type ListCampaignsAfterParams struct {
+	Status          string           `json:"status"`
+	Channel         string           `json:"channel"`
+	CursorCreatedAt pgtype.Timestamp `json:"cursor_created_at"`
+	CursorID        int64            `json:"cursor_id"`
+	PageSize        int32            `json:"page_size"`
+}
+
+// Campaigns older than the cursor, newest first. Without a cursor this is the
+// first page.
+func (q *Queries) ListCampaignsAfter(ctx context.Context, arg *ListCampaignsAfterParams) ([]*Campaign, error) {
+	rows, err := q.db.Query(ctx, listCampaignsAfter,
+		arg.Status,
+		arg.Channel,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Campaign
+	for rows.Next() {
+		var i Campaign
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Channel,
+			&i.Status,
+			&i.BaseTemplate,
+			&i.ScheduledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LenientTemplates,
+			&i.MaxSegments,
+			&i.ArchivedAt,
+			&i.PausedAt,
+			&i.CancelledAt,
+			&i.SegmentID,
+			&i.CustomerIds,
+			&i.Recurrence,
+			&i.Timezone,
+			&i.RecurrenceEndedAt,
+			&i.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCampaignsBefore = `-- name: ListCampaignsBefore :many
+SELECT c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at, c.lenient_templates, c.max_segments, c.archived_at, c.paused_at, c.cancelled_at, c.segment_id, c.customer_ids, c.recurrence, c.timezone, c.recurrence_ended_at, c.parent_id
+FROM campaigns c
+WHERE
+    (
+        (($1::text IS NULL OR $1::text = '') AND c.status <> 'archived')
+        OR c.status = $1
+    )
+    AND (
+        $2::text IS NULL 
+        OR $2::text = '' 
+        OR c.channel = $2
+    )
+    AND c.parent_id IS NULL
+    AND (c.created_at, c.id) > ($3::timestamp, $4::bigint)
+ORDER BY c.created_at, c.id
+LIMIT $5
+`
+
+type ListCampaignsBeforeParams struct {
+	Status          string           `json:"status"`
+	Channel         string           `json:"channel"`
+	CursorCreatedAt pgtype.Timestamp `json:"cursor_created_at"`
+	CursorID        int64            `json:"cursor_id"`
+	PageSize        int32            `json:"page_size"`
+}
+
+// Campaigns newer than the cursor, oldest first.
+func (q *Queries) ListCampaignsBefore(ctx context.Context, arg *ListCampaignsBeforeParams) ([]*Campaign, error) {
+	rows, err := q.db.Query(ctx, listCampaignsBefore,
+		arg.Status,
+		arg.Channel,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Campaign
+	for rows.Next() {
+		var i Campaign
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Channel,
+			&i.Status,
+			&i.BaseTemplate,
+			&i.ScheduledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LenientTemplates,
+			&i.MaxSegments,
+			&i.ArchivedAt,
+			&i.PausedAt,
+			&i.CancelledAt,
+			&i.SegmentID,
+			&i.CustomerIds,
+			&i.Recurrence,
+			&i.Timezone,
+			&i.RecurrenceEndedAt,
+			&i.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDueCampaigns = `-- name: ListDueCampaigns :many
+SELECT c.id, c.name, c.channel, c.status, c.base_template, c.scheduled_at, c.created_at, c.updated_at, c.lenient_templates, c.max_segments, c.archived_at, c.paused_at, c.cancelled_at, c.segment_id, c.customer_ids, c.recurrence, c.timezone, c.recurrence_ended_at, c.parent_id
+FROM campaigns c
+WHERE c.status = 'scheduled'
+    AND c.scheduled_at <= $1::timestamptz
+    AND (c.segment_id IS NOT NULL OR c.customer_ids IS NOT NULL)
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d WHERE d.campaign_id = c.id
+    )
+ORDER BY c.scheduled_at, c.id
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+
+type ListDueCampaignsParams struct {
+	Now       pgtype.Timestamptz `json:"now"`
+	BatchSize int32              `json:"batch_size"`
+}
+
+// Scheduled campaigns whose time has come and that were not sent by hand ahead
+// of it. They stay locked until the scheduler's transaction ends.
+func (q *Queries) ListDueCampaigns(ctx context.Context, arg *ListDueCampaignsParams) ([]*Campaign, error) {
+	rows, err := q.db.Query(ctx, listDueCampaigns, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Campaign
+	for rows.Next() {
+		var i Campaign
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Channel,
+			&i.Status,
+			&i.BaseTemplate,
+			&i.ScheduledAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LenientTemplates,
+			&i.MaxSegments,
+			&i.ArchivedAt,
+			&i.PausedAt,
+			&i.CancelledAt,
+			&i.SegmentID,
+			&i.CustomerIds,
+			&i.Recurrence,
+			&i.Timezone,
+			&i.RecurrenceEndedAt,
+			&i.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pauseCampaign = `-- name: PauseCampaign :one
+UPDATE campaigns
+SET
+    status = 'paused',
+    paused_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+    AND status = ANY($2::text[])
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type PauseCampaignParams struct {
+	CampaignID   int64    `json:"campaign_id"`
+	FromStatuses []string `json:"from_statuses"`
+}
+
+func (q *Queries) PauseCampaign(ctx context.Context, arg *PauseCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, pauseCampaign, arg.CampaignID, arg.FromStatuses)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const resumeCampaign = `-- name: ResumeCampaign :one
+UPDATE campaigns c
+SET
+    status = CASE
+        WHEN c.scheduled_at > $1::timestamptz THEN 'scheduled'
+        WHEN c.scheduled_at IS NOT NULL AND NOT EXISTS (
+            SELECT 1 FROM campaign_dispatches d WHERE d.campaign_id = c.id
+        ) THEN 'scheduled'
+        ELSE 'sending'
+    END,
+    paused_at = NULL,
+    updated_at = NOW()
+WHERE c.id = $2
+    AND c.status = 'paused'
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type ResumeCampaignParams struct {
+	Now        pgtype.Timestamptz `json:"now"`
+	CampaignID int64              `json:"campaign_id"`
+}
+
+// Puts a paused campaign back to sending, or back to scheduled when its time
+// has not come yet or the scheduler has yet to dispatch it.
+func (q *Queries) ResumeCampaign(ctx context.Context, arg *ResumeCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, resumeCampaign, arg.Now, arg.CampaignID)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const startScheduledCampaign = `-- name: StartScheduledCampaign :one
+UPDATE campaigns
+SET
+    status = 'sending',
+    updated_at = NOW()
+WHERE id = $1
+    AND status = 'scheduled'
+    AND scheduled_at <= $2::timestamptz
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type StartScheduledCampaignParams struct {
+	CampaignID int64              `json:"campaign_id"`
+	Now        pgtype.Timestamptz `json:"now"`
+}
+
+// Moves a scheduled campaign to sending once its time has come. Tasks of a
+// campaign that was rescheduled to a later time leave it alone.
+func (q *Queries) StartScheduledCampaign(ctx context.Context, arg *StartScheduledCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, startScheduledCampaign, arg.CampaignID, arg.Now)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const transitionCampaign = `-- name: TransitionCampaign :one
+UPDATE campaigns
+SET
+    status = $1,
+    updated_at = NOW()
+WHERE id = $2
+    AND status = ANY($3::text[])
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type TransitionCampaignParams struct {
+	ToStatus     string   `json:"to_status"`
+	CampaignID   int64    `json:"campaign_id"`
+	FromStatuses []string `json:"from_statuses"`
+}
+
+func (q *Queries) TransitionCampaign(ctx context.Context, arg *TransitionCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, transitionCampaign, arg.ToStatus, arg.CampaignID, arg.FromStatuses)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
+
+const updateCampaign = `-- name: UpdateCampaign :one
+UPDATE campaigns c
+SET
+    name = $1,
+    status = $2,
+    base_template = $3,
+    scheduled_at = $4,
+    lenient_templates = $5,
+    max_segments = $6,
+    segment_id = $7,
+    customer_ids = $8,
+    recurrence = $9,
+    timezone = $10,
+    recurrence_ended_at = $11,
+    updated_at = NOW()
+WHERE c.id = $12
+    AND c.status = ANY($13::text[])
+    AND c.updated_at = $14
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d
+        WHERE d.campaign_id = c.id AND d.status IN ('pending', 'running')
+    )
+RETURNING id, name, channel, status, base_template, scheduled_at, created_at, updated_at, lenient_templates, max_segments, archived_at, paused_at, cancelled_at, segment_id, customer_ids, recurrence, timezone, recurrence_ended_at, parent_id
+`
+
+type UpdateCampaignParams struct {
+	Name              string             `json:"name"`
+	Status            string             `json:"status"`
+	BaseTemplate      string             `json:"base_template"`
+	ScheduledAt       pgtype.Timestamptz `json:"scheduled_at"`
+	LenientTemplates  bool               `json:"lenient_templates"`
+	MaxSegments       pgtype.Int4        `json:"max_segments"`
+	SegmentID         pgtype.Int8        `json:"segment_id"`
+	CustomerIds       []int64            `json:"customer_ids"`
+	Recurrence        pgtype.Text        `json:"recurrence"`
+	Timezone          string             `json:"timezone"`
+	RecurrenceEndedAt pgtype.Timestamp   `json:"recurrence_ended_at"`
+	CampaignID        int64              `json:"campaign_id"`
+	FromStatuses      []string           `json:"from_statuses"`
+	ExpectedUpdatedAt pgtype.Timestamp   `json:"expected_updated_at"`
+}
+
+func (q *Queries) UpdateCampaign(ctx context.Context, arg *UpdateCampaignParams) (*Campaign, error) {
+	row := q.db.QueryRow(ctx, updateCampaign,
+		arg.Name,
+		arg.Status,
+		arg.BaseTemplate,
+		arg.ScheduledAt,
+		arg.LenientTemplates,
+		arg.MaxSegments,
+		arg.SegmentID,
+		arg.CustomerIds,
+		arg.Recurrence,
+		arg.Timezone,
+		arg.RecurrenceEndedAt,
+		arg.CampaignID,
+		arg.FromStatuses,
+		arg.ExpectedUpdatedAt,
+	)
+	var i Campaign
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Channel,
+		&i.Status,
+		&i.BaseTemplate,
+		&i.ScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LenientTemplates,
+		&i.MaxSegments,
+		&i.ArchivedAt,
+		&i.PausedAt,
+		&i.CancelledAt,
+		&i.SegmentID,
+		&i.CustomerIds,
+		&i.Recurrence,
+		&i.Timezone,
+		&i.RecurrenceEndedAt,
+		&i.ParentID,
+	)
+	return &i, err
+}
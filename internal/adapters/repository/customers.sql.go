@@ -7,8 +7,58 @@ package repository
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const createCustomer = `-- name: CreateCustomer :one
+INSERT INTO customers (phone, first_name, last_name, location, preferred_product)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, phone, first_name, last_name, location, preferred_product, created_at, updated_at
+`
+
+type CreateCustomerParams struct {
+	Phone            string      `json:"phone"`
+	FirstName        pgtype.Text `json:"first_name"`
+	LastName         pgtype.Text `json:"last_name"`
+	Location         pgtype.Text `json:"location"`
+	PreferredProduct pgtype.Text `json:"preferred_product"`
+}
+
+func (q *Queries) CreateCustomer(ctx context.Context, arg *CreateCustomerParams) (*Customer, error) {
+	row := q.db.QueryRow(ctx, createCustomer,
+		arg.Phone,
+		arg.FirstName,
+		arg.LastName,
+		arg.Location,
+		arg.PreferredProduct,
+	)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.FirstName,
+		&i.LastName,
+		&i.Location,
+		&i.PreferredProduct,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const deleteCustomer = `-- name: DeleteCustomer :execrows
+DELETE FROM customers WHERE id = $1
+`
+
+func (q *Queries) DeleteCustomer(ctx context.Context, customerID int64) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteCustomer, customerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getCustomerById = `-- name: GetCustomerById :one
 SELECT id, phone, first_name, last_name, location, preferred_product, created_at, updated_at FROM customers WHERE id = $1
 `
@@ -28,3 +78,242 @@ func (q *Queries) GetCustomerById(ctx context.Context, customerID int64) (*Custo
 	)
 	return &i, err
 }
+
+const listCustomers = `-- name: ListCustomers :many
+SELECT
+    cu.id, cu.phone, cu.first_name, cu.last_name, cu.location, cu.preferred_product, cu.created_at, cu.updated_at,
+    COUNT(*) OVER() AS total_count
+FROM customers cu
+WHERE
+    (
+        $1::text IS NULL
+        OR $1::text = ''
+        OR LOWER(cu.location) = LOWER($1)
+    )
+    AND (
+        $2::text IS NULL
+        OR $2::text = ''
+        OR LOWER(cu.preferred_product) = LOWER($2)
+    )
+    AND (
+        $3::text IS NULL
+        OR $3::text = ''
+        OR starts_with(cu.phone, $3)
+    )
+ORDER BY cu.id DESC
+LIMIT $5
+OFFSET (($4- 1) * $5)
+`
+
+type ListCustomersParams struct {
+	Location         string      `json:"location"`
+	PreferredProduct string      `json:"preferred_product"`
+	Phone            string      `json:"phone"`
+	PageNumber       interface{} `json:"-page_number"`
+	PageSize         int32       `json:"page_size"`
+}
+
+type ListCustomersRow struct {
+	ID               int64            `json:"id"`
+	Phone            string           `json:"phone"`
+	FirstName        pgtype.Text      `json:"first_name"`
+	LastName         pgtype.Text      `json:"last_name"`
+	Location         pgtype.Text      `json:"location"`
+	PreferredProduct pgtype.Text      `json:"preferred_product"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	TotalCount       int64            `json:"total_count"`
+}
+
+func (q *Queries) ListCustomers(ctx context.Context, arg *ListCustomersParams) ([]*ListCustomersRow, error) {
+	rows, err := q.db.Query(ctx, listCustomers,
+		arg.Location,
+		arg.PreferredProduct,
+		arg.Phone,
+		arg.PageNumber,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListCustomersRow
+	for rows.Next() {
+		var i ListCustomersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Phone,
+			&i.FirstName,
+			&i.LastName,
+			&i.Location,
+			&i.PreferredProduct,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.TotalCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCustomersByIds = `-- name: ListCustomersByIds :many
+SELECT id, phone, first_name, last_name, location, preferred_product, created_at, updated_at FROM customers
+WHERE id = ANY($1::bigint[]) AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListCustomersByIdsParams struct {
+	CustomerIds []int64 `json:"customer_ids"`
+	AfterID     int64   `json:"after_id"`
+	BatchSize   int32   `json:"batch_size"`
+}
+
+func (q *Queries) ListCustomersByIds(ctx context.Context, arg *ListCustomersByIdsParams) ([]*Customer, error) {
+	rows, err := q.db.Query(ctx, listCustomersByIds, arg.CustomerIds, arg.AfterID, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Customer
+	for rows.Next() {
+		var i Customer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Phone,
+			&i.FirstName,
+			&i.LastName,
+			&i.Location,
+			&i.PreferredProduct,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExistingCustomerIds = `-- name: ListExistingCustomerIds :many
+SELECT id FROM customers WHERE id = ANY($1::bigint[])
+`
+
+func (q *Queries) ListExistingCustomerIds(ctx context.Context, customerIds []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listExistingCustomerIds, customerIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateCustomer = `-- name: UpdateCustomer :one
+UPDATE customers
+SET
+    phone = $1,
+    first_name = $2,
+    last_name = $3,
+    location = $4,
+    preferred_product = $5,
+    updated_at = NOW()
+WHERE id = $6
+RETURNING id, phone, first_name, last_name, location, preferred_product, created_at, updated_at
+`
+
+type UpdateCustomerParams struct {
+	Phone            string      `json:"phone"`
+	FirstName        pgtype.Text `json:"first_name"`
+	LastName         pgtype.Text `json:"last_name"`
+	Location         pgtype.Text `json:"location"`
+	PreferredProduct pgtype.Text `json:"preferred_product"`
+	CustomerID       int64       `json:"customer_id"`
+}
+
+func (q *Queries) UpdateCustomer(ctx context.Context, arg *UpdateCustomerParams) (*Customer, error) {
+	row := q.db.QueryRow(ctx, updateCustomer,
+		arg.Phone,
+		arg.FirstName,
+		arg.LastName,
+		arg.Location,
+		arg.PreferredProduct,
+		arg.CustomerID,
+	)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.FirstName,
+		&i.LastName,
+		&i.Location,
+		&i.PreferredProduct,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const upsertCustomers = `-- name: UpsertCustomers :execrows
+INSERT INTO customers (phone, first_name, last_name, location, preferred_product)
+SELECT
+    t.phone,
+    NULLIF(t.first_name, ''),
+    NULLIF(t.last_name, ''),
+    NULLIF(t.location, ''),
+    NULLIF(t.preferred_product, '')
+FROM unnest(
+    $1::text[],
+    $2::text[],
+    $3::text[],
+    $4::text[],
+    $5::text[]
+) AS t(phone, first_name, last_name, location, preferred_product)
+ON CONFLICT (phone) DO UPDATE
+SET
+    first_name = COALESCE(EXCLUDED.first_name, customers.first_name),
+    last_name = COALESCE(EXCLUDED.last_name, customers.last_name),
+    location = COALESCE(EXCLUDED.location, customers.location),
+    preferred_product = COALESCE(EXCLUDED.preferred_product, customers.preferred_product),
+    updated_at = NOW()
+`
+
+type UpsertCustomersParams struct {
+	Phones            []string `json:"phones"`
+	FirstNames        []string `json:"first_names"`
+	LastNames         []string `json:"last_names"`
+	Locations         []string `json:"locations"`
+	PreferredProducts []string `json:"preferred_products"`
+}
+
+func (q *Queries) UpsertCustomers(ctx context.Context, arg *UpsertCustomersParams) (int64, error) {
+	result, err := q.db.Exec(ctx, upsertCustomers,
+		arg.Phones,
+		arg.FirstNames,
+		arg.LastNames,
+		arg.Locations,
+		arg.PreferredProducts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -9,14 +9,55 @@ import (
 )
 
 type Campaign struct {
-	ID           int64            `json:"id"`
-	Name         string           `json:"name"`
-	Channel      string           `json:"channel"`
-	Status       string           `json:"status"`
-	BaseTemplate string           `json:"base_template"`
-	ScheduledAt  pgtype.Timestamp `json:"scheduled_at"`
-	CreatedAt    pgtype.Timestamp `json:"created_at"`
-	UpdatedAt    pgtype.Timestamp `json:"updated_at"`
+	ID                int64              `json:"id"`
+	Name              string             `json:"name"`
+	Channel           string             `json:"channel"`
+	Status            string             `json:"status"`
+	BaseTemplate      string             `json:"base_template"`
+	ScheduledAt       pgtype.Timestamptz `json:"scheduled_at"`
+	CreatedAt         pgtype.Timestamp   `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp   `json:"updated_at"`
+	LenientTemplates  bool               `json:"lenient_templates"`
+	MaxSegments       pgtype.Int4        `json:"max_segments"`
+	ArchivedAt        pgtype.Timestamp   `json:"archived_at"`
+	PausedAt          pgtype.Timestamp   `json:"paused_at"`
+	CancelledAt       pgtype.Timestamp   `json:"cancelled_at"`
+	SegmentID         pgtype.Int8        `json:"segment_id"`
+	CustomerIds       []int64            `json:"customer_ids"`
+	Recurrence        pgtype.Text        `json:"recurrence"`
+	Timezone          string             `json:"timezone"`
+	RecurrenceEndedAt pgtype.Timestamp   `json:"recurrence_ended_at"`
+	ParentID          pgtype.Int8        `json:"parent_id"`
+}
+
+type CampaignDispatch struct {
+	ID               int64            `json:"id"`
+	CampaignID       int64            `json:"campaign_id"`
+	Status           string           `json:"status"`
+	SegmentID        pgtype.Int8      `json:"segment_id"`
+	CustomerIds      []int64          `json:"customer_ids"`
+	TotalCustomers   int32            `json:"total_customers"`
+	QueuedMessages   int32            `json:"queued_messages"`
+	LastCustomerID   int64            `json:"last_customer_id"`
+	LastError        pgtype.Text      `json:"last_error"`
+	StartedAt        pgtype.Timestamp `json:"started_at"`
+	FinishedAt       pgtype.Timestamp `json:"finished_at"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	Mode             string           `json:"mode"`
+	SkippedCustomers int32            `json:"skipped_customers"`
+	FailedCustomers  int32            `json:"failed_customers"`
+	ResendReason     pgtype.Text      `json:"resend_reason"`
+}
+
+type CampaignDispatchOutcome struct {
+	ID         int64            `json:"id"`
+	DispatchID int64            `json:"dispatch_id"`
+	CustomerID int64            `json:"customer_id"`
+	Outcome    string           `json:"outcome"`
+	Reason     pgtype.Text      `json:"reason"`
+	MessageID  pgtype.Int8      `json:"message_id"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
 }
 
 type Customer struct {
@@ -30,14 +71,101 @@ type Customer struct {
 	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
 }
 
+type CustomerImport struct {
+	ID            int64            `json:"id"`
+	Format        string           `json:"format"`
+	Status        string           `json:"status"`
+	ColumnMapping []byte           `json:"column_mapping"`
+	ProcessedRows int32            `json:"processed_rows"`
+	ImportedRows  int32            `json:"imported_rows"`
+	RejectedRows  int32            `json:"rejected_rows"`
+	LastError     pgtype.Text      `json:"last_error"`
+	CreatedAt     pgtype.Timestamp `json:"created_at"`
+	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+}
+
+type CustomerImportChunk struct {
+	ImportID int64  `json:"import_id"`
+	Seq      int32  `json:"seq"`
+	Data     []byte `json:"data"`
+}
+
+type CustomerImportKey struct {
+	IdempotencyKey string           `json:"idempotency_key"`
+	ImportID       int64            `json:"import_id"`
+	Fingerprint    string           `json:"fingerprint"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type CustomerImportRejection struct {
+	ID        int64  `json:"id"`
+	ImportID  int64  `json:"import_id"`
+	RowNumber int32  `json:"row_number"`
+	Raw       string `json:"raw"`
+	Reason    string `json:"reason"`
+}
+
+type IdempotencyKey struct {
+	ID             int64            `json:"id"`
+	Scope          string           `json:"scope"`
+	IdempotencyKey string           `json:"idempotency_key"`
+	Fingerprint    string           `json:"fingerprint"`
+	StatusCode     pgtype.Int4      `json:"status_code"`
+	ContentType    pgtype.Text      `json:"content_type"`
+	ResponseBody   []byte           `json:"response_body"`
+	LockedAt       pgtype.Timestamp `json:"locked_at"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type MessageEvent struct {
+	ID         int64            `json:"id"`
+	MessageID  int64            `json:"message_id"`
+	EventType  string           `json:"event_type"`
+	Status     string           `json:"status"`
+	Detail     []byte           `json:"detail"`
+	OccurredAt pgtype.Timestamp `json:"occurred_at"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+}
+
 type OutboundMessage struct {
-	ID              int64            `json:"id"`
-	CampaignID      int64            `json:"campaign_id"`
-	CustomerID      int64            `json:"customer_id"`
-	Status          string           `json:"status"`
-	RenderedContent string           `json:"rendered_content"`
-	LastError       pgtype.Text      `json:"last_error"`
-	RetryCount      int32            `json:"retry_count"`
-	CreatedAt       pgtype.Timestamp `json:"created_at"`
-	UpdatedAt       pgtype.Timestamp `json:"updated_at"`
+	ID                int64            `json:"id"`
+	CampaignID        int64            `json:"campaign_id"`
+	CustomerID        int64            `json:"customer_id"`
+	Status            string           `json:"status"`
+	RenderedContent   string           `json:"rendered_content"`
+	LastError         pgtype.Text      `json:"last_error"`
+	RetryCount        int32            `json:"retry_count"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+	Encoding          pgtype.Text      `json:"encoding"`
+	Segments          pgtype.Int4      `json:"segments"`
+	ResendReason      pgtype.Text      `json:"resend_reason"`
+	ProviderMessageID pgtype.Text      `json:"provider_message_id"`
+	TaskID            pgtype.Text      `json:"task_id"`
+}
+
+type Outbox struct {
+	ID           int64              `json:"id"`
+	TaskType     string             `json:"task_type"`
+	Queue        string             `json:"queue"`
+	Payload      []byte             `json:"payload"`
+	ProcessAt    pgtype.Timestamptz `json:"process_at"`
+	MaxRetry     pgtype.Int4        `json:"max_retry"`
+	Attempts     int32              `json:"attempts"`
+	LastError    pgtype.Text        `json:"last_error"`
+	DispatchedAt pgtype.Timestamp   `json:"dispatched_at"`
+	CreatedAt    pgtype.Timestamp   `json:"created_at"`
+}
+
+type Segment struct {
+	ID               int64            `json:"id"`
+	Name             string           `json:"name"`
+	Location         pgtype.Text      `json:"location"`
+	PreferredProduct pgtype.Text      `json:"preferred_product"`
+	PhonePrefix      pgtype.Text      `json:"phone_prefix"`
+	CreatedAfter     pgtype.Timestamp `json:"created_after"`
+	CreatedBefore    pgtype.Timestamp `json:"created_before"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
 }
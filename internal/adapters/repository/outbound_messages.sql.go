@@ -11,10 +11,49 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const cancelCampaignMessages = `-- name: CancelCampaignMessages :many
+UPDATE outbound_messages
+SET
+    status = 'cancelled',
+    updated_at = NOW()
+WHERE campaign_id = $1
+    AND status = 'pending'
+RETURNING id, task_id
+`
+
+type CancelCampaignMessagesRow struct {
+	ID     int64       `json:"id"`
+	TaskID pgtype.Text `json:"task_id"`
+}
+
+func (q *Queries) CancelCampaignMessages(ctx context.Context, campaignID int64) ([]*CancelCampaignMessagesRow, error) {
+	rows, err := q.db.Query(ctx, cancelCampaignMessages, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*CancelCampaignMessagesRow
+	for rows.Next() {
+		var i CancelCampaignMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createOutboundMessage = `-- name: CreateOutboundMessage :one
-INSERT INTO outbound_messages (campaign_id, customer_id, status, rendered_content, last_error, retry_count)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, campaign_id, customer_id, status, rendered_content, last_error, retry_count, created_at, updated_at
+INSERT INTO outbound_messages (campaign_id, customer_id, status, rendered_content, last_error, retry_count, encoding, segments, resend_reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (campaign_id, customer_id) WHERE resend_reason IS NULL AND status <> 'cancelled' DO NOTHING
+RETURNING id, campaign_id, customer_id, status, rendered_content, last_error, retry_count, created_at, updated_at, encoding, segments, resend_reason, provider_message_id, task_id
 `
 
 type CreateOutboundMessageParams struct {
@@ -24,6 +63,9 @@ type CreateOutboundMessageParams struct {
 	RenderedContent string      `json:"rendered_content"`
 	LastError       pgtype.Text `json:"last_error"`
 	RetryCount      int32       `json:"retry_count"`
+	Encoding        pgtype.Text `json:"encoding"`
+	Segments        pgtype.Int4 `json:"segments"`
+	ResendReason    pgtype.Text `json:"resend_reason"`
 }
 
 func (q *Queries) CreateOutboundMessage(ctx context.Context, arg *CreateOutboundMessageParams) (*OutboundMessage, error) {
@@ -34,7 +76,99 @@ func (q *Queries) CreateOutboundMessage(ctx context.Context, arg *CreateOutbound
 		arg.RenderedContent,
 		arg.LastError,
 		arg.RetryCount,
+		arg.Encoding,
+		arg.Segments,
+		arg.ResendReason,
+	)
+	var i OutboundMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CampaignID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RenderedContent,
+		&i.LastError,
+		&i.RetryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Encoding,
+		&i.Segments,
+		&i.ResendReason,
+		&i.ProviderMessageID,
+		&i.TaskID,
+	)
+	return &i, err
+}
+
+const deferOutboundMessage = `-- name: DeferOutboundMessage :one
+UPDATE outbound_messages
+SET
+    task_id = $1,
+    updated_at = NOW()
+WHERE id = $2
+    AND status = 'pending'
+    AND ($3::text IS NULL OR task_id IS NULL OR task_id = $3)
+RETURNING id
+`
+
+type DeferOutboundMessageParams struct {
+	NewTaskID pgtype.Text `json:"new_task_id"`
+	MessageID int64       `json:"message_id"`
+	TaskID    pgtype.Text `json:"task_id"`
+}
+
+// A message held back by quiet hours moves to the task that delivers it once
+// they end. It must still be pending and expect the task that defers it.
+func (q *Queries) DeferOutboundMessage(ctx context.Context, arg *DeferOutboundMessageParams) (int64, error) {
+	row := q.db.QueryRow(ctx, deferOutboundMessage, arg.NewTaskID, arg.MessageID, arg.TaskID)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getOutboundMessage = `-- name: GetOutboundMessage :one
+SELECT id, campaign_id, customer_id, status, rendered_content, last_error, retry_count, created_at, updated_at, encoding, segments, resend_reason, provider_message_id, task_id FROM outbound_messages WHERE id = $1
+`
+
+func (q *Queries) GetOutboundMessage(ctx context.Context, messageID int64) (*OutboundMessage, error) {
+	row := q.db.QueryRow(ctx, getOutboundMessage, messageID)
+	var i OutboundMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CampaignID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RenderedContent,
+		&i.LastError,
+		&i.RetryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Encoding,
+		&i.Segments,
+		&i.ResendReason,
+		&i.ProviderMessageID,
+		&i.TaskID,
 	)
+	return &i, err
+}
+
+const getOutboundMessageByProviderID = `-- name: GetOutboundMessageByProviderID :one
+SELECT om.id, om.campaign_id, om.customer_id, om.status, om.rendered_content, om.last_error, om.retry_count, om.created_at, om.updated_at, om.encoding, om.segments, om.resend_reason, om.provider_message_id, om.task_id
+FROM outbound_messages om
+JOIN campaigns c ON c.id = om.campaign_id
+WHERE om.provider_message_id = $1
+    AND c.channel = $2
+ORDER BY om.id DESC
+LIMIT 1
+`
+
+type GetOutboundMessageByProviderIDParams struct {
+	ProviderMessageID pgtype.Text `json:"provider_message_id"`
+	Channel           string      `json:"channel"`
+}
+
+func (q *Queries) GetOutboundMessageByProviderID(ctx context.Context, arg *GetOutboundMessageByProviderIDParams) (*OutboundMessage, error) {
+	row := q.db.QueryRow(ctx, getOutboundMessageByProviderID, arg.ProviderMessageID, arg.Channel)
 	var i OutboundMessage
 	err := row.Scan(
 		&i.ID,
@@ -46,6 +180,459 @@ func (q *Queries) CreateOutboundMessage(ctx context.Context, arg *CreateOutbound
 		&i.RetryCount,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Encoding,
+		&i.Segments,
+		&i.ResendReason,
+		&i.ProviderMessageID,
+		&i.TaskID,
 	)
 	return &i, err
 }
+
+const getOutboundMessageDetail = `-- name: GetOutboundMessageDetail :one
+SELECT
+    om.id, om.campaign_id, om.customer_id, om.status, om.rendered_content, om.last_error, om.retry_count, om.created_at, om.updated_at, om.encoding, om.segments, om.resend_reason, om.provider_message_id, om.task_id,
+    c.name AS campaign_name,
+    c.channel,
+    cu.phone,
+    cu.first_name,
+    cu.last_name
+FROM outbound_messages om
+JOIN campaigns c ON c.id = om.campaign_id
+JOIN customers cu ON cu.id = om.customer_id
+WHERE om.id = $1
+`
+
+type GetOutboundMessageDetailRow struct {
+	ID                int64            `json:"id"`
+	CampaignID        int64            `json:"campaign_id"`
+	CustomerID        int64            `json:"customer_id"`
+	Status            string           `json:"status"`
+	RenderedContent   string           `json:"rendered_content"`
+	LastError         pgtype.Text      `json:"last_error"`
+	RetryCount        int32            `json:"retry_count"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+	Encoding          pgtype.Text      `json:"encoding"`
+	Segments          pgtype.Int4      `json:"segments"`
+	ResendReason      pgtype.Text      `json:"resend_reason"`
+	ProviderMessageID pgtype.Text      `json:"provider_message_id"`
+	TaskID            pgtype.Text      `json:"task_id"`
+	CampaignName      string           `json:"campaign_name"`
+	Channel           string           `json:"channel"`
+	Phone             string           `json:"phone"`
+	FirstName         pgtype.Text      `json:"first_name"`
+	LastName          pgtype.Text      `json:"last_name"`
+}
+
+func (q *Queries) GetOutboundMessageDetail(ctx context.Context, messageID int64) (*GetOutboundMessageDetailRow, error) {
+	row := q.db.QueryRow(ctx, getOutboundMessageDetail, messageID)
+	var i GetOutboundMessageDetailRow
+	err := row.Scan(
+		&i.ID,
+		&i.CampaignID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RenderedContent,
+		&i.LastError,
+		&i.RetryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Encoding,
+		&i.Segments,
+		&i.ResendReason,
+		&i.ProviderMessageID,
+		&i.TaskID,
+		&i.CampaignName,
+		&i.Channel,
+		&i.Phone,
+		&i.FirstName,
+		&i.LastName,
+	)
+	return &i, err
+}
+
+const getOutboundMessageForDelivery = `-- name: GetOutboundMessageForDelivery :one
+SELECT
+    om.id, om.campaign_id, om.customer_id, om.status, om.rendered_content, om.last_error, om.retry_count, om.created_at, om.updated_at, om.encoding, om.segments, om.resend_reason, om.provider_message_id, om.task_id,
+    c.channel,
+    c.status AS campaign_status,
+    c.timezone,
+    cu.phone
+FROM outbound_messages om
+JOIN campaigns c ON c.id = om.campaign_id
+JOIN customers cu ON cu.id = om.customer_id
+WHERE om.id = $1
+`
+
+type GetOutboundMessageForDeliveryRow struct {
+	ID                int64            `json:"id"`
+	CampaignID        int64            `json:"campaign_id"`
+	CustomerID        int64            `json:"customer_id"`
+	Status            string           `json:"status"`
+	RenderedContent   string           `json:"rendered_content"`
+	LastError         pgtype.Text      `json:"last_error"`
+	RetryCount        int32            `json:"retry_count"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+	Encoding          pgtype.Text      `json:"encoding"`
+	Segments          pgtype.Int4      `json:"segments"`
+	ResendReason      pgtype.Text      `json:"resend_reason"`
+	ProviderMessageID pgtype.Text      `json:"provider_message_id"`
+	TaskID            pgtype.Text      `json:"task_id"`
+	Channel           string           `json:"channel"`
+	CampaignStatus    string           `json:"campaign_status"`
+	Timezone          string           `json:"timezone"`
+	Phone             string           `json:"phone"`
+}
+
+func (q *Queries) GetOutboundMessageForDelivery(ctx context.Context, messageID int64) (*GetOutboundMessageForDeliveryRow, error) {
+	row := q.db.QueryRow(ctx, getOutboundMessageForDelivery, messageID)
+	var i GetOutboundMessageForDeliveryRow
+	err := row.Scan(
+		&i.ID,
+		&i.CampaignID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RenderedContent,
+		&i.LastError,
+		&i.RetryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Encoding,
+		&i.Segments,
+		&i.ResendReason,
+		&i.ProviderMessageID,
+		&i.TaskID,
+		&i.Channel,
+		&i.CampaignStatus,
+		&i.Timezone,
+		&i.Phone,
+	)
+	return &i, err
+}
+
+const listCampaignMessages = `-- name: ListCampaignMessages :many
+SELECT
+    om.id,
+    om.customer_id,
+    cu.phone,
+    cu.first_name,
+    cu.last_name,
+    om.status,
+    om.last_error,
+    om.retry_count,
+    om.encoding,
+    om.segments,
+    om.resend_reason,
+    om.provider_message_id,
+    om.created_at,
+    om.updated_at
+FROM outbound_messages om
+JOIN customers cu ON cu.id = om.customer_id
+WHERE
+    om.campaign_id = $1
+    AND om.id > $2
+    AND (
+        cardinality($3::text[]) = 0
+        OR om.status = ANY($3::text[])
+    )
+    AND ($4::bigint IS NULL OR om.customer_id = $4)
+    AND ($5::timestamp IS NULL OR om.created_at >= $5)
+    AND ($6::timestamp IS NULL OR om.created_at < $6)
+    AND ($7::text IS NULL OR strpos(LOWER(om.last_error), LOWER($7)) > 0)
+ORDER BY om.id
+LIMIT $8
+`
+
+type ListCampaignMessagesParams struct {
+	CampaignID    int64            `json:"campaign_id"`
+	AfterID       int64            `json:"after_id"`
+	Statuses      []string         `json:"statuses"`
+	CustomerID    pgtype.Int8      `json:"customer_id"`
+	CreatedAfter  pgtype.Timestamp `json:"created_after"`
+	CreatedBefore pgtype.Timestamp `json:"created_before"`
+	Error         pgtype.Text      `json:"error"`
+	PageSize      int32            `json:"page_size"`
+}
+
+type ListCampaignMessagesRow struct {
+	ID                int64            `json:"id"`
+	CustomerID        int64            `json:"customer_id"`
+	Phone             string           `json:"phone"`
+	FirstName         pgtype.Text      `json:"first_name"`
+	LastName          pgtype.Text      `json:"last_name"`
+	Status            string           `json:"status"`
+	LastError         pgtype.Text      `json:"last_error"`
+	RetryCount        int32            `json:"retry_count"`
+	Encoding          pgtype.Text      `json:"encoding"`
+	Segments          pgtype.Int4      `json:"segments"`
+	ResendReason      pgtype.Text      `json:"resend_reason"`
+	ProviderMessageID pgtype.Text      `json:"provider_message_id"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+}
+
+func (q *Queries) ListCampaignMessages(ctx context.Context, arg *ListCampaignMessagesParams) ([]*ListCampaignMessagesRow, error) {
+	rows, err := q.db.Query(ctx, listCampaignMessages,
+		arg.CampaignID,
+		arg.AfterID,
+		arg.Statuses,
+		arg.CustomerID,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Error,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListCampaignMessagesRow
+	for rows.Next() {
+		var i ListCampaignMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.Phone,
+			&i.FirstName,
+			&i.LastName,
+			&i.Status,
+			&i.LastError,
+			&i.RetryCount,
+			&i.Encoding,
+			&i.Segments,
+			&i.ResendReason,
+			&i.ProviderMessageID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagedCustomerIds = `-- name: ListMessagedCustomerIds :many
+SELECT DISTINCT customer_id
+FROM outbound_messages
+WHERE campaign_id = $1
+    AND customer_id = ANY($2::bigint[])
+    AND resend_reason IS NULL
+    AND status <> 'cancelled'
+`
+
+type ListMessagedCustomerIdsParams struct {
+	CampaignID  int64   `json:"campaign_id"`
+	CustomerIds []int64 `json:"customer_ids"`
+}
+
+// The customers among customer_ids who already have a message for the campaign
+// that a send without resend would not repeat.
+func (q *Queries) ListMessagedCustomerIds(ctx context.Context, arg *ListMessagedCustomerIdsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listMessagedCustomerIds, arg.CampaignID, arg.CustomerIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var customer_id int64
+		if err := rows.Scan(&customer_id); err != nil {
+			return nil, err
+		}
+		items = append(items, customer_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingCampaignMessages = `-- name: ListPendingCampaignMessages :many
+SELECT id, customer_id, task_id
+FROM outbound_messages
+WHERE campaign_id = $1
+    AND status = 'pending'
+    AND id > $2
+ORDER BY id
+LIMIT $3
+FOR UPDATE
+`
+
+type ListPendingCampaignMessagesParams struct {
+	CampaignID int64 `json:"campaign_id"`
+	AfterID    int64 `json:"after_id"`
+	BatchSize  int32 `json:"batch_size"`
+}
+
+type ListPendingCampaignMessagesRow struct {
+	ID         int64       `json:"id"`
+	CustomerID int64       `json:"customer_id"`
+	TaskID     pgtype.Text `json:"task_id"`
+}
+
+func (q *Queries) ListPendingCampaignMessages(ctx context.Context, arg *ListPendingCampaignMessagesParams) ([]*ListPendingCampaignMessagesRow, error) {
+	rows, err := q.db.Query(ctx, listPendingCampaignMessages, arg.CampaignID, arg.AfterID, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListPendingCampaignMessagesRow
+	for rows.Next() {
+		var i ListPendingCampaignMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.TaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingCampaignTaskIds = `-- name: ListPendingCampaignTaskIds :many
+SELECT task_id::text
+FROM outbound_messages
+WHERE campaign_id = $1
+    AND status = 'pending'
+    AND task_id IS NOT NULL
+`
+
+func (q *Queries) ListPendingCampaignTaskIds(ctx context.Context, campaignID int64) ([]string, error) {
+	rows, err := q.db.Query(ctx, listPendingCampaignTaskIds, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var task_id string
+		if err := rows.Scan(&task_id); err != nil {
+			return nil, err
+		}
+		items = append(items, task_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setOutboundMessageTaskIds = `-- name: SetOutboundMessageTaskIds :exec
+UPDATE outbound_messages om
+SET
+    task_id = t.task_id,
+    updated_at = NOW()
+FROM unnest($1::bigint[], $2::text[]) AS t(message_id, task_id)
+WHERE om.id = t.message_id
+`
+
+type SetOutboundMessageTaskIdsParams struct {
+	MessageIds []int64  `json:"message_ids"`
+	TaskIds    []string `json:"task_ids"`
+}
+
+func (q *Queries) SetOutboundMessageTaskIds(ctx context.Context, arg *SetOutboundMessageTaskIdsParams) error {
+	_, err := q.db.Exec(ctx, setOutboundMessageTaskIds, arg.MessageIds, arg.TaskIds)
+	return err
+}
+
+const transitionOutboundMessage = `-- name: TransitionOutboundMessage :one
+UPDATE outbound_messages
+SET
+    status = $1,
+    last_error = COALESCE($2::text, last_error),
+    provider_message_id = COALESCE($3::text, provider_message_id),
+    retry_count = retry_count + CASE WHEN $1 = 'pending' OR status = 'failed' THEN 1 ELSE 0 END,
+    updated_at = NOW()
+WHERE id = $4
+    AND status = ANY($5::text[])
+    AND ($6::text IS NULL OR task_id IS NULL OR task_id = $6)
+    AND ($1 <> 'sending' OR status = 'pending' OR task_id = $6)
+    AND ($1 <> 'pending' OR status = 'failed' OR task_id = $6)
+RETURNING id, campaign_id, customer_id, status, rendered_content, last_error, retry_count, created_at, updated_at, encoding, segments, resend_reason, provider_message_id, task_id
+`
+
+type TransitionOutboundMessageParams struct {
+	ToStatus          string      `json:"to_status"`
+	LastError         pgtype.Text `json:"last_error"`
+	ProviderMessageID pgtype.Text `json:"provider_message_id"`
+	MessageID         int64       `json:"message_id"`
+	FromStatuses      []string    `json:"from_statuses"`
+	TaskID            pgtype.Text `json:"task_id"`
+}
+
+// A message is claimed again from sending or failed, or put back from sending
+// to pending, only by the task that holds it. A claim from failed is a retry.
+func (q *Queries) TransitionOutboundMessage(ctx context.Context, arg *TransitionOutboundMessageParams) (*OutboundMessage, error) {
+	row := q.db.QueryRow(ctx, transitionOutboundMessage,
+		arg.ToStatus,
+		arg.LastError,
+		arg.ProviderMessageID,
+		arg.MessageID,
+		arg.FromStatuses,
+		arg.TaskID,
+	)
+	var i OutboundMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CampaignID,
+		&i.CustomerID,
+		&i.Status,
+		&i.RenderedContent,
+		&i.LastError,
+		&i.RetryCount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Encoding,
+		&i.Segments,
+		&i.ResendReason,
+		&i.ProviderMessageID,
+		&i.TaskID,
+	)
+	return &i, err
+}
+
+const updateOutboundMessageContents = `-- name: UpdateOutboundMessageContents :exec
+UPDATE outbound_messages om
+SET
+    rendered_content = t.rendered_content,
+    encoding = NULLIF(t.encoding, ''),
+    segments = NULLIF(t.segments, 0),
+    updated_at = NOW()
+FROM unnest(
+    $1::bigint[],
+    $2::text[],
+    $3::text[],
+    $4::int[]
+) AS t(message_id, rendered_content, encoding, segments)
+WHERE om.id = t.message_id
+    AND om.status = 'pending'
+`
+
+type UpdateOutboundMessageContentsParams struct {
+	MessageIds       []int64  `json:"message_ids"`
+	RenderedContents []string `json:"rendered_contents"`
+	Encodings        []string `json:"encodings"`
+	Segments         []int32  `json:"segments"`
+}
+
+func (q *Queries) UpdateOutboundMessageContents(ctx context.Context, arg *UpdateOutboundMessageContentsParams) error {
+	_, err := q.db.Exec(ctx, updateOutboundMessageContents,
+		arg.MessageIds,
+		arg.RenderedContents,
+		arg.Encodings,
+		arg.Segments,
+	)
+	return err
+}
@@ -2,17 +2,24 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"focus-dev-challenge/internal/config"
+	"focus-dev-challenge/internal/core/domain"
+	"io"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mwinyimoha/commons/pkg/errors"
 )
 
 type Repository struct {
 	*Queries
-	db        *pgx.Conn
+	db        *pgxpool.Pool
 	dbTimeout time.Duration // seconds
 }
 
@@ -21,23 +28,37 @@ func NewRepository(cfg *config.Config) (*Repository, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
-	conn, err := pgx.Connect(ctx, cfg.DatabaseURL)
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
 		return nil, err
 	}
 
+	poolConfig.MaxConns = int32(cfg.DBMaxConns)
+	poolConfig.MinConns = int32(cfg.DBMinConns)
+	poolConfig.MaxConnLifetime = time.Duration(cfg.DBMaxConnLifetime) * time.Second
+	poolConfig.MaxConnIdleTime = time.Duration(cfg.DBMaxConnIdleTime) * time.Second
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	return &Repository{
-		db:        conn,
-		Queries:   New(conn),
+		db:        pool,
+		Queries:   New(pool),
 		dbTimeout: defaultTimeout,
 	}, nil
 }
 
+// Close waits for checked-out connections to be released and closes the pool.
 func (r *Repository) Close() error {
-	ctx, cancel := r.getContext()
-	defer cancel()
-
-	return r.db.Close(ctx)
+	r.db.Close()
+	return nil
 }
 
 func (r *Repository) ExecTx(ctx context.Context, fn func(*Queries) error) error {
@@ -60,8 +81,8 @@ func (r *Repository) ExecTx(ctx context.Context, fn func(*Queries) error) error
 	return tx.Commit(ctx)
 }
 
-func (r *Repository) AddCampaign(arg *CreateCampaignParams) (*Campaign, error) {
-	ctx, cancel := r.getContext()
+func (r *Repository) AddCampaign(ctx context.Context, arg *CreateCampaignParams) (*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
 	defer cancel()
 
 	record, err := r.Queries.CreateCampaign(ctx, arg)
@@ -72,8 +93,8 @@ func (r *Repository) AddCampaign(arg *CreateCampaignParams) (*Campaign, error) {
 	return record, nil
 }
 
-func (r *Repository) ListCampaigns(arg *ListCampaignsParams) ([]*ListCampaignsRow, error) {
-	ctx, cancel := r.getContext()
+func (r *Repository) ListCampaigns(ctx context.Context, arg *ListCampaignsParams) ([]*ListCampaignsRow, error) {
+	ctx, cancel := r.getContext(ctx)
 	defer cancel()
 
 	records, err := r.Queries.ListCampaigns(ctx, arg)
@@ -84,32 +105,651 @@ func (r *Repository) ListCampaigns(arg *ListCampaignsParams) ([]*ListCampaignsRo
 	return records, nil
 }
 
-func (r *Repository) GetCampaign(ID int64) (*GetCampaignRow, error) {
-	ctx, cancel := r.getContext()
+func (r *Repository) ListCampaignsAfter(ctx context.Context, arg *ListCampaignsAfterParams) ([]*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCampaignsAfter(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGNS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) ListCampaignsBefore(ctx context.Context, arg *ListCampaignsBeforeParams) ([]*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCampaignsBefore(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGNS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) CountCampaigns(ctx context.Context, arg *CountCampaignsParams) (int64, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	count, err := r.Queries.CountCampaigns(ctx, arg)
+	if err != nil {
+		return 0, errors.WrapError(err, errors.Internal, "COUNT_CAMPAIGNS_ERROR")
+	}
+
+	return count, nil
+}
+
+func (r *Repository) GetCampaign(ctx context.Context, ID int64) (*GetCampaignRow, error) {
+	ctx, cancel := r.getContext(ctx)
 	defer cancel()
 
 	record, err := r.Queries.GetCampaign(ctx, ID)
 	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CAMPAIGN_NOT_FOUND")
+		}
+
 		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGN_ERROR")
 	}
 
 	return record, nil
 }
 
-func (r *Repository) GetCustomer(ID int64) (*Customer, error) {
-	ctx, cancel := r.getContext()
+func (r *Repository) GetCampaignStatus(ctx context.Context, ID int64) (string, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	status, err := r.Queries.GetCampaignStatus(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return "", errors.WrapError(err, errors.NotFound, "CAMPAIGN_NOT_FOUND")
+		}
+
+		return "", errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGN_ERROR")
+	}
+
+	return status, nil
+}
+
+// TransitionCampaign moves a campaign to arg.ToStatus only if its current status
+// is a legal source for that transition. Refused transitions return an error
+// wrapping domain.ErrIllegalTransition.
+func (r *Repository) TransitionCampaign(ctx context.Context, arg *TransitionCampaignParams) (*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	arg.FromStatuses = domain.CampaignTransitionSources(arg.ToStatus)
+	record, err := r.Queries.TransitionCampaign(ctx, arg)
+	if err == nil {
+		return record, nil
+	}
+
+	if !stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	current, err := r.Queries.GetCampaign(ctx, arg.CampaignID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CAMPAIGN_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGN_ERROR")
+	}
+
+	return nil, fmt.Errorf("%w: campaign %d cannot move from %s to %s", domain.ErrIllegalTransition, current.ID, current.Status, arg.ToStatus)
+}
+
+// FinalizeCampaign rolls a sending campaign up to sent or failed once none of
+// its messages are pending or in flight and no dispatch is still fanning out.
+// It returns a nil campaign when the campaign is not ready to be finalized.
+func (r *Repository) FinalizeCampaign(ctx context.Context, ID int64) (*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.FinalizeCampaign(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FINALIZE_CAMPAIGN_ERROR")
+	}
+
+	return record, nil
+}
+
+// StartScheduledCampaign moves a scheduled campaign to sending once now has
+// reached its scheduled time. It returns a nil campaign when the campaign is
+// not scheduled or not due yet.
+func (r *Repository) StartScheduledCampaign(ctx context.Context, arg *StartScheduledCampaignParams) (*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.StartScheduledCampaign(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	return record, nil
+}
+
+// AdvanceCampaignRecurrence moves a recurring campaign on to its next
+// occurrence. It returns a nil campaign when the campaign is no longer due at
+// the expected time or not in one of the given statuses.
+func (r *Repository) AdvanceCampaignRecurrence(ctx context.Context, arg *AdvanceCampaignRecurrenceParams) (*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.AdvanceCampaignRecurrence(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	return record, nil
+}
+
+// EndCampaignRecurrence stops a recurring campaign. It returns a nil campaign
+// when the campaign does not recur or is not in one of the given statuses.
+func (r *Repository) EndCampaignRecurrence(ctx context.Context, arg *EndCampaignRecurrenceParams) (*Campaign, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.EndCampaignRecurrence(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListCampaignRuns(ctx context.Context, arg *ListCampaignRunsParams) ([]*ListCampaignRunsRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCampaignRuns(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGN_RUNS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) ListExistingCustomerIds(ctx context.Context, IDs []int64) ([]int64, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListExistingCustomerIds(ctx, IDs)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMERS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) ListCustomersByIds(ctx context.Context, arg *ListCustomersByIdsParams) ([]*Customer, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCustomersByIds(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMERS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) ListMessagedCustomerIds(ctx context.Context, arg *ListMessagedCustomerIdsParams) ([]int64, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListMessagedCustomerIds(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMERS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) GetCampaignDispatch(ctx context.Context, ID int64) (*CampaignDispatch, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetCampaignDispatch(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CAMPAIGN_DISPATCH_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGN_DISPATCH_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) StartCampaignDispatch(ctx context.Context, ID int64) (*CampaignDispatch, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.StartCampaignDispatch(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CAMPAIGN_DISPATCH_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_DISPATCH_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) FinishCampaignDispatch(ctx context.Context, arg *FinishCampaignDispatchParams) (*CampaignDispatch, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.FinishCampaignDispatch(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_DISPATCH_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListCampaignDispatchOutcomes(ctx context.Context, arg *ListCampaignDispatchOutcomesParams) ([]*ListCampaignDispatchOutcomesRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCampaignDispatchOutcomes(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CAMPAIGN_DISPATCH_OUTCOMES_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) GetCustomer(ctx context.Context, ID int64) (*Customer, error) {
+	ctx, cancel := r.getContext(ctx)
 	defer cancel()
 
 	record, err := r.Queries.GetCustomerById(ctx, ID)
 	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CUSTOMER_NOT_FOUND")
+		}
+
 		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMER_ERROR")
 	}
 
 	return record, nil
 }
 
-func (r *Repository) CreateOutboundMessage(arg *CreateOutboundMessageParams) (*OutboundMessage, error) {
-	ctx, cancel := r.getContext()
+func (r *Repository) AddCustomer(ctx context.Context, arg *CreateCustomerParams) (*Customer, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.CreateCustomer(ctx, arg)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.WrapError(err, errors.AlreadyExists, "CUSTOMER_PHONE_EXISTS")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_CUSTOMER_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListCustomers(ctx context.Context, arg *ListCustomersParams) ([]*ListCustomersRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCustomers(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMERS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) UpdateCustomer(ctx context.Context, arg *UpdateCustomerParams) (*Customer, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.UpdateCustomer(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CUSTOMER_NOT_FOUND")
+		}
+
+		if isUniqueViolation(err) {
+			return nil, errors.WrapError(err, errors.AlreadyExists, "CUSTOMER_PHONE_EXISTS")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_CUSTOMER_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) DeleteCustomer(ctx context.Context, ID int64) error {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	rows, err := r.Queries.DeleteCustomer(ctx, ID)
+	if err != nil {
+		return errors.WrapError(err, errors.Internal, "DELETE_CUSTOMER_ERROR")
+	}
+
+	if rows == 0 {
+		return errors.WrapError(pgx.ErrNoRows, errors.NotFound, "CUSTOMER_NOT_FOUND")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetCustomerImport(ctx context.Context, ID int64) (*CustomerImport, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetCustomerImport(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CUSTOMER_IMPORT_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMER_IMPORT_ERROR")
+	}
+
+	return record, nil
+}
+
+// GetCustomerImportKey returns the claim an import upload made on an
+// Idempotency-Key.
+func (r *Repository) GetCustomerImportKey(ctx context.Context, key string) (*CustomerImportKey, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetCustomerImportKey(ctx, key)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CUSTOMER_IMPORT_KEY_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMER_IMPORT_KEY_ERROR")
+	}
+
+	return record, nil
+}
+
+// OpenCustomerImportSource returns a reader over the uploaded file of an
+// import. It loads the file one stored chunk at a time.
+func (r *Repository) OpenCustomerImportSource(ctx context.Context, ID int64) io.Reader {
+	return &importSourceReader{ctx: ctx, r: r, importID: ID}
+}
+
+type importSourceReader struct {
+	ctx      context.Context
+	r        *Repository
+	importID int64
+	seq      int32
+	chunk    []byte
+	done     bool
+}
+
+func (sr *importSourceReader) Read(p []byte) (int, error) {
+	for len(sr.chunk) == 0 {
+		if sr.done {
+			return 0, io.EOF
+		}
+
+		if err := sr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.chunk)
+	sr.chunk = sr.chunk[n:]
+	return n, nil
+}
+
+// next loads the next chunk. The first one is always there, so its absence
+// means the import does not exist.
+func (sr *importSourceReader) next() error {
+	ctx, cancel := sr.r.getContext(sr.ctx)
+	defer cancel()
+
+	data, err := sr.r.Queries.GetCustomerImportChunk(ctx, &GetCustomerImportChunkParams{
+		ImportID: sr.importID,
+		Seq:      sr.seq,
+	})
+	switch {
+	case stderrors.Is(err, pgx.ErrNoRows) && sr.seq > 0:
+		sr.done = true
+		return nil
+	case stderrors.Is(err, pgx.ErrNoRows):
+		return errors.WrapError(err, errors.NotFound, "CUSTOMER_IMPORT_NOT_FOUND")
+	case err != nil:
+		return errors.WrapError(err, errors.Internal, "FETCH_CUSTOMER_IMPORT_ERROR")
+	}
+
+	sr.seq++
+	sr.chunk = data
+	return nil
+}
+
+// StartCustomerImport marks an import as processing and clears the results of
+// any earlier attempt so a retried job starts from a clean slate.
+func (r *Repository) StartCustomerImport(ctx context.Context, ID int64) (*CustomerImport, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	var record *CustomerImport
+	err := r.ExecTx(ctx, func(q *Queries) error {
+		if err := q.DeleteCustomerImportRejections(ctx, ID); err != nil {
+			return err
+		}
+
+		var err error
+		record, err = q.StartCustomerImport(ctx, ID)
+		return err
+	})
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "CUSTOMER_IMPORT_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CUSTOMER_IMPORT_ERROR")
+	}
+
+	return record, nil
+}
+
+// SaveCustomerImportBatch upserts a batch of customers, stores the rows rejected
+// alongside them and advances the import counters in a single transaction.
+func (r *Repository) SaveCustomerImportBatch(
+	ctx context.Context,
+	customers *UpsertCustomersParams,
+	rejections *CreateCustomerImportRejectionsParams,
+	progress *RecordCustomerImportProgressParams,
+) (*CustomerImport, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	var record *CustomerImport
+	err := r.ExecTx(ctx, func(q *Queries) error {
+		if len(customers.Phones) > 0 {
+			if _, err := q.UpsertCustomers(ctx, customers); err != nil {
+				return err
+			}
+		}
+
+		if len(rejections.RowNumbers) > 0 {
+			if err := q.CreateCustomerImportRejections(ctx, rejections); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		record, err = q.RecordCustomerImportProgress(ctx, progress)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_CUSTOMER_IMPORT_BATCH_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) FinishCustomerImport(ctx context.Context, arg *FinishCustomerImportParams) (*CustomerImport, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.FinishCustomerImport(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CUSTOMER_IMPORT_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListCustomerImportRejections(ctx context.Context, ID int64) ([]*CustomerImportRejection, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCustomerImportRejections(ctx, ID)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_CUSTOMER_IMPORT_REJECTIONS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) AddSegment(ctx context.Context, arg *CreateSegmentParams) (*Segment, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.CreateSegment(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_SEGMENT_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListSegments(ctx context.Context, arg *ListSegmentsParams) ([]*ListSegmentsRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListSegments(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_SEGMENTS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) GetSegment(ctx context.Context, ID int64) (*Segment, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetSegment(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "SEGMENT_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_SEGMENT_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) UpdateSegment(ctx context.Context, arg *UpdateSegmentParams) (*Segment, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.UpdateSegment(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "SEGMENT_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_SEGMENT_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) DeleteSegment(ctx context.Context, ID int64) error {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	rows, err := r.Queries.DeleteSegment(ctx, ID)
+	if err != nil {
+		return errors.WrapError(err, errors.Internal, "DELETE_SEGMENT_ERROR")
+	}
+
+	if rows == 0 {
+		return errors.WrapError(pgx.ErrNoRows, errors.NotFound, "SEGMENT_NOT_FOUND")
+	}
+
+	return nil
+}
+
+func (r *Repository) CountSegmentCustomers(ctx context.Context, ID int64) (int64, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	count, err := r.Queries.CountSegmentCustomers(ctx, ID)
+	if err != nil {
+		return 0, errors.WrapError(err, errors.Internal, "COUNT_SEGMENT_CUSTOMERS_ERROR")
+	}
+
+	return count, nil
+}
+
+func (r *Repository) ListSegmentCustomers(ctx context.Context, arg *ListSegmentCustomersParams) ([]*Customer, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListSegmentCustomers(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_SEGMENT_CUSTOMERS_ERROR")
+	}
+
+	return records, nil
+}
+
+// SampleSegmentCustomers returns a uniform random sample of the customers a
+// segment matches.
+func (r *Repository) SampleSegmentCustomers(ctx context.Context, arg *SampleSegmentCustomersParams) ([]*Customer, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.SampleSegmentCustomers(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_SEGMENT_CUSTOMERS_ERROR")
+	}
+
+	return records, nil
+}
+
+func (r *Repository) CreateOutboundMessage(ctx context.Context, arg *CreateOutboundMessageParams) (*OutboundMessage, error) {
+	ctx, cancel := r.getContext(ctx)
 	defer cancel()
 
 	record, err := r.Queries.CreateOutboundMessage(ctx, arg)
@@ -120,6 +760,300 @@ func (r *Repository) CreateOutboundMessage(arg *CreateOutboundMessageParams) (*O
 	return record, nil
 }
 
-func (r *Repository) getContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), r.dbTimeout)
+func (r *Repository) GetOutboundMessageForDelivery(ctx context.Context, ID int64) (*GetOutboundMessageForDeliveryRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetOutboundMessageForDelivery(ctx, ID)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) GetOutboundMessage(ctx context.Context, ID int64) (*OutboundMessage, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetOutboundMessage(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "OUTBOUND_MESSAGE_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) GetOutboundMessageDetail(ctx context.Context, ID int64) (*GetOutboundMessageDetailRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetOutboundMessageDetail(ctx, ID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "OUTBOUND_MESSAGE_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	return record, nil
+}
+
+// GetOutboundMessageByProviderID finds the message a gateway refers to by its
+// own ID. Provider IDs are only unique per channel.
+func (r *Repository) GetOutboundMessageByProviderID(ctx context.Context, arg *GetOutboundMessageByProviderIDParams) (*OutboundMessage, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetOutboundMessageByProviderID(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "OUTBOUND_MESSAGE_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListCampaignMessages(ctx context.Context, arg *ListCampaignMessagesParams) ([]*ListCampaignMessagesRow, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListCampaignMessages(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_OUTBOUND_MESSAGES_ERROR")
+	}
+
+	return records, nil
+}
+
+// statusChangeDetail is stored with the status_changed event of a transition.
+// The status the message left is the one of the event before it.
+type statusChangeDetail struct {
+	LastError         string `json:"last_error,omitempty"`
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	RetryCount        int32  `json:"retry_count"`
+}
+
+// TransitionOutboundMessage moves a message to arg.ToStatus only if its current
+// status is a legal source for that transition, and appends the change to the
+// message's event history in the same transaction. Refused transitions return
+// an error wrapping domain.ErrIllegalTransition.
+func (r *Repository) TransitionOutboundMessage(ctx context.Context, arg *TransitionOutboundMessageParams) (*OutboundMessage, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	arg.FromStatuses = domain.MessageTransitionSources(arg.ToStatus)
+
+	var record *OutboundMessage
+	err := r.ExecTx(ctx, func(q *Queries) error {
+		var err error
+		record, err = q.TransitionOutboundMessage(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		detail, err := json.Marshal(statusChangeDetail{
+			LastError:         arg.LastError.String,
+			ProviderMessageID: arg.ProviderMessageID.String,
+			RetryCount:        record.RetryCount,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateMessageEvent(ctx, &CreateMessageEventParams{
+			MessageID: record.ID,
+			EventType: domain.MessageEventStatusChanged,
+			Status:    record.Status,
+			Detail:    detail,
+		})
+		return err
+	})
+	if err == nil {
+		return record, nil
+	}
+
+	if !stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	current, err := r.Queries.GetOutboundMessage(ctx, arg.MessageID)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.WrapError(err, errors.NotFound, "OUTBOUND_MESSAGE_NOT_FOUND")
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	return nil, fmt.Errorf("%w: message %d cannot move from %s to %s", domain.ErrIllegalTransition, current.ID, current.Status, arg.ToStatus)
+}
+
+func (r *Repository) SetOutboundMessageTaskIds(ctx context.Context, arg *SetOutboundMessageTaskIdsParams) error {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	if err := r.Queries.SetOutboundMessageTaskIds(ctx, arg); err != nil {
+		return errors.WrapError(err, errors.Internal, "UPDATE_OUTBOUND_MESSAGE_ERROR")
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateMessageEvent(ctx context.Context, arg *CreateMessageEventParams) (*MessageEvent, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.CreateMessageEvent(ctx, arg)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_MESSAGE_EVENT_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) ListMessageEvents(ctx context.Context, messageID int64) ([]*MessageEvent, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	records, err := r.Queries.ListMessageEvents(ctx, messageID)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_MESSAGE_EVENTS_ERROR")
+	}
+
+	return records, nil
+}
+
+// DispatchOutbox claims up to batchSize undispatched outbox entries and hands
+// each to publish. Entries that publish successfully are marked dispatched;
+// failures are recorded and retried on a later pass. Rows stay locked until the
+// transaction commits, so concurrent relays skip over each other's batches.
+func (r *Repository) DispatchOutbox(ctx context.Context, batchSize int32, publish func(*Outbox) error) (int, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	dispatched := 0
+	err := r.ExecTx(ctx, func(q *Queries) error {
+		entries, err := q.ClaimOutboxEntries(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if pubErr := publish(entry); pubErr != nil {
+				arg := RecordOutboxFailureParams{
+					LastError: pgtype.Text{String: pubErr.Error(), Valid: true},
+					EntryID:   entry.ID,
+				}
+				if err := q.RecordOutboxFailure(ctx, &arg); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := q.MarkOutboxDispatched(ctx, entry.ID); err != nil {
+				return err
+			}
+			dispatched++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, errors.WrapError(err, errors.Internal, "DISPATCH_OUTBOX_ERROR")
+	}
+
+	return dispatched, nil
+}
+
+// idempotencyPurgeBatch bounds how many expired idempotency keys each new claim
+// deletes, which keeps the table from growing without a separate sweeper.
+const idempotencyPurgeBatch = 100
+
+// ClaimIdempotencyKey reserves a key for the request about to run. Expired keys
+// and keys abandoned mid-request are taken over. It returns nil when the key is
+// held by a live record, which the caller then reads with GetIdempotencyKey.
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, arg *ClaimIdempotencyKeyParams) (*IdempotencyKey, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	var record *IdempotencyKey
+	err := r.ExecTx(ctx, func(q *Queries) error {
+		if err := q.PurgeIdempotencyKeys(ctx, idempotencyPurgeBatch); err != nil {
+			return err
+		}
+
+		var err error
+		record, err = q.ClaimIdempotencyKey(ctx, arg)
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			record = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "CLAIM_IDEMPOTENCY_KEY_ERROR")
+	}
+
+	return record, nil
+}
+
+// GetIdempotencyKey returns nil when the key is not held, e.g. because the
+// request that claimed it failed and released it in the meantime.
+func (r *Repository) GetIdempotencyKey(ctx context.Context, arg *GetIdempotencyKeyParams) (*IdempotencyKey, error) {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	record, err := r.Queries.GetIdempotencyKey(ctx, arg)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, errors.WrapError(err, errors.Internal, "FETCH_IDEMPOTENCY_KEY_ERROR")
+	}
+
+	return record, nil
+}
+
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, arg *SaveIdempotentResponseParams) error {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	if err := r.Queries.SaveIdempotentResponse(ctx, arg); err != nil {
+		return errors.WrapError(err, errors.Internal, "SAVE_IDEMPOTENT_RESPONSE_ERROR")
+	}
+
+	return nil
+}
+
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, ID int64) error {
+	ctx, cancel := r.getContext(ctx)
+	defer cancel()
+
+	if err := r.Queries.ReleaseIdempotencyKey(ctx, ID); err != nil {
+		return errors.WrapError(err, errors.Internal, "RELEASE_IDEMPOTENCY_KEY_ERROR")
+	}
+
+	return nil
+}
+
+// getContext bounds a repository call by the configured query timeout while
+// keeping the caller's cancellation.
+func (r *Repository) getContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.dbTimeout)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return stderrors.As(err, &pgErr) && pgErr.Code == "23505"
 }
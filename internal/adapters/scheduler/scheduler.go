@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"focus-dev-challenge/internal/config"
+	"focus-dev-challenge/internal/core/ports"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Scheduler starts scheduled campaigns once their time comes. Every replica
+// polls, but a pass only runs on the one holding the database lock, so several
+// schedulers can run side by side without starting a campaign twice.
+type Scheduler struct {
+	service   ports.CampaignScheduler
+	logger    *zap.Logger
+	interval  time.Duration
+	batchSize int32
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewScheduler(cfg *config.Config, svc ports.CampaignScheduler, logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		service:   svc,
+		logger:    logger,
+		interval:  time.Duration(cfg.SchedulerInterval) * time.Millisecond,
+		batchSize: int32(cfg.SchedulerBatchSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start polls for due campaigns in the background until Stop is called. Full
+// batches are followed immediately by another pass.
+func (s *Scheduler) Start() error {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			for s.poll() == int(s.batchSize) {
+				select {
+				case <-s.stop:
+					return
+				default:
+				}
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop waits for the pass in flight to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// poll runs a single pass and returns the number of campaigns it started.
+func (s *Scheduler) poll() int {
+	n, err := s.service.DispatchDueCampaigns(context.Background(), s.batchSize)
+	if err != nil {
+		s.logger.Error("could not dispatch due campaigns", zap.Error(err))
+		return 0
+	}
+
+	if n > 0 {
+		s.logger.Info("started scheduled campaigns", zap.Int("count", n))
+	}
+
+	return n
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeService struct {
+	started []int
+	err     error
+	limits  []int32
+}
+
+func (f *fakeService) DispatchDueCampaigns(_ context.Context, limit int32) (int, error) {
+	f.limits = append(f.limits, limit)
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	n := f.started[0]
+	f.started = f.started[1:]
+	return n, nil
+}
+
+func TestPoll(t *testing.T) {
+	svc := &fakeService{started: []int{2}}
+	s := &Scheduler{service: svc, logger: zap.NewNop(), batchSize: 10}
+
+	assert.Equal(t, 2, s.poll())
+	assert.Equal(t, []int32{10}, svc.limits)
+
+	svc.err = errors.New("database unavailable")
+	assert.Equal(t, 0, s.poll(), "a failed pass waits for the next tick")
+}
@@ -0,0 +1,65 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"focus-dev-challenge/internal/core/domain"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSender appends every message to a newline delimited JSON file instead of
+// calling a gateway. It lets local and test environments run the full delivery
+// pipeline without provider credentials.
+type FileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+type fileRecord struct {
+	*domain.Message
+	ProviderMessageID string    `json:"provider_message_id"`
+	SentAt            time.Time `json:"sent_at"`
+}
+
+func NewFileSender(path string) (*FileSender, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileSender{path: path}, nil
+}
+
+func (fs *FileSender) Send(ctx context.Context, msg *domain.Message) (*domain.DeliveryResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	record := fileRecord{
+		Message:           msg,
+		ProviderMessageID: fmt.Sprintf("file-%d-%d", msg.ID, time.Now().UnixNano()),
+		SentAt:            time.Now().UTC(),
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+
+	return &domain.DeliveryResult{ProviderMessageID: record.ProviderMessageID}, nil
+}
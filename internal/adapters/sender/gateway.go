@@ -0,0 +1,51 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// postJSON sends body to url and decodes a successful response into out.
+// Non 2xx responses are returned as errors carrying the response body so the
+// worker can persist it as the message's last_error.
+func postJSON(ctx context.Context, client *http.Client, url, token string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("gateway responded with status %d: %s", res.StatusCode, bytes.TrimSpace(raw))
+	}
+
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,49 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"focus-dev-challenge/internal/config"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/ports"
+	"net/http"
+	"time"
+)
+
+// Sender routes a message to the adapter registered for its channel.
+type Sender struct {
+	channels map[string]ports.MessageSender
+}
+
+func NewSender(cfg *config.Config) (*Sender, error) {
+	channels := make(map[string]ports.MessageSender)
+
+	switch cfg.SenderDriver {
+	case "file":
+		fs, err := NewFileSender(cfg.SenderOutputFile)
+		if err != nil {
+			return nil, err
+		}
+
+		channels["sms"] = fs
+		channels["whatsapp"] = fs
+	case "gateway":
+		client := &http.Client{Timeout: time.Duration(cfg.DefaultTimeout) * time.Second}
+
+		channels["sms"] = NewSMSGateway(client, cfg.SMSGatewayURL, cfg.SMSGatewayToken)
+		channels["whatsapp"] = NewWhatsAppGateway(client, cfg.WhatsAppGatewayURL, cfg.WhatsAppGatewayToken)
+	default:
+		return nil, fmt.Errorf("unsupported sender driver: %s", cfg.SenderDriver)
+	}
+
+	return &Sender{channels: channels}, nil
+}
+
+func (s *Sender) Send(ctx context.Context, msg *domain.Message) (*domain.DeliveryResult, error) {
+	adapter, ok := s.channels[msg.Channel]
+	if !ok {
+		return nil, fmt.Errorf("no sender registered for channel: %s", msg.Channel)
+	}
+
+	return adapter.Send(ctx, msg)
+}
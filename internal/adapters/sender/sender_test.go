@@ -0,0 +1,84 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/ports"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSender_AppendsRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "messages.ndjson")
+
+	fs, err := NewFileSender(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	msg := &domain.Message{ID: 7, Channel: "sms", Recipient: "+254700111222", Content: "Hi Alice"}
+	result, err := fs.Send(context.Background(), msg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotEmpty(t, result.ProviderMessageID)
+
+	data, err := os.ReadFile(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, "Hi Alice", record["content"])
+	assert.Equal(t, result.ProviderMessageID, record["provider_message_id"])
+}
+
+func TestSMSGateway_Send(t *testing.T) {
+	var received smsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"message_id":"abc-123"}`))
+	}))
+	defer srv.Close()
+
+	gw := NewSMSGateway(srv.Client(), srv.URL, "secret")
+	result, err := gw.Send(context.Background(), &domain.Message{ID: 1, Recipient: "+254712832088", Content: "Hello"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, "abc-123", result.ProviderMessageID)
+	assert.Equal(t, "+254712832088", received.To)
+	assert.Equal(t, "1", received.Reference)
+}
+
+func TestSMSGateway_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`upstream unavailable`))
+	}))
+	defer srv.Close()
+
+	gw := NewSMSGateway(srv.Client(), srv.URL, "")
+	_, err := gw.Send(context.Background(), &domain.Message{ID: 1})
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), "502")
+	assert.Contains(t, err.Error(), "upstream unavailable")
+}
+
+func TestSender_UnknownChannel(t *testing.T) {
+	s := &Sender{channels: map[string]ports.MessageSender{}}
+
+	_, err := s.Send(context.Background(), &domain.Message{ID: 1, Channel: "email"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"focus-dev-challenge/internal/core/domain"
+	"net/http"
+	"strconv"
+)
+
+type SMSGateway struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+type smsRequest struct {
+	To        string `json:"to"`
+	Message   string `json:"message"`
+	Reference string `json:"reference"`
+}
+
+type smsResponse struct {
+	MessageID string `json:"message_id"`
+}
+
+func NewSMSGateway(client *http.Client, url, token string) *SMSGateway {
+	return &SMSGateway{
+		client: client,
+		url:    url,
+		token:  token,
+	}
+}
+
+func (g *SMSGateway) Send(ctx context.Context, msg *domain.Message) (*domain.DeliveryResult, error) {
+	body := smsRequest{
+		To:        msg.Recipient,
+		Message:   msg.Content,
+		Reference: strconv.FormatInt(msg.ID, 10),
+	}
+
+	var res smsResponse
+	if err := postJSON(ctx, g.client, g.url, g.token, &body, &res); err != nil {
+		return nil, fmt.Errorf("sms gateway: %w", err)
+	}
+
+	return &domain.DeliveryResult{ProviderMessageID: res.MessageID}, nil
+}
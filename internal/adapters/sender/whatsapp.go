@@ -0,0 +1,63 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"focus-dev-challenge/internal/core/domain"
+	"net/http"
+	"strconv"
+)
+
+type WhatsAppGateway struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+type whatsAppText struct {
+	Body string `json:"body"`
+}
+
+type whatsAppRequest struct {
+	MessagingProduct string       `json:"messaging_product"`
+	To               string       `json:"to"`
+	Type             string       `json:"type"`
+	Text             whatsAppText `json:"text"`
+	BizOpaqueData    string       `json:"biz_opaque_callback_data"`
+}
+
+type whatsAppResponse struct {
+	Messages []struct {
+		ID string `json:"id"`
+	} `json:"messages"`
+}
+
+func NewWhatsAppGateway(client *http.Client, url, token string) *WhatsAppGateway {
+	return &WhatsAppGateway{
+		client: client,
+		url:    url,
+		token:  token,
+	}
+}
+
+func (g *WhatsAppGateway) Send(ctx context.Context, msg *domain.Message) (*domain.DeliveryResult, error) {
+	body := whatsAppRequest{
+		MessagingProduct: "whatsapp",
+		To:               msg.Recipient,
+		Type:             "text",
+		Text:             whatsAppText{Body: msg.Content},
+		BizOpaqueData:    strconv.FormatInt(msg.ID, 10),
+	}
+
+	var res whatsAppResponse
+	if err := postJSON(ctx, g.client, g.url, g.token, &body, &res); err != nil {
+		return nil, fmt.Errorf("whatsapp gateway: %w", err)
+	}
+
+	result := domain.DeliveryResult{}
+	if len(res.Messages) > 0 {
+		result.ProviderMessageID = res.Messages[0].ID
+	}
+
+	return &result, nil
+}
@@ -3,9 +3,13 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"focus-dev-challenge/internal/adapters/repository"
 	"focus-dev-challenge/internal/core/domain"
+	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5/pgtype"
 	"go.uber.org/zap"
 )
 
@@ -16,12 +20,337 @@ func (tp *TaskProcessor) SendMessage(ctx context.Context, task *asynq.Task) erro
 		return err
 	}
 
-	tp.logger.Info("sending message", zap.Int64("message_id", payload.MessageID))
+	taskID, _ := asynq.GetTaskID(ctx)
+	return tp.sendMessage(ctx, payload.MessageID, taskID, isFinalAttempt(ctx))
+}
+
+// sendMessage delivers a message on behalf of the task taskID, which is empty
+// when the task has no ID. final is set on the last attempt asynq makes, after
+// which a failed delivery is not retried.
+func (tp *TaskProcessor) sendMessage(ctx context.Context, messageID int64, taskID string, final bool) error {
+	logger := tp.logger.With(zap.Int64("message_id", messageID))
+
+	msg, err := tp.repository.GetOutboundMessageForDelivery(ctx, messageID)
+	if err != nil {
+		logger.Error("failed to load outbound message", zap.Error(err))
+		return err
+	}
+
+	// Tasks of a paused or cancelled campaign may still run: the queue is only
+	// cleaned up on a best-effort basis, and a task may have been picked up
+	// before it was.
+	switch msg.CampaignStatus {
+	case domain.CampaignPaused:
+		logger.Info("campaign is paused, leaving message for resume")
+		return nil
+	case domain.CampaignCancelled:
+		return tp.cancelMessage(ctx, msg.ID, taskID, logger)
+	}
+
+	if until, quiet := tp.quietUntil(msg, logger); quiet {
+		return tp.deferMessage(ctx, msg.ID, taskID, until, logger)
+	}
+
+	// Claim the message before talking to the gateway. A task the message was
+	// taken off when its campaign was rescheduled or cancelled loses this race
+	// and is dropped. The task that holds the message may claim it again from
+	// sending or failed: asynq delivers it again when a worker died before
+	// recording the outcome or could not put the message back to pending.
+	claim := repository.TransitionOutboundMessageParams{
+		ToStatus:  domain.MessageSending,
+		MessageID: msg.ID,
+		TaskID:    pgtype.Text{String: taskID, Valid: taskID != ""},
+	}
+
+	claimed, err := tp.repository.TransitionOutboundMessage(ctx, &claim)
+	if err != nil {
+		if errors.Is(err, domain.ErrIllegalTransition) {
+			logger.Info("message is not pending or expects another task, skipping", zap.String("status", msg.Status))
+			return nil
+		}
+
+		logger.Error("failed to claim message", zap.Error(err))
+		return err
+	}
+
+	if msg.CampaignStatus == domain.CampaignScheduled {
+		tp.startCampaign(ctx, msg.CampaignID)
+	}
+
+	tp.recordEvent(ctx, msg.ID, domain.MessageEventAttempt, domain.MessageSending, attemptDetail{
+		Attempt: claimed.RetryCount + 1,
+		Channel: msg.Channel,
+	})
+
+	logger.Info("sending message", zap.String("channel", msg.Channel))
+
+	result, err := tp.sender.Send(ctx, &domain.Message{
+		ID:        msg.ID,
+		Channel:   msg.Channel,
+		Recipient: msg.Phone,
+		Content:   claimed.RenderedContent,
+	})
+
+	// The gateway has been called, so the outcome is recorded even if the task
+	// deadline passed while waiting for it.
+	ctx = context.WithoutCancel(ctx)
+
+	response := providerResponseDetail{}
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.ProviderMessageID = result.ProviderMessageID
+	}
+	tp.recordEvent(ctx, msg.ID, domain.MessageEventProviderResponse, domain.MessageSending, response)
+
+	if err != nil {
+		logger.Warn("message delivery failed", zap.Error(err))
+
+		// While asynq still has retries left the message goes straight back to
+		// pending, so it is outstanding throughout and another message cannot
+		// finalize the campaign under it. Should that fail, the message stays
+		// sending and the next attempt claims it from there.
+		if !final {
+			if _, terr := tp.transition(ctx, msg.ID, taskID, domain.MessagePending, err.Error()); terr != nil {
+				logger.Error("failed to requeue message", zap.Error(terr))
+			}
+
+			return err
+		}
+
+		if _, terr := tp.transition(ctx, msg.ID, taskID, domain.MessageFailed, err.Error()); terr != nil {
+			logger.Error("failed to record delivery failure", zap.Error(terr))
+			return err
+		}
+
+		tp.finalizeCampaign(ctx, msg.CampaignID)
+		return err
+	}
+
+	// The provider's ID is what its delivery receipts refer to.
+	sent := repository.TransitionOutboundMessageParams{
+		ToStatus:  domain.MessageSent,
+		MessageID: msg.ID,
+	}
+	if result.ProviderMessageID != "" {
+		sent.ProviderMessageID = pgtype.Text{String: result.ProviderMessageID, Valid: true}
+	}
+
+	if _, err := tp.repository.TransitionOutboundMessage(ctx, &sent); err != nil {
+		logger.Error("failed to mark message as sent", zap.Error(err))
+		return err
+	}
+
+	logger.Info("message sent", zap.String("provider_message_id", result.ProviderMessageID))
+
+	tp.finalizeCampaign(ctx, msg.CampaignID)
+	return nil
+}
+
+// finalizeCampaign rolls the campaign up to sent or failed if this message was
+// the last one outstanding. Failures are only logged: the message itself has
+// already reached a final state and the next finished message retries the
+// roll-up.
+func (tp *TaskProcessor) finalizeCampaign(ctx context.Context, campaignID int64) {
+	campaign, err := tp.repository.FinalizeCampaign(ctx, campaignID)
+	if err != nil {
+		tp.logger.Error("failed to finalize campaign", zap.Int64("campaign_id", campaignID), zap.Error(err))
+		return
+	}
+
+	if campaign != nil {
+		tp.logger.Info(
+			"campaign finished",
+			zap.Int64("campaign_id", campaign.ID),
+			zap.String("status", campaign.Status),
+		)
+	}
+}
+
+// cancelMessage cancels a pending message whose campaign was cancelled after
+// the campaign's own clean-up ran. Messages in any other state, or that expect
+// another task, are left alone.
+func (tp *TaskProcessor) cancelMessage(ctx context.Context, messageID int64, taskID string, logger *zap.Logger) error {
+	arg := repository.TransitionOutboundMessageParams{
+		ToStatus:  domain.MessageCancelled,
+		MessageID: messageID,
+		TaskID:    pgtype.Text{String: taskID, Valid: taskID != ""},
+	}
+
+	_, err := tp.repository.TransitionOutboundMessage(ctx, &arg)
+	switch {
+	case err == nil:
+		logger.Info("campaign is cancelled, message cancelled")
+	case errors.Is(err, domain.ErrIllegalTransition):
+		logger.Info("campaign is cancelled, skipping message")
+	default:
+		logger.Error("failed to cancel message", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// quietUntil reports whether the message's channel is in its quiet hours,
+// read in the campaign's timezone, and when they end.
+func (tp *TaskProcessor) quietUntil(msg *repository.GetOutboundMessageForDeliveryRow, logger *zap.Logger) (time.Time, bool) {
+	loc, err := time.LoadLocation(msg.Timezone)
+	if err != nil {
+		logger.Warn("unknown campaign timezone, using UTC", zap.String("timezone", msg.Timezone))
+		loc = time.UTC
+	}
+
+	return tp.quietHours.Until(msg.Channel, time.Now().In(loc))
+}
+
+// deferMessage leaves a message held back by quiet hours to a new task that
+// runs when they end; this one is done. A message that is no longer pending,
+// or that expects another task, is left alone.
+func (tp *TaskProcessor) deferMessage(ctx context.Context, messageID int64, taskID string, until time.Time, logger *zap.Logger) error {
+	deferred, err := tp.dispatcher.DeferMessage(ctx, messageID, taskID, until)
+	if err != nil {
+		logger.Error("failed to defer message", zap.Error(err))
+		return err
+	}
+
+	if deferred {
+		logger.Info("quiet hours, message deferred", zap.Time("until", until))
+	} else {
+		logger.Info("message is not pending or expects another task, skipping")
+	}
+
+	return nil
+}
+
+// startCampaign moves a scheduled campaign to sending when its first message
+// goes out. Failures are only logged: the next message tries again.
+func (tp *TaskProcessor) startCampaign(ctx context.Context, campaignID int64) {
+	campaign, err := tp.repository.StartScheduledCampaign(ctx, &repository.StartScheduledCampaignParams{
+		CampaignID: campaignID,
+		Now:        pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		tp.logger.Error("failed to start campaign", zap.Int64("campaign_id", campaignID), zap.Error(err))
+		return
+	}
+
+	if campaign != nil {
+		tp.logger.Info("campaign started", zap.Int64("campaign_id", campaign.ID))
+	}
+}
+
+// attemptDetail is stored with the event recorded when a worker claims a
+// message.
+type attemptDetail struct {
+	Attempt int32  `json:"attempt"`
+	Channel string `json:"channel"`
+}
+
+// providerResponseDetail is stored with the event recorded when the gateway
+// answers.
+type providerResponseDetail struct {
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// recordEvent appends an event to the message timeline. Failures are only
+// logged: the timeline must not hold up or repeat a delivery.
+func (tp *TaskProcessor) recordEvent(ctx context.Context, messageID int64, eventType, status string, detail any) {
+	logger := tp.logger.With(zap.Int64("message_id", messageID), zap.String("event_type", eventType))
+
+	data, err := json.Marshal(detail)
+	if err != nil {
+		logger.Error("failed to encode message event", zap.Error(err))
+		return
+	}
+
+	_, err = tp.repository.CreateMessageEvent(ctx, &repository.CreateMessageEventParams{
+		MessageID: messageID,
+		EventType: eventType,
+		Status:    status,
+		Detail:    data,
+	})
+	if err != nil {
+		logger.Error("failed to record message event", zap.Error(err))
+	}
+}
+
+func (tp *TaskProcessor) transition(ctx context.Context, messageID int64, taskID, status, lastError string) (*repository.OutboundMessage, error) {
+	arg := repository.TransitionOutboundMessageParams{
+		ToStatus:  status,
+		MessageID: messageID,
+		TaskID:    pgtype.Text{String: taskID, Valid: taskID != ""},
+	}
+	if lastError != "" {
+		arg.LastError = pgtype.Text{String: lastError, Valid: true}
+	}
+
+	return tp.repository.TransitionOutboundMessage(ctx, &arg)
+}
+
+func isFinalAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return true
+	}
+
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	if !ok {
+		return true
+	}
+
+	return retried >= maxRetry
+}
+
+func (tp *TaskProcessor) ImportCustomers(ctx context.Context, task *asynq.Task) error {
+	var payload domain.ImportCustomers
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		tp.logger.Error("failed to parse task data", zap.Error(err))
+		return err
+	}
+
+	logger := tp.logger.With(zap.Int64("import_id", payload.ImportID))
+
+	record, err := tp.importer.Run(ctx, payload.ImportID)
+	if err != nil {
+		logger.Error("customer import failed", zap.Error(err))
+		return err
+	}
+
+	logger.Info(
+		"customer import completed",
+		zap.Int32("processed_rows", record.ProcessedRows),
+		zap.Int32("imported_rows", record.ImportedRows),
+		zap.Int32("rejected_rows", record.RejectedRows),
+	)
+
+	return nil
+}
+
+func (tp *TaskProcessor) DispatchCampaign(ctx context.Context, task *asynq.Task) error {
+	var payload domain.DispatchCampaign
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		tp.logger.Error("failed to parse task data", zap.Error(err))
+		return err
+	}
+
+	logger := tp.logger.With(zap.Int64("dispatch_id", payload.DispatchID))
+
+	record, err := tp.dispatcher.RunDispatch(ctx, payload.DispatchID)
+	if err != nil {
+		logger.Error("campaign dispatch failed", zap.Error(err))
+		return err
+	}
 
-	// TODO:
-	// - User worker.repository to fetch campaign & customer records
-	// - Send message to customer
-	// - Use worker.repository to update status
+	logger.Info(
+		"campaign dispatch finished",
+		zap.Int64("campaign_id", record.CampaignID),
+		zap.String("status", record.Status),
+		zap.Int32("total_customers", record.TotalCustomers),
+		zap.Int32("queued_messages", record.QueuedMessages),
+		zap.Int32("skipped_customers", record.SkippedCustomers),
+		zap.Int32("failed_customers", record.FailedCustomers),
+	)
 
 	return nil
 }
@@ -0,0 +1,306 @@
+package worker
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/config"
+	"focus-dev-challenge/internal/core/app"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/ports"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeDB records the queries run in a transaction by name. Every row it
+// returns scans as ID 1.
+type fakeDB struct {
+	queries []string
+	args    map[string][]interface{}
+}
+
+func (f *fakeDB) record(sql string, args []interface{}) {
+	name := strings.Fields(strings.TrimPrefix(sql, "-- name: "))[0]
+	f.queries = append(f.queries, name)
+	f.args[name] = args
+}
+
+func (f *fakeDB) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	f.record(sql, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func (f *fakeDB) Query(_ context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, stderrors.New("not supported")
+}
+
+func (f *fakeDB) QueryRow(_ context.Context, sql string, args ...interface{}) pgx.Row {
+	f.record(sql, args)
+	return fakeRow{}
+}
+
+type fakeRow struct{}
+
+func (fakeRow) Scan(dest ...any) error {
+	if id, ok := dest[0].(*int64); ok {
+		*id = 1
+	}
+	return nil
+}
+
+// fakeRepository holds a single message and applies transitions the way
+// TransitionOutboundMessage does in the database. statuses lists every status
+// the message moved to.
+type fakeRepository struct {
+	ports.AppRepository
+	message  *repository.OutboundMessage
+	channel  string
+	timezone string
+	events   []string
+	statuses []string
+	db       *fakeDB
+
+	// afterTransition runs after every transition, as another message
+	// finishing at that moment would.
+	afterTransition func()
+	finalized       bool
+}
+
+func (f *fakeRepository) ExecTx(_ context.Context, fn func(*repository.Queries) error) error {
+	return fn(repository.New(f.db))
+}
+
+func (f *fakeRepository) GetOutboundMessageForDelivery(_ context.Context, ID int64) (*repository.GetOutboundMessageForDeliveryRow, error) {
+	return &repository.GetOutboundMessageForDeliveryRow{
+		ID:              f.message.ID,
+		CampaignID:      f.message.CampaignID,
+		Status:          f.message.Status,
+		RenderedContent: f.message.RenderedContent,
+		RetryCount:      f.message.RetryCount,
+		TaskID:          f.message.TaskID,
+		Channel:         f.channel,
+		CampaignStatus:  domain.CampaignSending,
+		Timezone:        f.timezone,
+		Phone:           "+254712000000",
+	}, nil
+}
+
+func (f *fakeRepository) TransitionOutboundMessage(_ context.Context, arg *repository.TransitionOutboundMessageParams) (*repository.OutboundMessage, error) {
+	msg := f.message
+	holder := arg.TaskID.Valid && msg.TaskID.Valid && msg.TaskID.String == arg.TaskID.String
+	allowed := slices.Contains(domain.MessageTransitionSources(arg.ToStatus), msg.Status) &&
+		(!arg.TaskID.Valid || !msg.TaskID.Valid || holder) &&
+		(arg.ToStatus != domain.MessageSending || msg.Status == domain.MessagePending || holder) &&
+		(arg.ToStatus != domain.MessagePending || msg.Status == domain.MessageFailed || holder)
+	if !allowed {
+		return nil, fmt.Errorf("%w: message %d cannot move from %s to %s", domain.ErrIllegalTransition, msg.ID, msg.Status, arg.ToStatus)
+	}
+
+	if arg.ToStatus == domain.MessagePending || msg.Status == domain.MessageFailed {
+		msg.RetryCount++
+	}
+	msg.Status = arg.ToStatus
+	f.statuses = append(f.statuses, msg.Status)
+	if arg.LastError.Valid {
+		msg.LastError = arg.LastError
+	}
+	if arg.ProviderMessageID.Valid {
+		msg.ProviderMessageID = arg.ProviderMessageID
+	}
+
+	record := *msg
+	if f.afterTransition != nil {
+		f.afterTransition()
+	}
+	return &record, nil
+}
+
+func (f *fakeRepository) CreateMessageEvent(_ context.Context, arg *repository.CreateMessageEventParams) (*repository.MessageEvent, error) {
+	f.events = append(f.events, arg.EventType)
+	return &repository.MessageEvent{}, nil
+}
+
+// FinalizeCampaign rolls the campaign up once its message is no longer
+// outstanding, as the FinalizeCampaign query does.
+func (f *fakeRepository) FinalizeCampaign(_ context.Context, ID int64) (*repository.Campaign, error) {
+	if f.message.Status == domain.MessagePending || f.message.Status == domain.MessageSending {
+		return nil, nil
+	}
+
+	f.finalized = true
+	return &repository.Campaign{ID: ID, Status: domain.CampaignFailed}, nil
+}
+
+// fakeSender panics on its first call when crash is set, as a worker dying
+// halfway through a delivery would, and then fails the next fail calls.
+type fakeSender struct {
+	crash bool
+	fail  int
+	sent  []*domain.Message
+}
+
+func (f *fakeSender) Send(_ context.Context, msg *domain.Message) (*domain.DeliveryResult, error) {
+	if f.crash {
+		f.crash = false
+		panic("worker died")
+	}
+	if f.fail > 0 {
+		f.fail--
+		return nil, stderrors.New("gateway unavailable")
+	}
+
+	f.sent = append(f.sent, msg)
+	return &domain.DeliveryResult{ProviderMessageID: fmt.Sprintf("provider-%d", len(f.sent))}, nil
+}
+
+func newProcessor(repo *fakeRepository, sender *fakeSender) *TaskProcessor {
+	return &TaskProcessor{logger: zap.NewNop(), repository: repo, sender: sender}
+}
+
+// newQuietProcessor defers messages through the service, with SMS quiet hours
+// running from start to end.
+func newQuietProcessor(t *testing.T, repo *fakeRepository, sender *fakeSender, start, end time.Time) *TaskProcessor {
+	t.Helper()
+
+	quietHours, err := config.ParseQuietHours(fmt.Sprintf(`{"sms": {"start": %q, "end": %q}}`, start.Format("15:04"), end.Format("15:04")))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	repo.db = &fakeDB{args: map[string][]interface{}{}}
+	cfg := &config.Config{RedisHost: "127.0.0.1:6379", DefaultQueue: "tasks", DispatchBatchSize: 1}
+	tp := newProcessor(repo, sender)
+	tp.dispatcher = app.NewService(cfg, repo, validator.New(), zap.NewNop())
+	tp.quietHours = quietHours
+	return tp
+}
+
+func pendingMessage(taskID string) *repository.OutboundMessage {
+	return &repository.OutboundMessage{
+		ID:              1,
+		CampaignID:      7,
+		Status:          domain.MessagePending,
+		RenderedContent: "Hi Ann",
+		TaskID:          pgtype.Text{String: taskID, Valid: true},
+	}
+}
+
+func TestSendMessage_RedeliveryAfterCrash(t *testing.T) {
+	repo := &fakeRepository{message: pendingMessage("outbox:1"), channel: "sms", timezone: "UTC"}
+	sender := &fakeSender{crash: true}
+	tp := newProcessor(repo, sender)
+
+	assert.Panics(t, func() { _ = tp.sendMessage(context.Background(), 1, "outbox:1", true) })
+	assert.Equal(t, domain.MessageSending, repo.message.Status, "the crash leaves the message claimed")
+
+	assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:2", true))
+	assert.Empty(t, sender.sent, "another task cannot take the message over")
+
+	assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:1", true))
+	assert.Len(t, sender.sent, 1)
+	assert.Equal(t, domain.MessageSent, repo.message.Status)
+	assert.Equal(t, "provider-1", repo.message.ProviderMessageID.String)
+}
+
+func TestSendMessage_ReclaimsFailedMessage(t *testing.T) {
+	msg := pendingMessage("outbox:1")
+	msg.Status = domain.MessageFailed
+	repo := &fakeRepository{message: msg, channel: "sms", timezone: "UTC"}
+	sender := &fakeSender{}
+	tp := newProcessor(repo, sender)
+
+	assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:1", true))
+	assert.Len(t, sender.sent, 1)
+	assert.Equal(t, domain.MessageSent, repo.message.Status)
+	assert.Equal(t, int32(1), repo.message.RetryCount, "claiming a failed message is a retry")
+}
+
+func TestSendMessage_RetriedFailureStaysOutstanding(t *testing.T) {
+	repo := &fakeRepository{message: pendingMessage("outbox:1"), channel: "sms", timezone: "UTC"}
+	sender := &fakeSender{fail: 1}
+	tp := newProcessor(repo, sender)
+
+	// Another message of the campaign finishes right after each transition.
+	repo.afterTransition = func() { _, _ = repo.FinalizeCampaign(context.Background(), 7) }
+
+	assert.Error(t, tp.sendMessage(context.Background(), 1, "outbox:1", false))
+	assert.Equal(t, []string{domain.MessageSending, domain.MessagePending}, repo.statuses, "a failure with retries left never passes through failed")
+	assert.False(t, repo.finalized, "the campaign is not finalized while the message has retries left")
+	assert.Equal(t, int32(1), repo.message.RetryCount)
+	assert.Equal(t, "gateway unavailable", repo.message.LastError.String)
+
+	assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:1", false))
+	assert.Equal(t, domain.MessageSent, repo.message.Status)
+	assert.True(t, repo.finalized)
+}
+
+func TestSendMessage_FinalFailure(t *testing.T) {
+	repo := &fakeRepository{message: pendingMessage("outbox:1"), channel: "sms", timezone: "UTC"}
+	tp := newProcessor(repo, &fakeSender{fail: 1})
+
+	assert.Error(t, tp.sendMessage(context.Background(), 1, "outbox:1", true))
+	assert.Equal(t, []string{domain.MessageSending, domain.MessageFailed}, repo.statuses)
+	assert.True(t, repo.finalized)
+}
+
+func TestSendMessage_QuietHours(t *testing.T) {
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	t.Run("inside quiet hours", func(t *testing.T) {
+		now := time.Now().In(nairobi)
+		repo := &fakeRepository{message: pendingMessage("outbox:1"), channel: "sms", timezone: "Africa/Nairobi"}
+		sender := &fakeSender{}
+		tp := newQuietProcessor(t, repo, sender, now.Add(-time.Hour), now.Add(time.Hour))
+
+		assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:1", true))
+		assert.Empty(t, sender.sent)
+		assert.Equal(t, domain.MessagePending, repo.message.Status)
+		assert.Equal(t, []string{"CreateOutboxEntry", "DeferOutboundMessage", "CreateMessageEvent"}, repo.db.queries)
+
+		processAt := repo.db.args["CreateOutboxEntry"][3].(pgtype.Timestamptz)
+		end := now.Add(time.Hour)
+		assert.Equal(t, time.Date(end.Year(), end.Month(), end.Day(), end.Hour(), end.Minute(), 0, 0, nairobi), processAt.Time)
+		assert.Equal(t, pgtype.Text{String: "outbox:1", Valid: true}, repo.db.args["DeferOutboundMessage"][2], "only the task holding the message defers it")
+		assert.Equal(t, domain.MessageEventDeferred, repo.db.args["CreateMessageEvent"][1])
+	})
+
+	t.Run("outside quiet hours", func(t *testing.T) {
+		now := time.Now().In(nairobi)
+		repo := &fakeRepository{message: pendingMessage("outbox:1"), channel: "sms", timezone: "Africa/Nairobi"}
+		sender := &fakeSender{}
+		tp := newQuietProcessor(t, repo, sender, now.Add(time.Hour), now.Add(2*time.Hour))
+
+		assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:1", true))
+		assert.Len(t, sender.sent, 1)
+		assert.Equal(t, domain.MessageSent, repo.message.Status)
+		assert.Empty(t, repo.db.queries)
+	})
+
+	t.Run("unknown timezone", func(t *testing.T) {
+		// The window is around the current time in UTC.
+		now := time.Now().UTC()
+		repo := &fakeRepository{message: pendingMessage("outbox:1"), channel: "sms", timezone: "Mars/Olympus"}
+		sender := &fakeSender{}
+		tp := newQuietProcessor(t, repo, sender, now.Add(-time.Hour), now.Add(time.Hour))
+
+		assert.NoError(t, tp.sendMessage(context.Background(), 1, "outbox:1", true))
+		assert.Empty(t, sender.sent)
+
+		processAt := repo.db.args["CreateOutboxEntry"][3].(pgtype.Timestamptz)
+		assert.Equal(t, time.UTC, processAt.Time.Location())
+		assert.Equal(t, now.Add(time.Hour).Hour(), processAt.Time.Hour())
+	})
+}
@@ -15,9 +15,20 @@ type TaskProcessor struct {
 	server     *asynq.Server
 	logger     *zap.Logger
 	repository ports.AppRepository
+	sender     ports.MessageSender
+	importer   ports.CustomerImporter
+	dispatcher ports.CampaignDispatcher
+	quietHours config.QuietHours
 }
 
-func NewTaskProcessor(cfg *config.Config, repo ports.AppRepository, logger *zap.Logger) *TaskProcessor {
+func NewTaskProcessor(
+	cfg *config.Config,
+	repo ports.AppRepository,
+	sender ports.MessageSender,
+	importer ports.CustomerImporter,
+	dispatcher ports.CampaignDispatcher,
+	logger *zap.Logger,
+) *TaskProcessor {
 	server := asynq.NewServer(
 		&asynq.RedisClientOpt{
 			Addr:        cfg.RedisHost,
@@ -42,12 +53,18 @@ func NewTaskProcessor(cfg *config.Config, repo ports.AppRepository, logger *zap.
 		server:     server,
 		logger:     logger,
 		repository: repo,
+		sender:     sender,
+		importer:   importer,
+		dispatcher: dispatcher,
+		quietHours: cfg.QuietHours,
 	}
 }
 
 func (tp *TaskProcessor) Start() error {
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(domain.SendMessageTask, tp.SendMessage)
+	mux.HandleFunc(domain.ImportCustomersTask, tp.ImportCustomers)
+	mux.HandleFunc(domain.DispatchCampaignTask, tp.DispatchCampaign)
 
 	return tp.server.Start(mux)
 }
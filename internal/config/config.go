@@ -1,6 +1,11 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/mwinyimoha/commons/pkg/errors"
 	"github.com/spf13/viper"
@@ -8,7 +13,7 @@ import (
 
 type Config struct {
 	ServiceName    string `mapstructure:"SERVICE_NAME" validate:"required"`
-	AppTier        string `mapstructure:"APP_TIER" validate:"required,oneof=web worker"`
+	AppTier        string `mapstructure:"APP_TIER" validate:"required,oneof=web worker relay scheduler"`
 	Debug          bool   `mapstructure:"DEBUG"`
 	DefaultTimeout int    `mapstructure:"DEFAULT_TIMEOUT" validate:"required,gt=0"`
 	ServerPort     int    `mapstructure:"SERVER_PORT" validate:"required"`
@@ -16,6 +21,149 @@ type Config struct {
 	RedisHost      string `mapstructure:"REDIS_HOST" validate:"required"`
 	RedisDB        int    `mapstructure:"REDIS_DB"`
 	DefaultQueue   string `mapstructure:"DEFAULT_QUEUE"`
+
+	DBMaxConns        int `mapstructure:"DB_MAX_CONNS" validate:"required,gt=0"`
+	DBMinConns        int `mapstructure:"DB_MIN_CONNS" validate:"gte=0,ltefield=DBMaxConns"`
+	DBMaxConnLifetime int `mapstructure:"DB_MAX_CONN_LIFETIME" validate:"required,gt=0"`  // seconds
+	DBMaxConnIdleTime int `mapstructure:"DB_MAX_CONN_IDLE_TIME" validate:"required,gt=0"` // seconds
+
+	SenderDriver         string `mapstructure:"SENDER_DRIVER" validate:"required,oneof=file gateway"`
+	SenderOutputFile     string `mapstructure:"SENDER_OUTPUT_FILE" validate:"required_if=SenderDriver file"`
+	SMSGatewayURL        string `mapstructure:"SMS_GATEWAY_URL" validate:"required_if=SenderDriver gateway,omitempty,url"`
+	SMSGatewayToken      string `mapstructure:"SMS_GATEWAY_TOKEN"`
+	WhatsAppGatewayURL   string `mapstructure:"WHATSAPP_GATEWAY_URL" validate:"required_if=SenderDriver gateway,omitempty,url"`
+	WhatsAppGatewayToken string `mapstructure:"WHATSAPP_GATEWAY_TOKEN"`
+
+	// Webhook secrets sign delivery receipts. A channel without one accepts no
+	// receipts.
+	SMSWebhookSecret      string `mapstructure:"SMS_WEBHOOK_SECRET"`
+	WhatsAppWebhookSecret string `mapstructure:"WHATSAPP_WEBHOOK_SECRET"`
+
+	PriceTable         string     `mapstructure:"PRICE_TABLE" validate:"omitempty,json"`
+	PriceCurrency      string     `mapstructure:"PRICE_CURRENCY" validate:"required"`
+	EstimateSampleSize int        `mapstructure:"ESTIMATE_SAMPLE_SIZE" validate:"required,gt=0"`
+	Prices             PriceTable `mapstructure:"-"`
+
+	DispatchBatchSize int `mapstructure:"DISPATCH_BATCH_SIZE" validate:"required,gt=0"`
+
+	QuietHoursTable string     `mapstructure:"QUIET_HOURS" validate:"omitempty,json"`
+	QuietHours      QuietHours `mapstructure:"-"`
+
+	RelayInterval  int `mapstructure:"RELAY_INTERVAL_MS" validate:"required,gt=0"`
+	RelayBatchSize int `mapstructure:"RELAY_BATCH_SIZE" validate:"required,gt=0"`
+
+	SchedulerInterval  int `mapstructure:"SCHEDULER_INTERVAL_MS" validate:"required,gt=0"`
+	SchedulerBatchSize int `mapstructure:"SCHEDULER_BATCH_SIZE" validate:"required,gt=0"`
+
+	// CursorSecret signs the pagination cursors handed out by the API so that
+	// clients cannot forge positions.
+	CursorSecret string `mapstructure:"CURSOR_SECRET" validate:"required_if=AppTier web"`
+
+	IdempotencyTTL         int `mapstructure:"IDEMPOTENCY_TTL" validate:"required,gt=0"`          // seconds
+	IdempotencyLockTimeout int `mapstructure:"IDEMPOTENCY_LOCK_TIMEOUT" validate:"required,gt=0"` // seconds
+	IdempotencyMaxBody     int `mapstructure:"IDEMPOTENCY_MAX_BODY" validate:"required,gt=0"`     // bytes
+}
+
+// PriceTable holds message prices keyed by channel and then by dialling prefix,
+// e.g. {"sms": {"+254": 0.8, "*": 1.5}}. The "*" entry applies to numbers no
+// other prefix matches. SMS prices are per segment; other channels per message.
+type PriceTable map[string]map[string]float64
+
+// Price returns the price for a recipient on a channel, preferring the longest
+// matching prefix.
+func (t PriceTable) Price(channel, phone string) (float64, bool) {
+	prices, ok := t[channel]
+	if !ok {
+		return 0, false
+	}
+
+	best, price, found := -1, 0.0, false
+	for prefix, p := range prices {
+		if prefix == "*" {
+			if best < 0 {
+				price, found = p, true
+			}
+			continue
+		}
+
+		if strings.HasPrefix(phone, prefix) && len(prefix) > best {
+			best, price, found = len(prefix), p, true
+		}
+	}
+
+	return price, found
+}
+
+// QuietHours holds, per channel, the local times between which no messages
+// go out, e.g. {"sms": {"start": "21:00", "end": "08:00"}}. A window whose
+// end comes before its start runs over midnight.
+type QuietHours map[string]QuietWindow
+
+type QuietWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	start, end int // minutes after midnight
+}
+
+// Until reports whether t falls in the quiet hours of a channel and, if it
+// does, when they end. Both are read in t's location.
+func (q QuietHours) Until(channel string, t time.Time) (time.Time, bool) {
+	w, ok := q[channel]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	days := 0
+	switch {
+	case w.start < w.end && now >= w.start && now < w.end:
+	case w.start > w.end && now < w.end:
+	case w.start > w.end && now >= w.start:
+		days = 1
+	default:
+		return time.Time{}, false
+	}
+
+	y, m, d := t.Date()
+	return time.Date(y, m, d+days, w.end/60, w.end%60, 0, 0, t.Location()), true
+}
+
+// ParseQuietHours reads quiet hours from their JSON form.
+func ParseQuietHours(raw string) (QuietHours, error) {
+	var q QuietHours
+	if err := json.Unmarshal([]byte(raw), &q); err != nil {
+		return nil, err
+	}
+
+	for channel, w := range q {
+		if err := w.parse(); err != nil {
+			return nil, fmt.Errorf("%s: %w", channel, err)
+		}
+		q[channel] = w
+	}
+
+	return q, nil
+}
+
+func (w *QuietWindow) parse() error {
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return err
+	}
+
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return err
+	}
+
+	w.start = start.Hour()*60 + start.Minute()
+	w.end = end.Hour()*60 + end.Minute()
+	if w.start == w.end {
+		return fmt.Errorf("quiet hours %s-%s are empty", w.Start, w.End)
+	}
+
+	return nil
 }
 
 func New(val *validator.Validate) (*Config, error) {
@@ -28,9 +176,34 @@ func New(val *validator.Validate) (*Config, error) {
 	v.SetDefault("DEFAULT_TIMEOUT", 10)
 	v.SetDefault("SERVER_PORT", 8080)
 	v.SetDefault("DATABASE_URL", "")
+	v.SetDefault("DB_MAX_CONNS", 20)
+	v.SetDefault("DB_MIN_CONNS", 2)
+	v.SetDefault("DB_MAX_CONN_LIFETIME", 3600)
+	v.SetDefault("DB_MAX_CONN_IDLE_TIME", 300)
 	v.SetDefault("REDIS_HOST", "")
 	v.SetDefault("REDIS_DB", 0)
 	v.SetDefault("DEFAULT_QUEUE", "tasks")
+	v.SetDefault("SENDER_DRIVER", "file")
+	v.SetDefault("SENDER_OUTPUT_FILE", "./tmp/sent_messages.ndjson")
+	v.SetDefault("SMS_GATEWAY_URL", "")
+	v.SetDefault("SMS_GATEWAY_TOKEN", "")
+	v.SetDefault("WHATSAPP_GATEWAY_URL", "")
+	v.SetDefault("WHATSAPP_GATEWAY_TOKEN", "")
+	v.SetDefault("SMS_WEBHOOK_SECRET", "")
+	v.SetDefault("WHATSAPP_WEBHOOK_SECRET", "")
+	v.SetDefault("PRICE_TABLE", "")
+	v.SetDefault("PRICE_CURRENCY", "KES")
+	v.SetDefault("ESTIMATE_SAMPLE_SIZE", 1000)
+	v.SetDefault("DISPATCH_BATCH_SIZE", 500)
+	v.SetDefault("QUIET_HOURS", "")
+	v.SetDefault("RELAY_INTERVAL_MS", 500)
+	v.SetDefault("RELAY_BATCH_SIZE", 100)
+	v.SetDefault("SCHEDULER_INTERVAL_MS", 5000)
+	v.SetDefault("SCHEDULER_BATCH_SIZE", 50)
+	v.SetDefault("CURSOR_SECRET", "")
+	v.SetDefault("IDEMPOTENCY_TTL", 86400)
+	v.SetDefault("IDEMPOTENCY_LOCK_TIMEOUT", 60)
+	v.SetDefault("IDEMPOTENCY_MAX_BODY", 1<<20)
 
 	v.AutomaticEnv()
 
@@ -58,5 +231,19 @@ func (c *Config) validate(v *validator.Validate) error {
 		return errors.WrapError(err, errors.InvalidArgument, "invalid config")
 	}
 
+	if c.PriceTable != "" {
+		if err := json.Unmarshal([]byte(c.PriceTable), &c.Prices); err != nil {
+			return errors.WrapError(err, errors.InvalidArgument, "invalid price table")
+		}
+	}
+
+	if c.QuietHoursTable != "" {
+		quietHours, err := ParseQuietHours(c.QuietHoursTable)
+		if err != nil {
+			return errors.WrapError(err, errors.InvalidArgument, "invalid quiet hours")
+		}
+		c.QuietHours = quietHours
+	}
+
 	return nil
 }
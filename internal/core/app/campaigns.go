@@ -0,0 +1,660 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/template"
+	"slices"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// cancelledDetail is stored with the status_changed event of messages that
+// were cancelled together with their campaign.
+type cancelledDetail struct {
+	Reason string `json:"reason"`
+}
+
+// UpdateCampaign edits a draft or scheduled campaign. When version is set it
+// must match the time of the campaign's last update, so that an edit based on
+// a stale copy is refused instead of overwriting someone else's. Messages the
+// campaign already queued follow the edit: they are rendered again when the
+// template or its settings change, requeued for the new time when the
+// campaign is rescheduled, and cancelled when its schedule is removed.
+func (svc *Service) UpdateCampaign(
+	ctx context.Context,
+	campaignID int64,
+	payload *domain.UpdateCampaign,
+	version *time.Time,
+) (*repository.Campaign, []*domain.TemplateWarning, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	current, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if version != nil && !current.UpdatedAt.Time.Equal(*version) {
+		return nil, nil, campaignModified(campaignID)
+	}
+	if !slices.Contains(domain.CampaignEditableStatuses(), current.Status) {
+		return nil, nil, campaignNotEditable(current)
+	}
+
+	edited, err := editedCampaign(current, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payload.SegmentID != nil {
+		if _, err := svc.repository.GetSegment(ctx, *payload.SegmentID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tmpl, warnings, err := compileTemplate(edited.BaseTemplate, "base_template", "BaseTemplate", edited.LenientTemplates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rerender := edited.BaseTemplate != current.BaseTemplate ||
+		edited.LenientTemplates != current.LenientTemplates ||
+		edited.MaxSegments != current.MaxSegments
+	reschedule := edited.ScheduledAt.Valid &&
+		(!current.ScheduledAt.Valid || !edited.ScheduledAt.Time.Equal(current.ScheduledAt.Time))
+	unschedule := !edited.ScheduledAt.Valid && current.ScheduledAt.Valid
+
+	var record *repository.Campaign
+	var replaced []string
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		record, err = q.UpdateCampaign(ctx, &repository.UpdateCampaignParams{
+			Name:              edited.Name,
+			Status:            edited.Status,
+			BaseTemplate:      edited.BaseTemplate,
+			ScheduledAt:       edited.ScheduledAt,
+			LenientTemplates:  edited.LenientTemplates,
+			MaxSegments:       edited.MaxSegments,
+			SegmentID:         edited.SegmentID,
+			CustomerIds:       edited.CustomerIds,
+			Recurrence:        edited.Recurrence,
+			Timezone:          edited.Timezone,
+			RecurrenceEndedAt: edited.RecurrenceEndedAt,
+			CampaignID:        campaignID,
+			FromStatuses:      domain.CampaignEditableStatuses(),
+			ExpectedUpdatedAt: current.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if unschedule {
+			replaced, err = cancelCampaignMessages(ctx, q, campaignID, "campaign was unscheduled")
+			return err
+		}
+		if rerender || reschedule {
+			replaced, err = svc.requeueCampaignMessages(ctx, q, edited, tmpl, rerender, reschedule)
+		}
+		return err
+	})
+	if stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, svc.campaignEditError(ctx, current)
+	}
+	if err != nil {
+		if _, ok := err.(*errors.Error); ok {
+			return nil, nil, err
+		}
+
+		return nil, nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	svc.deleteTasks(replaced)
+
+	return record, warnings, nil
+}
+
+// editedCampaign applies an edit to a copy of the campaign. A campaign with a
+// schedule is scheduled, one without is a draft. Scheduling a campaign needs
+// an audience for the scheduler to send it to. The schedule of a recurring
+// campaign is its next occurrence, and scheduled_at only says when the
+// occurrences may start. A scheduled_at without an offset is read in the
+// campaign's timezone, including one the same edit sets.
+func editedCampaign(current *repository.GetCampaignRow, payload *domain.UpdateCampaign) (*repository.GetCampaignRow, error) {
+	edited := *current
+	if payload.Name != nil {
+		edited.Name = *payload.Name
+	}
+	if payload.BaseTemplate != nil {
+		edited.BaseTemplate = *payload.BaseTemplate
+	}
+	if payload.LenientTemplates != nil {
+		edited.LenientTemplates = *payload.LenientTemplates
+	}
+	if payload.MaxSegments != nil {
+		edited.MaxSegments = pgtype.Int4{Int32: *payload.MaxSegments, Valid: *payload.MaxSegments > 0}
+	}
+	if payload.Timezone != nil {
+		edited.Timezone = *payload.Timezone
+		if edited.Timezone == "" {
+			edited.Timezone = defaultTimezone
+		}
+	}
+	if payload.ScheduledAt != nil {
+		edited.ScheduledAt = pgtype.Timestamptz{}
+		if *payload.ScheduledAt != "" {
+			ts, err := parseScheduledAt(*payload.ScheduledAt, edited.Timezone)
+			if err != nil {
+				return nil, validationError(&fieldError{
+					field:       "scheduled_at",
+					structField: "ScheduledAt",
+					tag:         "valid_scheduled_at",
+					value:       *payload.ScheduledAt,
+				})
+			}
+			edited.ScheduledAt = pgtype.Timestamptz{Time: ts, Valid: true}
+		}
+	}
+	if payload.SegmentID != nil {
+		edited.SegmentID = pgtype.Int8{Int64: *payload.SegmentID, Valid: true}
+		edited.CustomerIds = nil
+	}
+	if len(payload.CustomerIds) > 0 {
+		edited.SegmentID = pgtype.Int8{}
+		edited.CustomerIds = payload.CustomerIds
+	}
+	if payload.Recurrence != nil {
+		edited.Recurrence = pgtype.Text{String: *payload.Recurrence, Valid: *payload.Recurrence != ""}
+		if edited.Recurrence.Valid {
+			edited.RecurrenceEndedAt = pgtype.Timestamp{}
+		}
+	}
+	if edited.Recurrence.Valid && (payload.Recurrence != nil || payload.Timezone != nil || payload.ScheduledAt != nil) {
+		var start time.Time
+		if payload.ScheduledAt != nil {
+			start = edited.ScheduledAt.Time
+		}
+
+		first, err := firstOccurrence(edited.Recurrence.String, edited.Timezone, start)
+		if err != nil {
+			return nil, invalidRecurrence(edited.Recurrence.String)
+		}
+		edited.ScheduledAt = pgtype.Timestamptz{Time: first, Valid: true}
+	}
+
+	rescheduled := payload.ScheduledAt != nil || payload.Recurrence != nil
+	if edited.ScheduledAt.Valid && rescheduled && !edited.SegmentID.Valid && edited.CustomerIds == nil {
+		return nil, missingAudience()
+	}
+
+	edited.Status = domain.CampaignDraft
+	if edited.ScheduledAt.Valid {
+		edited.Status = domain.CampaignScheduled
+	}
+
+	return &edited, nil
+}
+
+// requeueCampaignMessages brings the pending messages of an edited or resumed
+// campaign in line with it, a page at a time. Their content is rendered again when
+// rerender is set, and when reschedule is set every message gets a new outbox
+// entry for the campaign's scheduled time. It returns the IDs of the tasks the
+// new entries replace.
+func (svc *Service) requeueCampaignMessages(
+	ctx context.Context,
+	q *repository.Queries,
+	campaign *repository.GetCampaignRow,
+	tmpl *template.Template,
+	rerender, reschedule bool,
+) ([]string, error) {
+	// A time that has already passed queues the messages right away.
+	processAt := campaign.ScheduledAt
+	if !processAt.Time.After(time.Now()) {
+		processAt = pgtype.Timestamptz{}
+	}
+
+	var replaced []string
+	afterID := int64(0)
+	for {
+		page, err := q.ListPendingCampaignMessages(ctx, &repository.ListPendingCampaignMessagesParams{
+			CampaignID: campaign.ID,
+			AfterID:    afterID,
+			BatchSize:  svc.dispatchBatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return replaced, nil
+		}
+
+		if rerender {
+			if err := rerenderMessages(ctx, q, campaign, tmpl, page); err != nil {
+				return nil, err
+			}
+		}
+
+		if reschedule {
+			arg := repository.SetOutboundMessageTaskIdsParams{
+				MessageIds: make([]int64, 0, len(page)),
+				TaskIds:    make([]string, 0, len(page)),
+			}
+			for _, msg := range page {
+				entry, err := svc.outboxEntry(domain.SendMessageTask, domain.SendMessage{MessageID: msg.ID}, processAt)
+				if err != nil {
+					return nil, err
+				}
+
+				created, err := q.CreateOutboxEntry(ctx, entry)
+				if err != nil {
+					return nil, err
+				}
+
+				arg.MessageIds = append(arg.MessageIds, msg.ID)
+				arg.TaskIds = append(arg.TaskIds, domain.OutboxTaskID(created.ID))
+				if msg.TaskID.Valid {
+					replaced = append(replaced, msg.TaskID.String)
+				}
+			}
+
+			if err := q.SetOutboundMessageTaskIds(ctx, &arg); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(page) < int(svc.dispatchBatchSize) {
+			return replaced, nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// rerenderMessages renders a page of pending messages again with the edited
+// campaign. A message that no longer fits the campaign's segment cap fails the
+// edit.
+func rerenderMessages(
+	ctx context.Context,
+	q *repository.Queries,
+	campaign *repository.GetCampaignRow,
+	tmpl *template.Template,
+	page []*repository.ListPendingCampaignMessagesRow,
+) error {
+	ids := make([]int64, 0, len(page))
+	for _, msg := range page {
+		ids = append(ids, msg.CustomerID)
+	}
+	slices.Sort(ids)
+
+	customers, err := q.ListCustomersByIds(ctx, &repository.ListCustomersByIdsParams{
+		CustomerIds: ids,
+		BatchSize:   int32(len(ids)),
+	})
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int64]*repository.Customer, len(customers))
+	for _, customer := range customers {
+		byID[customer.ID] = customer
+	}
+
+	arg := repository.UpdateOutboundMessageContentsParams{
+		MessageIds:       make([]int64, 0, len(page)),
+		RenderedContents: make([]string, 0, len(page)),
+		Encodings:        make([]string, 0, len(page)),
+		Segments:         make([]int32, 0, len(page)),
+	}
+	for _, msg := range page {
+		customer, ok := byID[msg.CustomerID]
+		if !ok {
+			continue
+		}
+
+		message, err := renderMessage(campaign, tmpl, customer)
+		if err != nil {
+			return err
+		}
+
+		encoding, segments := "", int32(0)
+		if message.SMS != nil {
+			encoding, segments = message.SMS.Encoding, int32(message.SMS.Segments)
+		}
+
+		arg.MessageIds = append(arg.MessageIds, msg.ID)
+		arg.RenderedContents = append(arg.RenderedContents, message.Content)
+		arg.Encodings = append(arg.Encodings, encoding)
+		arg.Segments = append(arg.Segments, segments)
+	}
+
+	return q.UpdateOutboundMessageContents(ctx, &arg)
+}
+
+// campaignEditError explains why the update of a campaign loaded as current
+// matched nothing.
+func (svc *Service) campaignEditError(ctx context.Context, current *repository.GetCampaignRow) error {
+	latest, err := svc.repository.GetCampaign(ctx, current.ID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !latest.UpdatedAt.Time.Equal(current.UpdatedAt.Time):
+		return campaignModified(current.ID)
+	case !slices.Contains(domain.CampaignEditableStatuses(), latest.Status):
+		return campaignNotEditable(latest)
+	default:
+		return dispatchInProgress(current.ID)
+	}
+}
+
+// CloneCampaign copies the content and audience of a campaign into a new
+// draft. The copy has no schedule and has not been sent to anyone.
+func (svc *Service) CloneCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	source, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := svc.repository.AddCampaign(ctx, &repository.CreateCampaignParams{
+		Name:             source.Name + " (copy)",
+		Channel:          source.Channel,
+		Status:           domain.CampaignDraft,
+		BaseTemplate:     source.BaseTemplate,
+		LenientTemplates: source.LenientTemplates,
+		MaxSegments:      source.MaxSegments,
+		SegmentID:        source.SegmentID,
+		CustomerIds:      source.CustomerIds,
+		Timezone:         source.Timezone,
+	})
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "failed to create campaign")
+	}
+
+	return record, nil
+}
+
+// ArchiveCampaign retires a campaign that is not sending. Archived campaigns
+// are left out of the listings unless asked for by status, and the messages a
+// scheduled campaign had queued are cancelled.
+func (svc *Service) ArchiveCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	sources := domain.CampaignTransitionSources(domain.CampaignArchived)
+
+	var record *repository.Campaign
+	var cancelled []string
+	err := svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		record, err = q.ArchiveCampaign(ctx, &repository.ArchiveCampaignParams{
+			CampaignID:   campaignID,
+			FromStatuses: sources,
+		})
+		if err != nil {
+			return err
+		}
+
+		cancelled, err = cancelCampaignMessages(ctx, q, campaignID, "campaign was archived")
+		return err
+	})
+	if stderrors.Is(err, pgx.ErrNoRows) {
+		current, err := svc.repository.GetCampaign(ctx, campaignID)
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.Contains(sources, current.Status) {
+			return nil, dispatchInProgress(campaignID)
+		}
+
+		return nil, refusedTransition(current, domain.CampaignArchived)
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	svc.deleteTasks(cancelled)
+
+	return record, nil
+}
+
+// CancelCampaign stops a scheduled, sending or paused campaign for good. Its
+// pending messages are cancelled and their tasks removed from the queue.
+// Messages a worker already claimed still go out, and a dispatch that is still
+// fanning out stops before its next page.
+func (svc *Service) CancelCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	var record *repository.Campaign
+	var cancelled []string
+	err := svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		record, err = q.CancelCampaign(ctx, &repository.CancelCampaignParams{
+			CampaignID:   campaignID,
+			FromStatuses: domain.CampaignTransitionSources(domain.CampaignCancelled),
+		})
+		if err != nil {
+			return err
+		}
+
+		cancelled, err = cancelCampaignMessages(ctx, q, campaignID, "campaign was cancelled")
+		return err
+	})
+	if stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, svc.campaignTransitionError(ctx, campaignID, domain.CampaignCancelled)
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	svc.deleteTasks(cancelled)
+
+	return record, nil
+}
+
+// PauseCampaign holds a scheduled or sending campaign. The tasks of its
+// pending messages are removed from the queue, and any that run anyway leave
+// their message pending for ResumeCampaign to queue again.
+func (svc *Service) PauseCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	var record *repository.Campaign
+	var held []string
+	err := svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		record, err = q.PauseCampaign(ctx, &repository.PauseCampaignParams{
+			CampaignID:   campaignID,
+			FromStatuses: domain.CampaignTransitionSources(domain.CampaignPaused),
+		})
+		if err != nil {
+			return err
+		}
+
+		held, err = q.ListPendingCampaignTaskIds(ctx, campaignID)
+		return err
+	})
+	if stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, svc.campaignTransitionError(ctx, campaignID, domain.CampaignPaused)
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	svc.deleteTasks(held)
+
+	return record, nil
+}
+
+// ResumeCampaign puts a paused campaign back to work. Every pending message
+// gets a new task, due at the campaign's scheduled time if that is still
+// ahead, and tasks from before the pause are refused by their messages.
+func (svc *Service) ResumeCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	campaign, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	var record *repository.Campaign
+	var replaced []string
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		record, err = q.ResumeCampaign(ctx, &repository.ResumeCampaignParams{
+			Now:        pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			CampaignID: campaignID,
+		})
+		if err != nil {
+			return err
+		}
+
+		replaced, err = svc.requeueCampaignMessages(ctx, q, campaign, nil, false, true)
+		return err
+	})
+	if stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, transitionError(fmt.Errorf(
+			"%w: campaign %d is %s, not %s",
+			domain.ErrIllegalTransition, campaignID, campaign.Status, domain.CampaignPaused,
+		))
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "UPDATE_CAMPAIGN_ERROR")
+	}
+
+	svc.deleteTasks(replaced)
+
+	// Messages that were in flight when the campaign was paused may have been
+	// the last ones, and a paused campaign does not roll up.
+	if record.Status == domain.CampaignSending {
+		finalized, err := svc.repository.FinalizeCampaign(ctx, campaignID)
+		if err != nil {
+			return nil, err
+		}
+		if finalized != nil {
+			return finalized, nil
+		}
+	}
+
+	return record, nil
+}
+
+// campaignTransitionError explains why moving a campaign to status matched
+// nothing.
+func (svc *Service) campaignTransitionError(ctx context.Context, campaignID int64, status string) error {
+	current, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+
+	return refusedTransition(current, status)
+}
+
+func refusedTransition(campaign *repository.GetCampaignRow, status string) error {
+	return transitionError(fmt.Errorf(
+		"%w: campaign %d cannot move from %s to %s",
+		domain.ErrIllegalTransition, campaign.ID, campaign.Status, status,
+	))
+}
+
+// cancelCampaignMessages cancels the pending messages of a campaign and
+// records why on their timelines. It returns the IDs of their tasks.
+func cancelCampaignMessages(ctx context.Context, q *repository.Queries, campaignID int64, reason string) ([]string, error) {
+	rows, err := q.CancelCampaignMessages(ctx, campaignID)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	detail, err := json.Marshal(cancelledDetail{Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(rows))
+	var taskIDs []string
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+		if row.TaskID.Valid {
+			taskIDs = append(taskIDs, row.TaskID.String)
+		}
+	}
+
+	err = q.CreateMessageEvents(ctx, &repository.CreateMessageEventsParams{
+		MessageIds: ids,
+		EventType:  domain.MessageEventStatusChanged,
+		Status:     domain.MessageCancelled,
+		Detail:     detail,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return taskIDs, nil
+}
+
+// deleteTasks removes tasks that their messages no longer expect from the
+// queue. It runs in the background, so that a campaign with many messages
+// does not hold up the request, and Stop waits for it. It is best effort and
+// only logs failures: a task that is missed, or that the relay has yet to
+// publish, is refused by its message when it runs.
+func (svc *Service) deleteTasks(taskIDs []string) {
+	if len(taskIDs) == 0 {
+		return
+	}
+
+	svc.background.Add(1)
+	go func() {
+		defer svc.background.Done()
+
+		failed := 0
+		for _, id := range taskIDs {
+			err := svc.inspector.DeleteTask(svc.queue, id)
+			if err != nil && !stderrors.Is(err, asynq.ErrTaskNotFound) {
+				failed++
+				svc.logger.Warn("could not delete task", zap.String("task_id", id), zap.Error(err))
+			}
+		}
+
+		svc.logger.Info("deleted tasks", zap.Int("count", len(taskIDs)-failed), zap.Int("failed", failed))
+	}()
+}
+
+// missingAudience refuses to schedule a campaign the scheduler would have no
+// one to send to.
+func missingAudience() error {
+	return validationError(&fieldError{
+		field:       "segment_id",
+		structField: "SegmentID",
+		tag:         "required_with",
+		param:       "ScheduledAt",
+	})
+}
+
+func campaignModified(campaignID int64) error {
+	return errors.WrapError(
+		fmt.Errorf("campaign %d was changed since it was read", campaignID),
+		errors.Aborted,
+		"CAMPAIGN_MODIFIED",
+	)
+}
+
+func campaignNotEditable(campaign *repository.GetCampaignRow) error {
+	return errors.WrapError(
+		fmt.Errorf("campaign %d is %s", campaign.ID, campaign.Status),
+		errors.FailedPrecondition,
+		"CAMPAIGN_NOT_EDITABLE",
+	)
+}
+
+func dispatchInProgress(campaignID int64) error {
+	return errors.WrapError(
+		fmt.Errorf("campaign %d has a dispatch in progress", campaignID),
+		errors.FailedPrecondition,
+		"CAMPAIGN_DISPATCH_IN_PROGRESS",
+	)
+}
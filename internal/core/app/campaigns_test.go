@@ -0,0 +1,185 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditedCampaign(t *testing.T) {
+	scheduled := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	current := &repository.GetCampaignRow{
+		ID:           1,
+		Name:         "Launch",
+		Status:       domain.CampaignScheduled,
+		BaseTemplate: "Hi {FirstName}",
+		ScheduledAt:  pgtype.Timestamptz{Time: scheduled, Valid: true},
+		MaxSegments:  pgtype.Int4{Int32: 2, Valid: true},
+	}
+
+	name, uncapped := "Relaunch", int32(0)
+	edited, err := editedCampaign(current, &domain.UpdateCampaign{Name: &name, MaxSegments: &uncapped})
+	assert.NoError(t, err)
+	assert.Equal(t, "Relaunch", edited.Name)
+	assert.Equal(t, current.BaseTemplate, edited.BaseTemplate)
+	assert.False(t, edited.MaxSegments.Valid)
+	assert.Equal(t, domain.CampaignScheduled, edited.Status)
+	assert.Equal(t, "Launch", current.Name, "the loaded campaign is left alone")
+
+	unscheduled := ""
+	edited, err = editedCampaign(current, &domain.UpdateCampaign{ScheduledAt: &unscheduled})
+	assert.NoError(t, err)
+	assert.False(t, edited.ScheduledAt.Valid)
+	assert.Equal(t, domain.CampaignDraft, edited.Status)
+
+	invalid := "tomorrow"
+	_, err = editedCampaign(current, &domain.UpdateCampaign{ScheduledAt: &invalid})
+	assert.Error(t, err)
+
+	rescheduled := "2030-02-01T09:00:00Z"
+	_, err = editedCampaign(current, &domain.UpdateCampaign{ScheduledAt: &rescheduled})
+	assert.Error(t, err, "a schedule needs an audience")
+
+	segmentID := int64(3)
+	withCustomers := *current
+	withCustomers.CustomerIds = []int64{1, 2}
+	edited, err = editedCampaign(&withCustomers, &domain.UpdateCampaign{ScheduledAt: &rescheduled, SegmentID: &segmentID})
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Int8{Int64: 3, Valid: true}, edited.SegmentID)
+	assert.Nil(t, edited.CustomerIds)
+	assert.Equal(t, domain.CampaignScheduled, edited.Status)
+
+	weekly, nairobi := "0 9 * * 1", "Africa/Nairobi"
+	edited, err = editedCampaign(&withCustomers, &domain.UpdateCampaign{Recurrence: &weekly, Timezone: &nairobi})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Monday, edited.ScheduledAt.Time.Weekday())
+	assert.Equal(t, 6, edited.ScheduledAt.Time.Hour())
+	assert.True(t, edited.ScheduledAt.Time.After(time.Now()))
+	assert.Equal(t, domain.CampaignScheduled, edited.Status)
+
+	never := "0 0 30 2 *"
+	_, err = editedCampaign(&withCustomers, &domain.UpdateCampaign{Recurrence: &never})
+	assert.Error(t, err)
+
+	local := "2030-02-01T10:00:00"
+	edited, err = editedCampaign(&withCustomers, &domain.UpdateCampaign{ScheduledAt: &local, Timezone: &nairobi})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2030, 2, 1, 7, 0, 0, 0, time.UTC), edited.ScheduledAt.Time, "read in the timezone set by the same edit")
+}
+
+// guardedTransition answers the status update of a campaign like the
+// database: it matches only while the campaign is in one of the statuses
+// allowed, and moves it to status.
+func guardedTransition(repo *fakeRepository, status string, allowed func(args []interface{}) []string) func([]interface{}) fakeRow {
+	return func(args []interface{}) fakeRow {
+		c := repo.campaigns[1]
+		if !slices.Contains(allowed(args), c.Status) {
+			return fakeRow{err: pgx.ErrNoRows}
+		}
+
+		c.Status = status
+		return fakeRow{values: []any{c.ID, c.Name, c.Channel, c.Status}}
+	}
+}
+
+func fromStatuses(args []interface{}) []string { return args[1].([]string) }
+
+func fromPaused([]interface{}) []string { return []string{domain.CampaignPaused} }
+
+func TestCancelCampaign(t *testing.T) {
+	svc, repo := newFakeService(&repository.Campaign{ID: 1, Status: domain.CampaignSending})
+	repo.db.rows["CancelCampaign"] = guardedTransition(repo, domain.CampaignCancelled, fromStatuses)
+	repo.db.results["CancelCampaignMessages"] = [][]any{
+		{int64(1), pgtype.Text{String: "outbox:1", Valid: true}},
+		{int64(2), pgtype.Text{}},
+		{int64(3), pgtype.Text{String: "outbox:3", Valid: true}},
+	}
+
+	record, err := svc.CancelCampaign(context.Background(), 1)
+	svc.Stop()
+	assert.NoError(t, err)
+	assert.Equal(t, domain.CampaignCancelled, record.Status)
+	assert.Equal(t, []string{"outbox:1", "outbox:3"}, svc.inspector.(*fakeInspector).deleted)
+}
+
+func TestPauseCampaign(t *testing.T) {
+	svc, repo := newFakeService(&repository.Campaign{ID: 1, Status: domain.CampaignSending})
+	repo.db.rows["PauseCampaign"] = guardedTransition(repo, domain.CampaignPaused, fromStatuses)
+	repo.db.results["ListPendingCampaignTaskIds"] = [][]any{{"outbox:1"}, {"outbox:2"}}
+
+	record, err := svc.PauseCampaign(context.Background(), 1)
+	svc.Stop()
+	assert.NoError(t, err)
+	assert.Equal(t, domain.CampaignPaused, record.Status)
+	assert.Equal(t, []string{"outbox:1", "outbox:2"}, svc.inspector.(*fakeInspector).deleted)
+}
+
+func TestResumeCampaign(t *testing.T) {
+	svc, repo := newFakeService(&repository.Campaign{ID: 1, Status: domain.CampaignPaused})
+	repo.db.rows["ResumeCampaign"] = guardedTransition(repo, domain.CampaignSending, fromPaused)
+	repo.db.results["ListPendingCampaignMessages"] = [][]any{
+		{int64(1), int64(10), pgtype.Text{String: "outbox:1", Valid: true}},
+		{int64(2), int64(11), pgtype.Text{}},
+	}
+	var nextID int64 = 100
+	repo.db.rows["CreateOutboxEntry"] = func([]interface{}) fakeRow {
+		nextID++
+		return fakeRow{values: []any{nextID}}
+	}
+
+	record, err := svc.ResumeCampaign(context.Background(), 1)
+	svc.Stop()
+	assert.NoError(t, err)
+	assert.Equal(t, domain.CampaignSending, record.Status)
+	assert.Equal(t, []interface{}{[]int64{1, 2}, []string{"outbox:101", "outbox:102"}}, repo.db.args["SetOutboundMessageTaskIds"])
+	assert.Equal(t, []string{"outbox:1"}, svc.inspector.(*fakeInspector).deleted, "only the tasks replaced are deleted")
+	assert.Equal(t, []int64{1}, repo.finalized)
+}
+
+func TestCampaignTransitions_Refused(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		query  string
+		to     string
+		from   func([]interface{}) []string
+		call   func(*Service) (*repository.Campaign, error)
+	}{
+		{"cancel", domain.CampaignSent, "CancelCampaign", domain.CampaignCancelled, fromStatuses, func(svc *Service) (*repository.Campaign, error) {
+			return svc.CancelCampaign(context.Background(), 1)
+		}},
+		{"pause", domain.CampaignDraft, "PauseCampaign", domain.CampaignPaused, fromStatuses, func(svc *Service) (*repository.Campaign, error) {
+			return svc.PauseCampaign(context.Background(), 1)
+		}},
+		{"resume", domain.CampaignSending, "ResumeCampaign", domain.CampaignSending, fromPaused, func(svc *Service) (*repository.Campaign, error) {
+			return svc.ResumeCampaign(context.Background(), 1)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := newFakeService(&repository.Campaign{ID: 1, Status: tt.status})
+			repo.db.rows[tt.query] = guardedTransition(repo, tt.to, tt.from)
+			repo.db.results["CancelCampaignMessages"] = [][]any{{int64(1), pgtype.Text{String: "outbox:1", Valid: true}}}
+			repo.db.results["ListPendingCampaignTaskIds"] = [][]any{{"outbox:1"}}
+			repo.db.results["ListPendingCampaignMessages"] = [][]any{{int64(1), int64(10), pgtype.Text{String: "outbox:1", Valid: true}}}
+
+			_, err := tt.call(svc)
+			svc.Stop()
+			_, ok := err.(*errors.Error)
+			assert.True(t, ok, "a refused transition surfaces as a typed error")
+			assert.Equal(t, tt.status, repo.campaigns[1].Status)
+			assert.Equal(t, []string{tt.query}, repo.db.queries, "nothing else runs in the transaction")
+			assert.Equal(t, 1, repo.reads, "the campaign is read once to explain the refusal")
+			assert.Empty(t, svc.inspector.(*fakeInspector).deleted)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+func (svc *Service) AddCustomer(ctx context.Context, payload *domain.CreateCustomer) (*repository.Customer, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	args := repository.CreateCustomerParams{
+		Phone:            payload.Phone,
+		FirstName:        nullableText(payload.FirstName),
+		LastName:         nullableText(payload.LastName),
+		Location:         nullableText(payload.Location),
+		PreferredProduct: nullableText(payload.PreferredProduct),
+	}
+
+	return svc.repository.AddCustomer(ctx, &args)
+}
+
+func (svc *Service) ListCustomers(ctx context.Context, pageNumber, pageSize int, filters *domain.CustomersFilter) ([]*repository.ListCustomersRow, error) {
+	args := repository.ListCustomersParams{
+		Location:         filters.Location,
+		PreferredProduct: filters.PreferredProduct,
+		Phone:            filters.Phone,
+		PageNumber:       pageNumber,
+		PageSize:         int32(pageSize),
+	}
+
+	return svc.repository.ListCustomers(ctx, &args)
+}
+
+func (svc *Service) RetrieveCustomer(ctx context.Context, customerID int64) (*repository.Customer, error) {
+	return svc.repository.GetCustomer(ctx, customerID)
+}
+
+func (svc *Service) UpdateCustomer(ctx context.Context, customerID int64, payload *domain.UpdateCustomer) (*repository.Customer, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	args := repository.UpdateCustomerParams{
+		Phone:            payload.Phone,
+		FirstName:        nullableText(payload.FirstName),
+		LastName:         nullableText(payload.LastName),
+		Location:         nullableText(payload.Location),
+		PreferredProduct: nullableText(payload.PreferredProduct),
+		CustomerID:       customerID,
+	}
+
+	return svc.repository.UpdateCustomer(ctx, &args)
+}
+
+func (svc *Service) DeleteCustomer(ctx context.Context, customerID int64) error {
+	return svc.repository.DeleteCustomer(ctx, customerID)
+}
@@ -0,0 +1,590 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/template"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// dispatchMaxRetry bounds how often asynq redelivers a failed dispatch task.
+// Every attempt resumes from the last checkpoint.
+const dispatchMaxRetry = 5
+
+// dispatchOutcomesLimit caps how many skipped and failed customers a dispatch
+// result lists inline. The rest are paged through ListDispatchOutcomes.
+const dispatchOutcomesLimit = 1000
+
+// errCampaignCancelled stops a dispatch whose campaign was cancelled. Like a
+// customerFailure it is final.
+var errCampaignCancelled = stderrors.New("campaign was cancelled")
+
+// customerFailure stops a fail-fast dispatch at the first customer that could
+// not be queued. Unlike other errors it is final, so the job is not retried.
+type customerFailure struct {
+	customerID int64
+	reason     string
+}
+
+func (e *customerFailure) Error() string {
+	return fmt.Sprintf("customer %d: %s", e.customerID, e.reason)
+}
+
+// RetrieveCampaignDispatch reports the progress of a dispatch job of the given
+// campaign together with the customers it skipped or failed so far.
+func (svc *Service) RetrieveCampaignDispatch(ctx context.Context, campaignID, dispatchID int64) (*domain.SendCampaignResult, error) {
+	dispatch, err := svc.campaignDispatch(ctx, campaignID, dispatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.dispatchResult(ctx, dispatch)
+}
+
+// ListDispatchOutcomes pages through the per-customer outcomes of a dispatch
+// job, optionally restricted to the given outcomes.
+func (svc *Service) ListDispatchOutcomes(
+	ctx context.Context,
+	campaignID, dispatchID int64,
+	pageNumber, pageSize int,
+	outcomes []string,
+) ([]*repository.ListCampaignDispatchOutcomesRow, error) {
+	if _, err := svc.campaignDispatch(ctx, campaignID, dispatchID); err != nil {
+		return nil, err
+	}
+
+	if outcomes == nil {
+		outcomes = []string{}
+	}
+
+	return svc.repository.ListCampaignDispatchOutcomes(ctx, &repository.ListCampaignDispatchOutcomesParams{
+		DispatchID: dispatchID,
+		Outcomes:   outcomes,
+		PageSize:   int32(pageSize),
+		PageNumber: pageNumber,
+	})
+}
+
+func (svc *Service) campaignDispatch(ctx context.Context, campaignID, dispatchID int64) (*repository.CampaignDispatch, error) {
+	dispatch, err := svc.repository.GetCampaignDispatch(ctx, dispatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dispatch.CampaignID != campaignID {
+		return nil, errors.WrapError(
+			fmt.Errorf("dispatch %d does not belong to campaign %d", dispatchID, campaignID),
+			errors.NotFound,
+			"CAMPAIGN_DISPATCH_NOT_FOUND",
+		)
+	}
+
+	return dispatch, nil
+}
+
+func (svc *Service) dispatchResult(ctx context.Context, dispatch *repository.CampaignDispatch) (*domain.SendCampaignResult, error) {
+	rows, err := svc.repository.ListCampaignDispatchOutcomes(ctx, &repository.ListCampaignDispatchOutcomesParams{
+		DispatchID: dispatch.ID,
+		Outcomes: []string{
+			domain.OutcomeSkippedNotFound,
+			domain.OutcomeSkippedDuplicate,
+			domain.OutcomeSkippedAlreadySent,
+			domain.OutcomeFailed,
+		},
+		PageSize:   dispatchOutcomesLimit,
+		PageNumber: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.SendCampaignResult{
+		DispatchID:     dispatch.ID,
+		CampaignID:     dispatch.CampaignID,
+		Status:         dispatch.Status,
+		Mode:           dispatch.Mode,
+		TotalCustomers: dispatch.TotalCustomers,
+		Queued:         dispatch.QueuedMessages,
+		Skipped:        dispatch.SkippedCustomers,
+		Failed:         dispatch.FailedCustomers,
+		LastError:      dispatch.LastError.String,
+		Outcomes:       make([]*domain.CustomerOutcome, 0, len(rows)),
+	}
+	for _, row := range rows {
+		result.Outcomes = append(result.Outcomes, &domain.CustomerOutcome{
+			CustomerID: row.CustomerID,
+			Outcome:    row.Outcome,
+			Reason:     row.Reason.String,
+		})
+	}
+	if len(rows) > 0 {
+		result.OutcomesTruncated = rows[0].TotalCount > int64(len(rows))
+	}
+
+	return result, nil
+}
+
+// RunDispatch fans a dispatch job out over its audience in pages of customers
+// ordered by ID. Each page is rendered and stored together with its outbox
+// entries and the new checkpoint in one transaction, so a job interrupted by a
+// crash resumes after the last committed page without queueing anyone twice.
+func (svc *Service) RunDispatch(ctx context.Context, dispatchID int64) (*repository.CampaignDispatch, error) {
+	dispatch, err := svc.repository.GetCampaignDispatch(ctx, dispatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dispatch.Status == domain.DispatchCompleted {
+		// A redelivery after the roll-up below failed.
+		if _, err := svc.repository.FinalizeCampaign(ctx, dispatch.CampaignID); err != nil {
+			return nil, err
+		}
+
+		return dispatch, nil
+	}
+
+	dispatch, err = svc.repository.StartCampaignDispatch(ctx, dispatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.dispatch(ctx, dispatch)
+	if failure := (*customerFailure)(nil); stderrors.As(err, &failure) || stderrors.Is(err, errCampaignCancelled) {
+		// A fail-fast job stopped at a customer, or its campaign was cancelled;
+		// retrying would stop there again.
+		dispatch, err = svc.repository.FinishCampaignDispatch(ctx, &repository.FinishCampaignDispatchParams{
+			Status:     domain.DispatchFailed,
+			LastError:  pgtype.Text{String: err.Error(), Valid: true},
+			DispatchID: dispatchID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := svc.repository.FinalizeCampaign(ctx, dispatch.CampaignID); err != nil {
+			return nil, err
+		}
+
+		return dispatch, nil
+	}
+
+	if err != nil {
+		// Record the failure even when ctx was what stopped the job.
+		_, _ = svc.repository.FinishCampaignDispatch(context.WithoutCancel(ctx), &repository.FinishCampaignDispatchParams{
+			Status:     domain.DispatchFailed,
+			LastError:  pgtype.Text{String: err.Error(), Valid: true},
+			DispatchID: dispatchID,
+		})
+		_, _ = svc.repository.FinalizeCampaign(context.WithoutCancel(ctx), dispatch.CampaignID)
+
+		return nil, err
+	}
+
+	dispatch, err = svc.repository.FinishCampaignDispatch(ctx, &repository.FinishCampaignDispatchParams{
+		Status:     domain.DispatchCompleted,
+		DispatchID: dispatchID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The campaign could not roll up while the job was running, so the last
+	// page's messages may already all have been delivered.
+	if _, err := svc.repository.FinalizeCampaign(ctx, dispatch.CampaignID); err != nil {
+		return nil, err
+	}
+
+	return dispatch, nil
+}
+
+func (svc *Service) dispatch(ctx context.Context, dispatch *repository.CampaignDispatch) error {
+	campaign, err := svc.repository.GetCampaign(ctx, dispatch.CampaignID)
+	if err != nil {
+		return err
+	}
+
+	// A campaign paused after the job was created still gets its messages;
+	// they wait for it to resume.
+	var tmpl *template.Template
+	switch campaign.Status {
+	case domain.CampaignCancelled:
+		return errCampaignCancelled
+	case domain.CampaignPaused:
+		tmpl, _, err = compileTemplate(campaign.BaseTemplate, "base_template", "BaseTemplate", campaign.LenientTemplates)
+	default:
+		tmpl, err = sendableTemplate(campaign)
+	}
+	if err != nil {
+		return err
+	}
+
+	// An earlier attempt may have queued pages without getting to mark the
+	// campaign as sending. A campaign scheduled for later stays scheduled, so
+	// that it can still be edited; the first of its messages to run starts it.
+	// A paused campaign is left for ResumeCampaign to start.
+	later := campaign.ScheduledAt.Valid && campaign.ScheduledAt.Time.After(time.Now())
+	sending := false
+	if dispatch.QueuedMessages > 0 && !later && campaign.Status != domain.CampaignPaused {
+		if _, err := svc.markCampaignSending(ctx, campaign.ID); err != nil {
+			return err
+		}
+		sending = true
+	}
+
+	afterID := dispatch.LastCustomerID
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The campaign may have been paused or cancelled since the last page.
+		status, err := svc.repository.GetCampaignStatus(ctx, campaign.ID)
+		if err != nil {
+			return err
+		}
+		if status == domain.CampaignCancelled {
+			return errCampaignCancelled
+		}
+
+		page, err := svc.dispatchPage(ctx, dispatch, afterID)
+		if err != nil {
+			return err
+		}
+
+		last := len(page) < int(svc.dispatchBatchSize)
+		entries := svc.pageEntries(dispatch, campaign, tmpl, afterID, page, last)
+		queued, err := svc.queuePage(ctx, dispatch, campaign, entries)
+		if queued > 0 && !sending && !later && status != domain.CampaignPaused {
+			if _, err := svc.markCampaignSending(ctx, campaign.ID); err != nil {
+				return err
+			}
+			sending = true
+		}
+
+		if err != nil || last {
+			return err
+		}
+
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// dispatchPage loads the next page of the job's audience after afterID.
+func (svc *Service) dispatchPage(ctx context.Context, dispatch *repository.CampaignDispatch, afterID int64) ([]*repository.Customer, error) {
+	if dispatch.SegmentID.Valid {
+		return svc.repository.ListSegmentCustomers(ctx, &repository.ListSegmentCustomersParams{
+			SegmentID: dispatch.SegmentID.Int64,
+			AfterID:   afterID,
+			BatchSize: svc.dispatchBatchSize,
+		})
+	}
+
+	return svc.repository.ListCustomersByIds(ctx, &repository.ListCustomersByIdsParams{
+		CustomerIds: dispatch.CustomerIds,
+		AfterID:     afterID,
+		BatchSize:   svc.dispatchBatchSize,
+	})
+}
+
+// queuedDetail is stored with the queued event of every message a dispatch
+// creates.
+type queuedDetail struct {
+	DispatchID int64 `json:"dispatch_id"`
+}
+
+// pageEntry is what a dispatch does for one customer of a page: queue the
+// rendered message, or record why the customer was left out.
+type pageEntry struct {
+	customerID int64
+	message    *repository.CreateOutboundMessageParams
+	outcome    string
+	reason     string
+}
+
+// pageEntries renders the campaign for a page of customers in ID order. For
+// jobs with an explicit audience it also reports the requested customers in
+// the page's ID range that no longer exist. A fail-fast job stops at the
+// first customer that cannot be queued.
+func (svc *Service) pageEntries(
+	dispatch *repository.CampaignDispatch,
+	campaign *repository.GetCampaignRow,
+	tmpl *template.Template,
+	afterID int64,
+	page []*repository.Customer,
+	last bool,
+) []*pageEntry {
+	var missing []int64
+	if !dispatch.SegmentID.Valid {
+		upTo := int64(-1)
+		if !last {
+			upTo = page[len(page)-1].ID
+		}
+		missing = missingCustomers(dispatch.CustomerIds, afterID, upTo, page)
+	}
+
+	entries := make([]*pageEntry, 0, len(page)+len(missing))
+	for i := 0; i < len(page) || len(missing) > 0; {
+		var entry *pageEntry
+		if len(missing) > 0 && (i == len(page) || missing[0] < page[i].ID) {
+			entry = &pageEntry{
+				customerID: missing[0],
+				outcome:    domain.OutcomeSkippedNotFound,
+				reason:     "customer does not exist",
+			}
+			missing = missing[1:]
+		} else {
+			entry = svc.pageEntry(campaign, tmpl, page[i])
+			i++
+		}
+
+		entries = append(entries, entry)
+		if entry.message == nil && dispatch.Mode == domain.SendFailFast {
+			break
+		}
+	}
+
+	return entries
+}
+
+func (svc *Service) pageEntry(campaign *repository.GetCampaignRow, tmpl *template.Template, customer *repository.Customer) *pageEntry {
+	message, err := renderMessage(campaign, tmpl, customer)
+	if err != nil {
+		return &pageEntry{
+			customerID: customer.ID,
+			outcome:    domain.OutcomeFailed,
+			reason:     err.Error(),
+		}
+	}
+
+	arg := &repository.CreateOutboundMessageParams{
+		CampaignID:      campaign.ID,
+		CustomerID:      customer.ID,
+		Status:          domain.MessagePending,
+		RenderedContent: message.Content,
+	}
+	if message.SMS != nil {
+		arg.Encoding = pgtype.Text{String: message.SMS.Encoding, Valid: true}
+		arg.Segments = pgtype.Int4{Int32: int32(message.SMS.Segments), Valid: true}
+	}
+
+	return &pageEntry{customerID: customer.ID, message: arg, outcome: domain.OutcomeQueued}
+}
+
+// missingCustomers returns the requested IDs after afterID, and up to upTo
+// unless it is negative, that are absent from page.
+func missingCustomers(requested []int64, afterID, upTo int64, page []*repository.Customer) []int64 {
+	found := make(map[int64]struct{}, len(page))
+	for _, customer := range page {
+		found[customer.ID] = struct{}{}
+	}
+
+	var missing []int64
+	for _, id := range requested {
+		if id <= afterID || (upTo >= 0 && id > upTo) {
+			continue
+		}
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	slices.Sort(missing)
+
+	return missing
+}
+
+// queuePage stores the outbound messages of a page, their outbox entries, the
+// outcome of every customer and the job checkpoint in a single transaction.
+// Every new message also gets its queued event. The relay publishes the
+// entries once it has committed, and every message remembers the ID of the
+// task that will deliver it. It returns how many messages were queued, and
+// a customerFailure when a fail-fast job has to stop at the page's last entry.
+func (svc *Service) queuePage(
+	ctx context.Context,
+	dispatch *repository.CampaignDispatch,
+	campaign *repository.GetCampaignRow,
+	entries []*pageEntry,
+) (int32, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	detail, err := json.Marshal(queuedDetail{DispatchID: dispatch.ID})
+	if err != nil {
+		return 0, err
+	}
+
+	var out *outcomes
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		out = newOutcomes(len(entries))
+		out.DispatchID = dispatch.ID
+		queued := make([]int64, 0, len(entries))
+		taskIDs := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if entry.message == nil {
+				out.add(entry.customerID, entry.outcome, entry.reason, 0)
+				continue
+			}
+
+			msg, err := createCampaignMessage(ctx, q, entry.message, dispatch.ResendReason)
+			if stderrors.Is(err, pgx.ErrNoRows) {
+				out.add(entry.customerID, domain.OutcomeSkippedAlreadySent, "customer already has a message for this campaign", 0)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			outboxEntry, err := svc.outboxEntry(domain.SendMessageTask, domain.SendMessage{MessageID: msg.ID}, campaign.ScheduledAt)
+			if err != nil {
+				return err
+			}
+
+			created, err := q.CreateOutboxEntry(ctx, outboxEntry)
+			if err != nil {
+				return err
+			}
+
+			out.add(entry.customerID, domain.OutcomeQueued, "", msg.ID)
+			queued = append(queued, msg.ID)
+			taskIDs = append(taskIDs, domain.OutboxTaskID(created.ID))
+		}
+
+		if err := q.CreateCampaignDispatchOutcomes(ctx, &out.CreateCampaignDispatchOutcomesParams); err != nil {
+			return err
+		}
+
+		if len(queued) > 0 {
+			err := q.SetOutboundMessageTaskIds(ctx, &repository.SetOutboundMessageTaskIdsParams{
+				MessageIds: queued,
+				TaskIds:    taskIDs,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = q.CreateMessageEvents(ctx, &repository.CreateMessageEventsParams{
+				MessageIds: queued,
+				EventType:  domain.MessageEventQueued,
+				Status:     domain.MessagePending,
+				Detail:     detail,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := q.RecordCampaignDispatchProgress(ctx, &repository.RecordCampaignDispatchProgressParams{
+			Queued:         out.queued,
+			Skipped:        out.skipped,
+			Failed:         out.failed,
+			LastCustomerID: entries[len(entries)-1].customerID,
+			DispatchID:     dispatch.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, errors.WrapError(err, errors.Internal, "QUEUE_MESSAGES_ERROR")
+	}
+
+	if stop := entries[len(entries)-1]; stop.message == nil && dispatch.Mode == domain.SendFailFast {
+		return out.queued, &customerFailure{customerID: stop.customerID, reason: stop.reason}
+	}
+
+	return out.queued, nil
+}
+
+// createCampaignMessage stores a campaign message unless the customer already
+// has one for the campaign, in which case it returns pgx.ErrNoRows. A resend
+// stores the repeat with its reason, which exempts it from that uniqueness.
+func createCampaignMessage(
+	ctx context.Context,
+	q *repository.Queries,
+	arg *repository.CreateOutboundMessageParams,
+	resendReason pgtype.Text,
+) (*repository.OutboundMessage, error) {
+	msg, err := q.CreateOutboundMessage(ctx, arg)
+	if !stderrors.Is(err, pgx.ErrNoRows) || !resendReason.Valid {
+		return msg, err
+	}
+
+	resend := *arg
+	resend.ResendReason = resendReason
+	return q.CreateOutboundMessage(ctx, &resend)
+}
+
+// outcomes collects per-customer outcomes for a single insert and tallies them
+// for the job's counters.
+type outcomes struct {
+	repository.CreateCampaignDispatchOutcomesParams
+	queued, skipped, failed int32
+}
+
+func newOutcomes(size int) *outcomes {
+	return &outcomes{
+		CreateCampaignDispatchOutcomesParams: repository.CreateCampaignDispatchOutcomesParams{
+			CustomerIds: make([]int64, 0, size),
+			Outcomes:    make([]string, 0, size),
+			Reasons:     make([]string, 0, size),
+			MessageIds:  make([]int64, 0, size),
+		},
+	}
+}
+
+func (o *outcomes) add(customerID int64, outcome, reason string, messageID int64) {
+	o.CustomerIds = append(o.CustomerIds, customerID)
+	o.Outcomes = append(o.Outcomes, outcome)
+	o.Reasons = append(o.Reasons, reason)
+	o.MessageIds = append(o.MessageIds, messageID)
+
+	switch outcome {
+	case domain.OutcomeQueued:
+		o.queued++
+	case domain.OutcomeFailed:
+		o.failed++
+	default:
+		o.skipped++
+	}
+}
+
+// splitAudience checks the requested customer IDs against the ones that exist.
+// It returns the IDs to dispatch in ascending order, the outcomes of repeated
+// and unknown IDs, and the unknown IDs themselves.
+func splitAudience(requested, existing []int64) ([]int64, *outcomes, []int64) {
+	known := make(map[int64]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+
+	ids := make([]int64, 0, len(existing))
+	out := newOutcomes(0)
+	var missing []int64
+	seen := make(map[int64]bool, len(requested))
+	for _, id := range requested {
+		switch {
+		case seen[id]:
+			out.add(id, domain.OutcomeSkippedDuplicate, "customer was requested more than once", 0)
+		case !known[id]:
+			missing = append(missing, id)
+		default:
+			ids = append(ids, id)
+		}
+		seen[id] = true
+	}
+
+	slices.Sort(ids)
+	slices.Sort(missing)
+	for _, id := range missing {
+		out.add(id, domain.OutcomeSkippedNotFound, "customer does not exist", 0)
+	}
+
+	return ids, out, missing
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAudience(t *testing.T) {
+	ids, out, missing := splitAudience([]int64{7, 3, 9, 3, 4, 7}, []int64{3, 7, 9})
+
+	assert.Equal(t, []int64{3, 7, 9}, ids)
+	assert.Equal(t, []int64{4}, missing)
+	assert.Equal(t, []int64{3, 7, 4}, out.CustomerIds)
+	assert.Equal(t, []string{
+		domain.OutcomeSkippedDuplicate,
+		domain.OutcomeSkippedDuplicate,
+		domain.OutcomeSkippedNotFound,
+	}, out.Outcomes)
+	assert.Equal(t, int32(3), out.skipped)
+	assert.Zero(t, out.queued)
+	assert.Zero(t, out.failed)
+}
+
+func TestMissingCustomers(t *testing.T) {
+	requested := []int64{2, 4, 6, 8, 10}
+	page := []*repository.Customer{{ID: 4}, {ID: 8}}
+
+	assert.Equal(t, []int64{6}, missingCustomers(requested, 2, 8, page))
+	assert.Equal(t, []int64{6, 10}, missingCustomers(requested, 2, -1, page))
+	assert.Equal(t, []int64{10}, missingCustomers(requested, 8, -1, nil))
+}
+
+func TestRunDispatch_ResumesFromCheckpoint(t *testing.T) {
+	svc, repo := newFakeService(&repository.Campaign{ID: 1, Channel: "sms", Status: domain.CampaignDraft, BaseTemplate: "Hi {FirstName}"})
+	svc.dispatchBatchSize = 2
+	for id := int64(1); id <= 5; id++ {
+		repo.customers[id] = &repository.Customer{ID: id, Phone: "+254700000000"}
+	}
+	repo.dispatches[1] = &repository.CampaignDispatch{
+		ID:          1,
+		CampaignID:  1,
+		Status:      domain.DispatchPending,
+		Mode:        domain.SendBestEffort,
+		CustomerIds: []int64{1, 2, 3, 4, 5},
+	}
+
+	// Every committed page moves the checkpoint; the job is stopped once the
+	// second one is in, as a crash or shutdown would.
+	ctx, stop := context.WithCancel(context.Background())
+	pages := 0
+	created := map[int64]int{}
+	var nextID int64
+	repo.db.rows["CreateOutboundMessage"] = func(args []interface{}) fakeRow {
+		created[args[1].(int64)]++
+		nextID++
+		return fakeRow{values: []any{nextID}}
+	}
+	repo.db.rows["CreateOutboxEntry"] = func([]interface{}) fakeRow {
+		nextID++
+		return fakeRow{values: []any{nextID}}
+	}
+	repo.db.rows["RecordCampaignDispatchProgress"] = func(args []interface{}) fakeRow {
+		d := repo.dispatches[args[4].(int64)]
+		d.QueuedMessages += args[0].(int32)
+		d.LastCustomerID = args[3].(int64)
+		if pages++; pages == 2 {
+			stop()
+		}
+		return fakeRow{values: []any{d.ID}}
+	}
+
+	_, err := svc.RunDispatch(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, domain.DispatchFailed, repo.dispatches[1].Status)
+	assert.Equal(t, int64(4), repo.dispatches[1].LastCustomerID)
+	assert.Equal(t, map[int64]int{1: 1, 2: 1, 3: 1, 4: 1}, created)
+
+	dispatch, err := svc.RunDispatch(context.Background(), 1)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, domain.DispatchCompleted, dispatch.Status)
+	assert.Equal(t, int32(5), dispatch.QueuedMessages)
+	assert.Equal(t, map[int64]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1}, created, "the second run starts after the checkpoint")
+	assert.Equal(t, domain.CampaignSending, repo.campaigns[1].Status)
+}
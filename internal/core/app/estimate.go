@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// EstimateCampaign projects the number of messages, SMS segments and cost of
+// sending a campaign to the audience described by payload. Messages are
+// rendered exactly as SendCampaign would render them; audiences larger than
+// the configured sample size are estimated from a uniform random sample.
+// Requested customer IDs are narrowed to the customers a send would queue.
+func (svc *Service) EstimateCampaign(ctx context.Context, campaignID int64, payload *domain.SendCampaign) (*domain.CampaignEstimate, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	campaign, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, _, err := compileTemplate(campaign.BaseTemplate, "base_template", "BaseTemplate", campaign.LenientTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	tally := estimateTally{}
+	add := func(customer *repository.Customer) {
+		msg := render(campaign, tmpl, customer)
+		if msg.exceeds(campaign) {
+			tally.exceeding++
+			return
+		}
+
+		price, ok := svc.prices.Price(campaign.Channel, customer.Phone)
+		if !ok {
+			tally.unpriced++
+		}
+		tally.add(msg.segments(), price*float64(msg.segments()))
+	}
+
+	var audience int64
+	if payload.SegmentID != 0 {
+		if _, err := svc.repository.GetSegment(ctx, payload.SegmentID); err != nil {
+			return nil, err
+		}
+
+		audience, err = svc.repository.CountSegmentCustomers(ctx, payload.SegmentID)
+		if err != nil {
+			return nil, err
+		}
+
+		if audience > int64(svc.sampleSize) {
+			sample, err := svc.repository.SampleSegmentCustomers(ctx, &repository.SampleSegmentCustomersParams{
+				SegmentID:  payload.SegmentID,
+				SampleSize: int32(svc.sampleSize),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, customer := range sample {
+				add(customer)
+			}
+		} else {
+			err := svc.streamSegment(ctx, payload.SegmentID, func(batch []*repository.Customer) error {
+				for _, customer := range batch {
+					add(customer)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		ids, err := svc.estimateAudience(ctx, campaign.ID, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		audience = int64(len(ids))
+		if len(ids) > svc.sampleSize {
+			sample := make([]int64, svc.sampleSize)
+			for i, j := range rand.Perm(len(ids))[:svc.sampleSize] {
+				sample[i] = ids[j]
+			}
+			ids = sample
+		}
+
+		if len(ids) > 0 {
+			customers, err := svc.repository.ListCustomersByIds(ctx, &repository.ListCustomersByIdsParams{
+				CustomerIds: ids,
+				BatchSize:   int32(len(ids)),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, customer := range customers {
+				add(customer)
+			}
+		}
+	}
+
+	estimate := tally.extrapolate(audience)
+	estimate.CampaignID = campaign.ID
+	estimate.Channel = campaign.Channel
+	estimate.Currency = svc.currency
+
+	return estimate, nil
+}
+
+// estimateAudience returns the requested customers a send would queue: each
+// existing customer once, leaving out the ones the campaign already reached
+// unless the payload asks for a resend.
+func (svc *Service) estimateAudience(ctx context.Context, campaignID int64, payload *domain.SendCampaign) ([]int64, error) {
+	existing, err := svc.repository.ListExistingCustomerIds(ctx, payload.CustomerIds)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, _, _ := splitAudience(payload.CustomerIds, existing)
+	if payload.Resend || len(ids) == 0 {
+		return ids, nil
+	}
+
+	messaged, err := svc.repository.ListMessagedCustomerIds(ctx, &repository.ListMessagedCustomerIdsParams{
+		CampaignID:  campaignID,
+		CustomerIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reached := make(map[int64]bool, len(messaged))
+	for _, id := range messaged {
+		reached[id] = true
+	}
+
+	return slices.DeleteFunc(ids, func(id int64) bool { return reached[id] }), nil
+}
+
+// estimateTally accumulates per-message figures over the rendered messages.
+type estimateTally struct {
+	rendered  int
+	exceeding int
+	unpriced  int
+	segments  int
+	cost      float64
+	costSq    float64
+}
+
+func (t *estimateTally) add(segments int, cost float64) {
+	t.rendered++
+	t.segments += segments
+	t.cost += cost
+	t.costSq += cost * cost
+}
+
+// extrapolate scales the tally up to an audience of the given size. Exceeding
+// messages count towards the sample but are never sent, so they contribute a
+// cost of zero to the confidence interval.
+func (t *estimateTally) extrapolate(audience int64) *domain.CampaignEstimate {
+	n := t.rendered + t.exceeding
+	estimate := domain.CampaignEstimate{
+		AudienceSize: audience,
+		SampleSize:   n,
+		Sampled:      int64(n) < audience,
+	}
+	if n == 0 {
+		return &estimate
+	}
+
+	scale := float64(audience) / float64(n)
+	estimate.TotalMessages = int64(math.Round(float64(t.rendered) * scale))
+	estimate.TotalSegments = int64(math.Round(float64(t.segments) * scale))
+	estimate.EstimatedCost = roundCost(t.cost * scale)
+	estimate.ExceedingMaxSegments = int64(math.Round(float64(t.exceeding) * scale))
+	estimate.UnpricedMessages = int64(math.Round(float64(t.unpriced) * scale))
+
+	if estimate.Sampled && n > 1 {
+		mean := t.cost / float64(n)
+		variance := (t.costSq - float64(n)*mean*mean) / float64(n-1)
+		fpc := math.Sqrt(float64(audience-int64(n)) / float64(audience-1))
+		estimate.CostMargin = roundCost(1.96 * float64(audience) * math.Sqrt(math.Max(variance, 0)/float64(n)) * fpc)
+	}
+
+	return &estimate
+}
+
+func roundCost(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateTally_FullAudience(t *testing.T) {
+	tally := estimateTally{}
+	tally.add(1, 0.8)
+	tally.add(2, 1.6)
+	tally.exceeding++
+
+	estimate := tally.extrapolate(3)
+	assert.False(t, estimate.Sampled)
+	assert.Equal(t, 3, estimate.SampleSize)
+	assert.Equal(t, int64(2), estimate.TotalMessages)
+	assert.Equal(t, int64(3), estimate.TotalSegments)
+	assert.Equal(t, 2.4, estimate.EstimatedCost)
+	assert.Equal(t, int64(1), estimate.ExceedingMaxSegments)
+	assert.Zero(t, estimate.CostMargin)
+}
+
+func TestEstimateTally_Sampled(t *testing.T) {
+	tally := estimateTally{}
+	for i := 0; i < 50; i++ {
+		tally.add(1, 1.0)
+		tally.add(2, 2.0)
+	}
+	tally.unpriced = 10
+
+	estimate := tally.extrapolate(10000)
+	assert.True(t, estimate.Sampled)
+	assert.Equal(t, 100, estimate.SampleSize)
+	assert.Equal(t, int64(10000), estimate.TotalMessages)
+	assert.Equal(t, int64(15000), estimate.TotalSegments)
+	assert.Equal(t, 15000.0, estimate.EstimatedCost)
+	assert.Equal(t, int64(1000), estimate.UnpricedMessages)
+	assert.InDelta(t, 980, estimate.CostMargin, 10)
+}
+
+func TestEstimateTally_Empty(t *testing.T) {
+	tally := estimateTally{}
+
+	estimate := tally.extrapolate(0)
+	assert.False(t, estimate.Sampled)
+	assert.Zero(t, estimate.TotalMessages)
+	assert.Zero(t, estimate.EstimatedCost)
+}
+
+func newEstimateService() (*Service, *fakeRepository) {
+	svc, repo := newFakeService(&repository.Campaign{ID: 1, Channel: "sms", Status: domain.CampaignDraft, BaseTemplate: "Hi {FirstName}"})
+	svc.sampleSize = 10
+	for _, id := range []int64{1, 2, 3} {
+		repo.customers[id] = &repository.Customer{ID: id, Phone: "+254700000000"}
+	}
+	return svc, repo
+}
+
+func TestEstimateCampaign_DuplicateCustomers(t *testing.T) {
+	svc, _ := newEstimateService()
+
+	estimate, err := svc.EstimateCampaign(context.Background(), 1, &domain.SendCampaign{CustomerIds: []int64{1, 1, 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), estimate.AudienceSize)
+	assert.Equal(t, int64(1), estimate.TotalMessages)
+}
+
+func TestEstimateCampaign_MissingCustomers(t *testing.T) {
+	svc, _ := newEstimateService()
+
+	estimate, err := svc.EstimateCampaign(context.Background(), 1, &domain.SendCampaign{CustomerIds: []int64{1, 2, 99}})
+	assert.NoError(t, err, "unknown customers are left out like the send leaves them out")
+	assert.Equal(t, int64(2), estimate.AudienceSize)
+	assert.Equal(t, int64(2), estimate.TotalMessages)
+}
+
+func TestEstimateCampaign_AlreadyMessaged(t *testing.T) {
+	svc, repo := newEstimateService()
+	repo.messaged[1] = []int64{2}
+
+	estimate, err := svc.EstimateCampaign(context.Background(), 1, &domain.SendCampaign{CustomerIds: []int64{1, 2, 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), estimate.AudienceSize)
+
+	estimate, err = svc.EstimateCampaign(context.Background(), 1, &domain.SendCampaign{CustomerIds: []int64{1, 2, 3}, Resend: true, ResendReason: "typo"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), estimate.AudienceSize, "a resend reaches customers the campaign already messaged")
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"io"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// importMaxRetry bounds how often a failed import job is retried. Each attempt
+// restarts the import from the first row.
+const importMaxRetry = 3
+
+// importChunkSize is how much of an uploaded file is stored per row, and so
+// the most of it that is held in memory at once.
+const importChunkSize = 1 << 20
+
+var (
+	// errImportKeyTaken rolls back an import whose Idempotency-Key an earlier
+	// upload already holds.
+	errImportKeyTaken = stderrors.New("idempotency key is taken")
+
+	errEmptyImport = stderrors.New("import file is empty")
+)
+
+// StageCustomerImport validates an uploaded file and stores it for processing
+// without queueing it.
+func (svc *Service) StageCustomerImport(ctx context.Context, payload *domain.CreateCustomerImport, file io.Reader) (*repository.CustomerImport, error) {
+	record, _, err := svc.addCustomerImport(ctx, payload, file, false)
+	return record, err
+}
+
+// ImportCustomers stores an uploaded file together with the outbox entry of
+// the task that processes it on the worker tier, so an import is never stored
+// without its task. An upload retried under the Idempotency-Key of an earlier
+// one gets that import back, reported as replayed, instead of a new one.
+func (svc *Service) ImportCustomers(ctx context.Context, payload *domain.CreateCustomerImport, file io.Reader) (*repository.CustomerImport, bool, error) {
+	return svc.addCustomerImport(ctx, payload, file, true)
+}
+
+// addCustomerImport streams an uploaded file into a new import, queueing it
+// when queue is set. The file is fingerprinted on the way, together with the
+// format and column mapping, for the Idempotency-Key check.
+func (svc *Service) addCustomerImport(ctx context.Context, payload *domain.CreateCustomerImport, file io.Reader, queue bool) (*repository.CustomerImport, bool, error) {
+	args, err := svc.customerImportParams(payload)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", args.Format, args.ColumnMapping)
+
+	var record *repository.CustomerImport
+	var fingerprint string
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		record, err = q.CreateCustomerImport(ctx, args)
+		if err != nil {
+			return err
+		}
+
+		if err := storeImportSource(ctx, q, record.ID, io.TeeReader(file, hash)); err != nil {
+			return err
+		}
+		fingerprint = hex.EncodeToString(hash.Sum(nil))
+
+		if payload.IdempotencyKey != "" {
+			_, err = q.ClaimCustomerImportKey(ctx, &repository.ClaimCustomerImportKeyParams{
+				IdempotencyKey: payload.IdempotencyKey,
+				ImportID:       record.ID,
+				Fingerprint:    fingerprint,
+			})
+			if stderrors.Is(err, pgx.ErrNoRows) {
+				return errImportKeyTaken
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if !queue {
+			return nil
+		}
+
+		entry, err := svc.outboxEntry(domain.ImportCustomersTask, domain.ImportCustomers{ImportID: record.ID}, pgtype.Timestamptz{})
+		if err != nil {
+			return err
+		}
+		entry.MaxRetry = pgtype.Int4{Int32: importMaxRetry, Valid: true}
+
+		_, err = q.CreateOutboxEntry(ctx, entry)
+		return err
+	})
+	switch {
+	case stderrors.Is(err, errImportKeyTaken):
+		return svc.replayCustomerImport(ctx, payload.IdempotencyKey, fingerprint)
+	case stderrors.Is(err, errEmptyImport):
+		return nil, false, errors.WrapError(err, errors.InvalidArgument, "EMPTY_IMPORT_FILE")
+	case err != nil:
+		return nil, false, errors.WrapError(err, errors.Internal, "SAVE_CUSTOMER_IMPORT_ERROR")
+	}
+
+	return record, false, nil
+}
+
+// storeImportSource copies an uploaded file into the chunks of an import. An
+// empty file is refused.
+func storeImportSource(ctx context.Context, q *repository.Queries, importID int64, file io.Reader) error {
+	buf := make([]byte, importChunkSize)
+	for seq := int32(0); ; seq++ {
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			err := q.CreateCustomerImportChunk(ctx, &repository.CreateCustomerImportChunkParams{
+				ImportID: importID,
+				Seq:      seq,
+				Data:     buf[:n],
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case err == io.EOF && seq == 0:
+			return errEmptyImport
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// replayCustomerImport returns the import an earlier upload created under key,
+// provided it was the same upload.
+func (svc *Service) replayCustomerImport(ctx context.Context, key, fingerprint string) (*repository.CustomerImport, bool, error) {
+	claim, err := svc.repository.GetCustomerImportKey(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if claim.Fingerprint != fingerprint {
+		return nil, false, errors.WrapError(
+			fmt.Errorf("idempotency key %q was already used with a different upload", key),
+			errors.AlreadyExists,
+			"IDEMPOTENCY_KEY_REUSED",
+		)
+	}
+
+	record, err := svc.repository.GetCustomerImport(ctx, claim.ImportID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, true, nil
+}
+
+// customerImportParams validates the options of an upload and builds the
+// import record for it.
+func (svc *Service) customerImportParams(payload *domain.CreateCustomerImport) (*repository.CreateCustomerImportParams, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	mapping := payload.Mapping
+	if mapping == nil {
+		mapping = map[string]string{}
+	}
+
+	out, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.InvalidArgument, "INVALID_COLUMN_MAPPING")
+	}
+
+	return &repository.CreateCustomerImportParams{
+		Format:        payload.Format,
+		ColumnMapping: out,
+	}, nil
+}
+
+func (svc *Service) RetrieveCustomerImport(ctx context.Context, importID int64) (*repository.CustomerImport, error) {
+	return svc.repository.GetCustomerImport(ctx, importID)
+}
+
+func (svc *Service) CustomerImportReport(ctx context.Context, importID int64) ([]*repository.CustomerImportRejection, error) {
+	if _, err := svc.repository.GetCustomerImport(ctx, importID); err != nil {
+		return nil, err
+	}
+
+	return svc.repository.ListCustomerImportRejections(ctx, importID)
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mwinyimoha/commons/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportCustomers_IdempotencyKey(t *testing.T) {
+	svc, repo := newFakeService()
+	repo.db.rows["CreateCustomerImport"] = func([]interface{}) fakeRow {
+		return fakeRow{values: []any{int64(2)}}
+	}
+
+	payload := &domain.CreateCustomerImport{Format: "csv", IdempotencyKey: "abc"}
+	data := []byte("phone\n+254712000000\n")
+
+	record, replayed, err := svc.ImportCustomers(context.Background(), payload, bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.False(t, replayed)
+	assert.Equal(t, int64(2), record.ID)
+	assert.Equal(t, []string{"CreateCustomerImport", "CreateCustomerImportChunk", "ClaimCustomerImportKey", "CreateOutboxEntry"}, repo.db.queries)
+	assert.Equal(t, domain.ImportCustomersTask, repo.db.args["CreateOutboxEntry"][0], "the import is queued with the record")
+
+	// From here on the key is held by the first upload.
+	repo.imports[2] = record
+	repo.importKeys["abc"] = &repository.CustomerImportKey{
+		IdempotencyKey: "abc",
+		ImportID:       2,
+		Fingerprint:    repo.db.args["ClaimCustomerImportKey"][2].(string),
+	}
+	repo.db.rows["ClaimCustomerImportKey"] = func([]interface{}) fakeRow {
+		return fakeRow{err: pgx.ErrNoRows}
+	}
+
+	t.Run("same upload", func(t *testing.T) {
+		repo.db.queries = nil
+
+		replay, replayed, err := svc.ImportCustomers(context.Background(), payload, bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.True(t, replayed)
+		assert.Equal(t, record, replay)
+		assert.NotContains(t, repo.db.queries, "CreateOutboxEntry", "a replay queues nothing")
+	})
+
+	t.Run("different upload", func(t *testing.T) {
+		_, _, err := svc.ImportCustomers(context.Background(), payload, strings.NewReader("phone\n+254712000001\n"))
+		_, ok := err.(*errors.Error)
+		assert.True(t, ok, "reusing a key for another file is refused")
+	})
+}
+
+func TestStoreImportSource(t *testing.T) {
+	_, repo := newFakeService()
+	q := repository.New(repo.db)
+
+	data := bytes.Repeat([]byte("x"), 2*importChunkSize+10)
+	assert.NoError(t, storeImportSource(context.Background(), q, 2, bytes.NewReader(data)))
+	assert.Len(t, repo.db.queries, 3, "the file is stored one chunk at a time")
+	assert.Equal(t, int32(2), repo.db.args["CreateCustomerImportChunk"][1])
+	assert.Len(t, repo.db.args["CreateCustomerImportChunk"][2], 10)
+
+	err := storeImportSource(context.Background(), q, 3, strings.NewReader(""))
+	assert.ErrorIs(t, err, errEmptyImport)
+}
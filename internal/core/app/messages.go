@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// messagesExportBatchSize is how many messages ExportCampaignMessages reads
+// per query.
+const messagesExportBatchSize = 1000
+
+// deferredDetail is stored with the event recorded when quiet hours hold a
+// message back.
+type deferredDetail struct {
+	Until time.Time `json:"until"`
+}
+
+// retriedDetail is stored with the status_changed event of a manual retry.
+type retriedDetail struct {
+	RetryCount int32 `json:"retry_count"`
+}
+
+// RetrieveMessage returns an outbound message together with the campaign it
+// belongs to and the customer it was rendered for.
+func (svc *Service) RetrieveMessage(ctx context.Context, messageID int64) (*repository.GetOutboundMessageDetailRow, error) {
+	return svc.repository.GetOutboundMessageDetail(ctx, messageID)
+}
+
+// ListMessageEvents returns the timeline of a message, oldest event first.
+func (svc *Service) ListMessageEvents(ctx context.Context, messageID int64) ([]*repository.MessageEvent, error) {
+	if _, err := svc.repository.GetOutboundMessageDetail(ctx, messageID); err != nil {
+		return nil, err
+	}
+
+	return svc.repository.ListMessageEvents(ctx, messageID)
+}
+
+// ListCampaignMessages returns up to limit messages of a campaign that match
+// filter, in ID order and starting after the message afterID.
+func (svc *Service) ListCampaignMessages(
+	ctx context.Context,
+	campaignID int64,
+	filter *domain.MessagesFilter,
+	afterID int64,
+	limit int,
+) ([]*repository.ListCampaignMessagesRow, error) {
+	arg, err := svc.campaignMessagesParams(ctx, campaignID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	arg.AfterID = afterID
+	arg.PageSize = int32(limit)
+	return svc.repository.ListCampaignMessages(ctx, arg)
+}
+
+// ExportCampaignMessages reads every message of a campaign that matches filter
+// in batches and hands each batch to write. write is called at least once,
+// with an empty batch when nothing matches.
+func (svc *Service) ExportCampaignMessages(
+	ctx context.Context,
+	campaignID int64,
+	filter *domain.MessagesFilter,
+	write func([]*repository.ListCampaignMessagesRow) error,
+) error {
+	arg, err := svc.campaignMessagesParams(ctx, campaignID, filter)
+	if err != nil {
+		return err
+	}
+
+	arg.PageSize = messagesExportBatchSize
+	for {
+		records, err := svc.repository.ListCampaignMessages(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		if err := write(records); err != nil {
+			return err
+		}
+
+		if len(records) < messagesExportBatchSize {
+			return nil
+		}
+		arg.AfterID = records[len(records)-1].ID
+	}
+}
+
+// DeferMessage hands a pending message to a new task that runs at until and
+// records the deferral on its timeline. It reports false, changing nothing,
+// when the message is no longer pending or expects another task than taskID.
+func (svc *Service) DeferMessage(ctx context.Context, messageID int64, taskID string, until time.Time) (bool, error) {
+	entry, err := svc.outboxEntry(domain.SendMessageTask, domain.SendMessage{MessageID: messageID}, pgtype.Timestamptz{Time: until, Valid: true})
+	if err != nil {
+		return false, errors.WrapError(err, errors.Internal, "DEFER_MESSAGE_ERROR")
+	}
+
+	detail, err := json.Marshal(deferredDetail{Until: until.UTC()})
+	if err != nil {
+		return false, errors.WrapError(err, errors.Internal, "DEFER_MESSAGE_ERROR")
+	}
+
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		created, err := q.CreateOutboxEntry(ctx, entry)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeferOutboundMessage(ctx, &repository.DeferOutboundMessageParams{
+			NewTaskID: pgtype.Text{String: domain.OutboxTaskID(created.ID), Valid: true},
+			MessageID: messageID,
+			TaskID:    nullableText(taskID),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateMessageEvent(ctx, &repository.CreateMessageEventParams{
+			MessageID: messageID,
+			EventType: domain.MessageEventDeferred,
+			Status:    domain.MessagePending,
+			Detail:    detail,
+		})
+		return err
+	})
+	if stderrors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WrapError(err, errors.Internal, "DEFER_MESSAGE_ERROR")
+	}
+
+	return true, nil
+}
+
+func (svc *Service) campaignMessagesParams(
+	ctx context.Context,
+	campaignID int64,
+	filter *domain.MessagesFilter,
+) (*repository.ListCampaignMessagesParams, error) {
+	if err := svc.validator.Struct(filter); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	if _, err := svc.repository.GetCampaign(ctx, campaignID); err != nil {
+		return nil, err
+	}
+
+	arg := repository.ListCampaignMessagesParams{
+		CampaignID:    campaignID,
+		Statuses:      filter.Statuses,
+		CreatedAfter:  nullableTimestamp(filter.CreatedAfter),
+		CreatedBefore: nullableTimestamp(filter.CreatedBefore),
+		Error:         nullableText(filter.Error),
+	}
+	if arg.Statuses == nil {
+		arg.Statuses = []string{}
+	}
+	if filter.CustomerID != 0 {
+		arg.CustomerID = pgtype.Int8{Int64: filter.CustomerID, Valid: true}
+	}
+
+	return &arg, nil
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func nullableText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
+
+// nullableTimestamp reads an RFC 3339 time as the UTC wall-clock time the
+// TIMESTAMP columns it is compared with hold.
+func nullableTimestamp(s string) pgtype.Timestamp {
+	if s == "" {
+		return pgtype.Timestamp{}
+	}
+
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return pgtype.Timestamp{}
+	}
+
+	return pgtype.Timestamp{Time: ts.UTC(), Valid: true}
+}
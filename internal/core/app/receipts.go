@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// receiptDetail is stored with every receipt event. Applied is false when the
+// receipt arrived out of order or twice and did not change the message.
+type receiptDetail struct {
+	Channel           string `json:"channel"`
+	ProviderMessageID string `json:"provider_message_id"`
+	Reason            string `json:"reason,omitempty"`
+	PreviousStatus    string `json:"previous_status"`
+	Applied           bool   `json:"applied"`
+}
+
+// RecordDeliveryReceipt applies a gateway's delivery receipt to the message it
+// refers to and records it in the message's event history. Receipts that would
+// move the message backwards, such as a delivery report arriving after the
+// read receipt, are recorded without changing the message.
+func (svc *Service) RecordDeliveryReceipt(ctx context.Context, channel string, receipt *domain.DeliveryReceipt) (*repository.OutboundMessage, error) {
+	if err := svc.validator.Struct(receipt); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	msg, err := svc.repository.GetOutboundMessageByProviderID(ctx, &repository.GetOutboundMessageByProviderIDParams{
+		ProviderMessageID: pgtype.Text{String: receipt.MessageID, Valid: true},
+		Channel:           channel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	event := repository.CreateMessageEventParams{
+		MessageID: msg.ID,
+		EventType: domain.MessageEventReceipt,
+		Status:    receipt.Status,
+	}
+	if receipt.OccurredAt != nil {
+		event.OccurredAt = pgtype.Timestamp{Time: receipt.OccurredAt.UTC(), Valid: true}
+	}
+
+	arg := repository.TransitionOutboundMessageParams{
+		ToStatus:     receipt.Status,
+		MessageID:    msg.ID,
+		FromStatuses: domain.MessageTransitionSources(receipt.Status),
+	}
+	if receipt.Status == domain.MessageUndelivered && receipt.Reason != "" {
+		arg.LastError = pgtype.Text{String: receipt.Reason, Valid: true}
+	}
+
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		detail := receiptDetail{
+			Channel:           channel,
+			ProviderMessageID: receipt.MessageID,
+			Reason:            receipt.Reason,
+			PreviousStatus:    msg.Status,
+		}
+
+		updated, err := q.TransitionOutboundMessage(ctx, &arg)
+		switch {
+		case err == nil:
+			msg = updated
+			detail.Applied = true
+		case !stderrors.Is(err, pgx.ErrNoRows):
+			return err
+		}
+
+		event.Detail, err = json.Marshal(detail)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateMessageEvent(ctx, &event)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_DELIVERY_RECEIPT_ERROR")
+	}
+
+	return msg, nil
+}
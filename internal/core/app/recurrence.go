@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// defaultTimezone is the timezone of campaigns that do not name one.
+const defaultTimezone = "UTC"
+
+// nextOccurrence returns the first time after after at which a cron expression
+// fires, reading the expression in the given timezone. An expression that
+// never fires again is an error.
+func nextOccurrence(expr, timezone string, after time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := schedule.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("%q does not fire after %s", expr, after.Format(time.RFC3339))
+	}
+
+	return next.UTC(), nil
+}
+
+// firstOccurrence returns the first time at or after start at which a
+// recurring campaign is due, and never one in the past.
+func firstOccurrence(expr, timezone string, start time.Time) (time.Time, error) {
+	if now := time.Now(); start.Before(now) {
+		return nextOccurrence(expr, timezone, now)
+	}
+
+	return nextOccurrence(expr, timezone, start.Add(-time.Second))
+}
+
+// localTimestampLayout is a scheduled_at given without an offset.
+const localTimestampLayout = "2006-01-02T15:04:05"
+
+// parseScheduledAt reads a scheduled_at given in RFC 3339, keeping its
+// offset, or given without one, in which case it is a wall-clock time in the
+// campaign's timezone.
+func parseScheduledAt(value, timezone string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts.UTC(), nil
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ts, err := time.ParseInLocation(localTimestampLayout, value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ts.UTC(), nil
+}
+
+// ListCampaignRuns pages through the runs a recurring campaign started, latest
+// first.
+func (svc *Service) ListCampaignRuns(ctx context.Context, campaignID int64, pageNumber, pageSize int) ([]*repository.ListCampaignRunsRow, error) {
+	if _, err := svc.repository.GetCampaign(ctx, campaignID); err != nil {
+		return nil, err
+	}
+
+	return svc.repository.ListCampaignRuns(ctx, &repository.ListCampaignRunsParams{
+		CampaignID: campaignID,
+		PageSize:   int32(pageSize),
+		PageNumber: pageNumber,
+	})
+}
+
+// SkipCampaignOccurrence moves a scheduled or paused recurring campaign past
+// its next occurrence, which then starts no run.
+func (svc *Service) SkipCampaignOccurrence(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	campaign, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := domain.CampaignTransitionSources(domain.CampaignDraft)
+	if !campaign.Recurrence.Valid || !slices.Contains(sources, campaign.Status) {
+		return nil, notRecurring(campaign)
+	}
+
+	after := campaign.ScheduledAt.Time
+	if now := time.Now(); after.Before(now) {
+		after = now
+	}
+	next, err := nextOccurrence(campaign.Recurrence.String, campaign.Timezone, after)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.FailedPrecondition, "CAMPAIGN_RECURRENCE_EXHAUSTED")
+	}
+
+	record, err := svc.repository.AdvanceCampaignRecurrence(ctx, &repository.AdvanceCampaignRecurrenceParams{
+		NextRunAt:     pgtype.Timestamptz{Time: next, Valid: true},
+		CampaignID:    campaignID,
+		FromStatuses:  sources,
+		ExpectedRunAt: campaign.ScheduledAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		// The occurrence started, or the campaign changed, in the meantime.
+		return nil, campaignModified(campaignID)
+	}
+
+	return record, nil
+}
+
+// EndCampaignRecurrence stops a scheduled or paused recurring campaign from
+// starting more runs. The campaign goes back to draft without a schedule;
+// runs it already started carry on.
+func (svc *Service) EndCampaignRecurrence(ctx context.Context, campaignID int64) (*repository.Campaign, error) {
+	record, err := svc.repository.EndCampaignRecurrence(ctx, &repository.EndCampaignRecurrenceParams{
+		CampaignID:   campaignID,
+		FromStatuses: domain.CampaignTransitionSources(domain.CampaignDraft),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		return record, nil
+	}
+
+	current, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Recurrence.Valid {
+		return nil, notRecurring(current)
+	}
+
+	return nil, refusedTransition(current, domain.CampaignDraft)
+}
+
+// invalidRecurrence reports a recurrence that cannot be read or never fires,
+// in the shape of the valid_cron check.
+func invalidRecurrence(expr string) error {
+	return validationError(&fieldError{
+		field:       "recurrence",
+		structField: "Recurrence",
+		tag:         "valid_cron",
+		value:       expr,
+	})
+}
+
+func notRecurring(campaign *repository.GetCampaignRow) error {
+	return errors.WrapError(
+		fmt.Errorf("campaign %d is %s and has no upcoming occurrence", campaign.ID, campaign.Status),
+		errors.FailedPrecondition,
+		"CAMPAIGN_NOT_RECURRING",
+	)
+}
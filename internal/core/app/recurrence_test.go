@@ -0,0 +1,55 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	// Mondays at 09:00 in Nairobi are 06:00 UTC.
+	after := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	next, err := nextOccurrence("0 9 * * 1", "Africa/Nairobi", after)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2030, 1, 7, 6, 0, 0, 0, time.UTC), next)
+
+	next, err = nextOccurrence("@monthly", "UTC", after)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC), next)
+
+	_, err = nextOccurrence("every monday", "UTC", after)
+	assert.Error(t, err)
+
+	_, err = nextOccurrence("0 9 * * 1", "Mars/Olympus", after)
+	assert.Error(t, err)
+
+	_, err = nextOccurrence("0 0 30 2 *", "UTC", after)
+	assert.Error(t, err, "February 30th never comes")
+}
+
+func TestParseScheduledAt(t *testing.T) {
+	want := time.Date(2030, 1, 7, 7, 0, 0, 0, time.UTC)
+
+	ts, err := parseScheduledAt("2030-01-07T10:00:00+03:00", "America/New_York")
+	assert.NoError(t, err)
+	assert.Equal(t, want, ts, "an offset wins over the timezone")
+
+	ts, err = parseScheduledAt("2030-01-07T10:00:00", "Africa/Nairobi")
+	assert.NoError(t, err)
+	assert.Equal(t, want, ts)
+
+	_, err = parseScheduledAt("2030-01-07 10:00", "UTC")
+	assert.Error(t, err)
+}
+
+func TestFirstOccurrence(t *testing.T) {
+	start := time.Date(2030, 1, 7, 6, 0, 0, 0, time.UTC)
+	first, err := firstOccurrence("0 9 * * 1", "Africa/Nairobi", start)
+	assert.NoError(t, err)
+	assert.Equal(t, start, first, "a start on an occurrence is that occurrence")
+
+	first, err = firstOccurrence("*/5 * * * *", "UTC", time.Time{})
+	assert.NoError(t, err)
+	assert.True(t, first.After(time.Now()), "a series never starts in the past")
+}
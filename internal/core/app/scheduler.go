@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// schedulerLockKey names the advisory lock that elects the scheduler leader.
+const schedulerLockKey int64 = 0x5c4ed01e
+
+// DispatchDueCampaigns starts up to limit scheduled campaigns whose time has
+// come, sending each to the audience stored with it in best-effort mode. A
+// recurring campaign starts a run of its own instead and waits for its next
+// occurrence. Only one scheduler runs a pass at a time; the others find the
+// lock taken and return without doing anything. It returns the number of
+// campaigns started.
+func (svc *Service) DispatchDueCampaigns(ctx context.Context, limit int32) (int, error) {
+	started := 0
+	err := svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		leader, err := q.TryAdvisoryXactLock(ctx, schedulerLockKey)
+		if err != nil || !leader {
+			return err
+		}
+
+		due, err := q.ListDueCampaigns(ctx, &repository.ListDueCampaignsParams{
+			Now:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			BatchSize: limit,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, campaign := range due {
+			if campaign.Recurrence.Valid {
+				err = svc.startCampaignRun(ctx, q, campaign)
+			} else {
+				err = svc.startDueCampaign(ctx, q, campaign)
+			}
+			if err != nil {
+				return err
+			}
+			started++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return started, nil
+}
+
+// startDueCampaign moves a due campaign to sending and records its dispatch.
+func (svc *Service) startDueCampaign(ctx context.Context, q *repository.Queries, campaign *repository.Campaign) error {
+	_, err := q.TransitionCampaign(ctx, &repository.TransitionCampaignParams{
+		ToStatus:     domain.CampaignSending,
+		CampaignID:   campaign.ID,
+		FromStatuses: []string{domain.CampaignScheduled},
+	})
+	if err != nil {
+		return err
+	}
+
+	return svc.dispatchAudience(ctx, q, campaign)
+}
+
+// startCampaignRun starts the occurrence a recurring campaign is due for as a
+// run of its own and moves the campaign on to its next occurrence. A campaign
+// whose recurrence never fires again stops recurring.
+func (svc *Service) startCampaignRun(ctx context.Context, q *repository.Queries, campaign *repository.Campaign) error {
+	run, err := q.CreateCampaignRun(ctx, &repository.CreateCampaignRunParams{
+		RunAt:      campaign.ScheduledAt,
+		CampaignID: campaign.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Occurrences missed while no scheduler was running are not made up for.
+	after := campaign.ScheduledAt.Time
+	if now := time.Now(); after.Before(now) {
+		after = now
+	}
+	next, err := nextOccurrence(campaign.Recurrence.String, campaign.Timezone, after)
+	if err != nil {
+		_, err = q.EndCampaignRecurrence(ctx, &repository.EndCampaignRecurrenceParams{
+			CampaignID:   campaign.ID,
+			FromStatuses: []string{domain.CampaignScheduled},
+		})
+	} else {
+		_, err = q.AdvanceCampaignRecurrence(ctx, &repository.AdvanceCampaignRecurrenceParams{
+			NextRunAt:     pgtype.Timestamptz{Time: next, Valid: true},
+			CampaignID:    campaign.ID,
+			FromStatuses:  []string{domain.CampaignScheduled},
+			ExpectedRunAt: campaign.ScheduledAt,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return svc.dispatchAudience(ctx, q, run)
+}
+
+// dispatchAudience records a best-effort dispatch of a campaign to the
+// audience stored with it. An audience that has since emptied, such as a
+// deleted segment, leaves the campaign failed once the job finds nobody to
+// send to.
+func (svc *Service) dispatchAudience(ctx context.Context, q *repository.Queries, campaign *repository.Campaign) error {
+	arg := repository.CreateCampaignDispatchParams{
+		CampaignID: campaign.ID,
+		Mode:       domain.SendBestEffort,
+	}
+	skipped := newOutcomes(0)
+	if campaign.SegmentID.Valid {
+		total, err := q.CountSegmentCustomers(ctx, campaign.SegmentID.Int64)
+		if err != nil {
+			return err
+		}
+
+		arg.SegmentID = campaign.SegmentID
+		arg.TotalCustomers = int32(total)
+	} else {
+		existing, err := q.ListExistingCustomerIds(ctx, campaign.CustomerIds)
+		if err != nil {
+			return err
+		}
+
+		arg.CustomerIds, skipped, _ = splitAudience(campaign.CustomerIds, existing)
+		arg.TotalCustomers = int32(len(campaign.CustomerIds))
+		arg.SkippedCustomers = skipped.skipped
+	}
+
+	_, err := svc.createDispatch(ctx, q, &arg, skipped)
+	return err
+}
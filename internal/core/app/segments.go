@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// segmentBatchSize is the number of customers fetched per page when a segment
+// is expanded into recipients.
+const segmentBatchSize = 500
+
+func (svc *Service) AddSegment(ctx context.Context, payload *domain.CreateSegment) (*repository.Segment, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	args := repository.CreateSegmentParams{
+		Name:             payload.Name,
+		Location:         nullableText(payload.Location),
+		PreferredProduct: nullableText(payload.PreferredProduct),
+		PhonePrefix:      nullableText(payload.PhonePrefix),
+		CreatedAfter:     nullableTimestamp(payload.CreatedAfter),
+		CreatedBefore:    nullableTimestamp(payload.CreatedBefore),
+	}
+
+	return svc.repository.AddSegment(ctx, &args)
+}
+
+func (svc *Service) ListSegments(ctx context.Context, pageNumber, pageSize int) ([]*repository.ListSegmentsRow, error) {
+	args := repository.ListSegmentsParams{
+		PageNumber: pageNumber,
+		PageSize:   int32(pageSize),
+	}
+
+	return svc.repository.ListSegments(ctx, &args)
+}
+
+func (svc *Service) RetrieveSegment(ctx context.Context, segmentID int64) (*repository.Segment, error) {
+	return svc.repository.GetSegment(ctx, segmentID)
+}
+
+func (svc *Service) UpdateSegment(ctx context.Context, segmentID int64, payload *domain.UpdateSegment) (*repository.Segment, error) {
+	if err := svc.validator.Struct(payload); err != nil {
+		if verr, ok := err.(validator.ValidationErrors); ok {
+			violations := errors.BuildViolations(verr)
+			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+		}
+
+		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	args := repository.UpdateSegmentParams{
+		Name:             payload.Name,
+		Location:         nullableText(payload.Location),
+		PreferredProduct: nullableText(payload.PreferredProduct),
+		PhonePrefix:      nullableText(payload.PhonePrefix),
+		CreatedAfter:     nullableTimestamp(payload.CreatedAfter),
+		CreatedBefore:    nullableTimestamp(payload.CreatedBefore),
+		SegmentID:        segmentID,
+	}
+
+	return svc.repository.UpdateSegment(ctx, &args)
+}
+
+func (svc *Service) DeleteSegment(ctx context.Context, segmentID int64) error {
+	return svc.repository.DeleteSegment(ctx, segmentID)
+}
+
+// CountSegment reports how many customers a segment currently matches, letting
+// callers size a send before committing to it.
+func (svc *Service) CountSegment(ctx context.Context, segmentID int64) (*domain.SegmentCount, error) {
+	if _, err := svc.repository.GetSegment(ctx, segmentID); err != nil {
+		return nil, err
+	}
+
+	count, err := svc.repository.CountSegmentCustomers(ctx, segmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SegmentCount{
+		SegmentID: segmentID,
+		Customers: count,
+	}, nil
+}
+
+// streamSegment expands a segment server-side, calling fn with successive pages
+// of matching customers ordered by ID until the segment is exhausted, fn fails
+// or ctx is cancelled.
+func (svc *Service) streamSegment(ctx context.Context, segmentID int64, fn func([]*repository.Customer) error) error {
+	args := repository.ListSegmentCustomersParams{
+		SegmentID: segmentID,
+		BatchSize: segmentBatchSize,
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch, err := svc.repository.ListSegmentCustomers(ctx, &args)
+		if err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < segmentBatchSize {
+			return nil
+		}
+
+		args.AfterID = batch[len(batch)-1].ID
+	}
+}
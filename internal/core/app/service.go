@@ -3,81 +3,146 @@ package app
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"focus-dev-challenge/internal/adapters/repository"
 	"focus-dev-challenge/internal/config"
 	"focus-dev-challenge/internal/core/domain"
 	"focus-dev-challenge/internal/core/ports"
-	"reflect"
-	"regexp"
-	"sync/atomic"
+	"focus-dev-challenge/internal/core/template"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mwinyimoha/commons/pkg/errors"
-	"golang.org/x/sync/errgroup"
+	"go.uber.org/zap"
 )
 
+// taskInspector deletes tasks from the queue.
+type taskInspector interface {
+	DeleteTask(queue, id string) error
+}
+
 type Service struct {
 	repository ports.AppRepository
-	broker     *asynq.Client
+	inspector  taskInspector
 	validator  *validator.Validate
+	logger     *zap.Logger
 	queue      string
+	prices     config.PriceTable
+	currency   string
+	sampleSize int
+
+	dispatchBatchSize int32
+
+	// background tracks the task deletions still running.
+	background sync.WaitGroup
 }
 
-func NewService(cfg *config.Config, r ports.AppRepository, v *validator.Validate) *Service {
+func NewService(cfg *config.Config, r ports.AppRepository, v *validator.Validate, logger *zap.Logger) *Service {
 	v.RegisterValidation("valid_timestamp", validTimestamp)
+	v.RegisterValidation("valid_scheduled_at", validScheduledAt)
+	v.RegisterValidation("valid_cron", validCron)
+	v.RegisterValidation("valid_phone_prefix", validPhonePrefix)
 
-	client := asynq.NewClient(&asynq.RedisClientOpt{
+	redis := &asynq.RedisClientOpt{
 		Addr:        cfg.RedisHost,
 		DB:          cfg.RedisDB,
 		DialTimeout: time.Duration(cfg.DefaultTimeout) * time.Second,
-	})
+	}
 
 	return &Service{
 		repository: r,
-		broker:     client,
+		inspector:  asynq.NewInspector(redis),
 		validator:  v,
+		logger:     logger,
 		queue:      cfg.DefaultQueue,
+		prices:     cfg.Prices,
+		currency:   cfg.PriceCurrency,
+		sampleSize: cfg.EstimateSampleSize,
+
+		dispatchBatchSize: int32(cfg.DispatchBatchSize),
 	}
 }
 
-func (svc *Service) AddCampaign(payload *domain.CreateCampaign) (*repository.Campaign, error) {
+// Stop waits for the task deletions started by campaign changes to finish.
+func (svc *Service) Stop() {
+	svc.background.Wait()
+}
+
+// AddCampaign creates a campaign after checking its template. For lenient
+// campaigns, unknown placeholders are returned as warnings.
+func (svc *Service) AddCampaign(ctx context.Context, payload *domain.CreateCampaign) (*repository.Campaign, []*domain.TemplateWarning, error) {
 	if err := svc.validator.Struct(payload); err != nil {
 		if verr, ok := err.(validator.ValidationErrors); ok {
 			violations := errors.BuildViolations(verr)
-			return nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+			return nil, nil, errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
 		}
 
-		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+		return nil, nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
+	}
+
+	_, warnings, err := compileTemplate(payload.BaseTemplate, "base_template", "BaseTemplate", payload.LenientTemplates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scheduled := payload.ScheduledAt != "" || payload.Recurrence != ""
+	if scheduled && payload.SegmentID == 0 && len(payload.CustomerIds) == 0 {
+		return nil, nil, missingAudience()
+	}
+	if payload.SegmentID != 0 {
+		if _, err := svc.repository.GetSegment(ctx, payload.SegmentID); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	args := repository.CreateCampaignParams{
-		Name:         payload.Name,
-		Channel:      payload.Channel,
-		Status:       "draft",
-		BaseTemplate: payload.BaseTemplate,
+		Name:             payload.Name,
+		Channel:          payload.Channel,
+		Status:           domain.CampaignDraft,
+		BaseTemplate:     payload.BaseTemplate,
+		LenientTemplates: payload.LenientTemplates,
+		MaxSegments:      pgtype.Int4{Int32: payload.MaxSegments, Valid: payload.MaxSegments > 0},
+		SegmentID:        pgtype.Int8{Int64: payload.SegmentID, Valid: payload.SegmentID != 0},
+		CustomerIds:      payload.CustomerIds,
+		Recurrence:       pgtype.Text{String: payload.Recurrence, Valid: payload.Recurrence != ""},
+		Timezone:         payload.Timezone,
+	}
+	if args.Timezone == "" {
+		args.Timezone = defaultTimezone
 	}
 	if payload.ScheduledAt != "" {
-		args.Status = "scheduled"
+		args.Status = domain.CampaignScheduled
 
-		ts, _ := time.Parse(time.RFC3339, payload.ScheduledAt)
-		args.ScheduledAt = pgtype.Timestamp{
+		ts, _ := parseScheduledAt(payload.ScheduledAt, args.Timezone)
+		args.ScheduledAt = pgtype.Timestamptz{
 			Time:  ts,
 			Valid: true,
 		}
 	}
-	record, err := svc.repository.AddCampaign(&args)
+	if payload.Recurrence != "" {
+		first, err := firstOccurrence(payload.Recurrence, args.Timezone, args.ScheduledAt.Time)
+		if err != nil {
+			return nil, nil, invalidRecurrence(payload.Recurrence)
+		}
+
+		args.Status = domain.CampaignScheduled
+		args.ScheduledAt = pgtype.Timestamptz{Time: first, Valid: true}
+	}
+	record, err := svc.repository.AddCampaign(ctx, &args)
 	if err != nil {
-		return nil, errors.WrapError(err, errors.Internal, "failed to create campaign")
+		return nil, nil, errors.WrapError(err, errors.Internal, "failed to create campaign")
 	}
 
-	return record, nil
+	return record, warnings, nil
 }
 
-func (svc *Service) ListCampaigns(pageNumber, pageSize int, filters *domain.CampaignsFilter) ([]*repository.ListCampaignsRow, error) {
+func (svc *Service) ListCampaigns(ctx context.Context, pageNumber, pageSize int, filters *domain.CampaignsFilter) ([]*repository.ListCampaignsRow, error) {
 	args := repository.ListCampaignsParams{
 		PageNumber: pageNumber,
 		PageSize:   int32(pageSize),
@@ -85,7 +150,7 @@ func (svc *Service) ListCampaigns(pageNumber, pageSize int, filters *domain.Camp
 		Channel:    filters.Channel,
 	}
 
-	records, err := svc.repository.ListCampaigns(&args)
+	records, err := svc.repository.ListCampaigns(ctx, &args)
 	if err != nil {
 		return nil, err
 	}
@@ -93,11 +158,94 @@ func (svc *Service) ListCampaigns(pageNumber, pageSize int, filters *domain.Camp
 	return records, nil
 }
 
-func (svc *Service) RetrieveCampaign(campaignID int64) (*repository.GetCampaignRow, error) {
-	return svc.repository.GetCampaign(campaignID)
+// ListCampaignsByCursor returns the page of campaigns next to cursor, newest
+// first, together with the cursors of the pages around it. A nil cursor asks
+// for the first page. Counting every matching campaign is only done when
+// withTotal is set.
+func (svc *Service) ListCampaignsByCursor(
+	ctx context.Context,
+	pageSize int,
+	filters *domain.CampaignsFilter,
+	cursor *domain.CampaignCursor,
+	withTotal bool,
+) ([]*repository.Campaign, *domain.CampaignCursors, error) {
+	// One extra row tells whether there is another page in the same direction.
+	var records []*repository.Campaign
+	var err error
+	if cursor != nil && cursor.Backward {
+		records, err = svc.repository.ListCampaignsBefore(ctx, &repository.ListCampaignsBeforeParams{
+			Status:          filters.Status,
+			Channel:         filters.Channel,
+			CursorCreatedAt: pgtype.Timestamp{Time: cursor.CreatedAt, Valid: true},
+			CursorID:        cursor.ID,
+			PageSize:        int32(pageSize + 1),
+		})
+	} else {
+		arg := repository.ListCampaignsAfterParams{
+			Status:   filters.Status,
+			Channel:  filters.Channel,
+			PageSize: int32(pageSize + 1),
+		}
+		if cursor != nil {
+			arg.CursorCreatedAt = pgtype.Timestamp{Time: cursor.CreatedAt, Valid: true}
+			arg.CursorID = cursor.ID
+		}
+		records, err = svc.repository.ListCampaignsAfter(ctx, &arg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, cursors := campaignPage(records, pageSize, cursor)
+
+	if withTotal {
+		count, err := svc.repository.CountCampaigns(ctx, &repository.CountCampaignsParams{
+			Status:  filters.Status,
+			Channel: filters.Channel,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		cursors.TotalCount = &count
+	}
+
+	return records, cursors, nil
+}
+
+// campaignPage trims the up to pageSize+1 campaigns read for cursor to a page
+// in listing order and works out the cursors of the pages around it. Campaigns
+// read backwards arrive oldest first.
+func campaignPage(records []*repository.Campaign, pageSize int, cursor *domain.CampaignCursor) ([]*repository.Campaign, *domain.CampaignCursors) {
+	backward := cursor != nil && cursor.Backward
+	more := len(records) > pageSize
+	if more {
+		records = records[:pageSize]
+	}
+	if backward {
+		slices.Reverse(records)
+	}
+
+	cursors := &domain.CampaignCursors{}
+	if len(records) == 0 {
+		return records, cursors
+	}
+
+	first, last := records[0], records[len(records)-1]
+	if (backward && more) || (!backward && cursor != nil) {
+		cursors.Prev = &domain.CampaignCursor{CreatedAt: first.CreatedAt.Time, ID: first.ID, Backward: true}
+	}
+	if (!backward && more) || backward {
+		cursors.Next = &domain.CampaignCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}
+	}
+
+	return records, cursors
 }
 
-func (svc *Service) PreviewMessage(campaignID int64, payload *domain.PreviewMessage) (*domain.PreviewResponse, error) {
+func (svc *Service) RetrieveCampaign(ctx context.Context, campaignID int64) (*repository.GetCampaignRow, error) {
+	return svc.repository.GetCampaign(ctx, campaignID)
+}
+
+func (svc *Service) PreviewMessage(ctx context.Context, campaignID int64, payload *domain.PreviewMessage) (*domain.PreviewResponse, error) {
 	if err := svc.validator.Struct(payload); err != nil {
 		if verr, ok := err.(validator.ValidationErrors); ok {
 			violations := errors.BuildViolations(verr)
@@ -107,35 +255,52 @@ func (svc *Service) PreviewMessage(campaignID int64, payload *domain.PreviewMess
 		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
 	}
 
-	var usedTemplate string
+	campaign, err := svc.repository.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpl *template.Template
+	var warnings []*domain.TemplateWarning
 	if payload.OverrideTemplate != "" {
-		usedTemplate = payload.OverrideTemplate
+		tmpl, warnings, err = compileTemplate(payload.OverrideTemplate, "override_template", "OverrideTemplate", campaign.LenientTemplates)
 	} else {
-		campaign, err := svc.repository.GetCampaign(campaignID)
-		if err != nil {
-			return nil, err
-		}
+		tmpl, warnings, err = compileTemplate(campaign.BaseTemplate, "base_template", "BaseTemplate", campaign.LenientTemplates)
+	}
+	if err != nil {
+		return nil, err
+	}
+	usedTemplate := tmpl.Source()
 
-		usedTemplate = campaign.BaseTemplate
+	customer, err := svc.repository.GetCustomer(ctx, payload.CustomerID)
+	if err != nil {
+		return nil, err
 	}
 
-	customer, err := svc.repository.GetCustomer(payload.CustomerID)
+	message, err := renderMessage(campaign, tmpl, customer)
 	if err != nil {
 		return nil, err
 	}
 
-	message := svc.renderTemplate(usedTemplate, customer)
 	return &domain.PreviewResponse{
-		Message:  message,
+		Message:  message.Content,
 		Template: usedTemplate,
 		Customer: &domain.MinimalCustomer{
 			ID:        customer.ID,
 			FirstName: customer.FirstName.String,
 		},
+		Warnings: warnings,
+		SMS:      message.SMS,
 	}, nil
 }
 
-func (svc *Service) SendCampaign(campaignID int64, payload *domain.SendCampaign) (*domain.SendCampaignResult, error) {
+// SendCampaign checks that the campaign can be sent to the given audience and
+// records a dispatch job for it. The job is published through the outbox and
+// fanned out by the worker tier; progress is read back with
+// RetrieveCampaignDispatch. Duplicate customer IDs are skipped, and so are
+// unknown ones in best-effort mode; fail-fast sends reject unknown IDs.
+// Customers the campaign already reached are skipped unless Resend is set.
+func (svc *Service) SendCampaign(ctx context.Context, campaignID int64, payload *domain.SendCampaign) (*domain.SendCampaignResult, error) {
 	if err := svc.validator.Struct(payload); err != nil {
 		if verr, ok := err.(validator.ValidationErrors); ok {
 			violations := errors.BuildViolations(verr)
@@ -144,154 +309,239 @@ func (svc *Service) SendCampaign(campaignID int64, payload *domain.SendCampaign)
 		return nil, errors.WrapError(err, errors.InvalidArgument, "could not validate request data")
 	}
 
-	campaign, err := svc.repository.GetCampaign(campaignID)
+	campaign, err := svc.repository.GetCampaign(ctx, campaignID)
 	if err != nil {
 		return nil, err
 	}
 
-	g, ctx := errgroup.WithContext(context.Background())
-	sem := make(chan struct{}, 10)
-
-	var queuedCount int32
-
-	for _, customerID := range payload.CustomerIds {
-		customerId := customerID
-
-		g.Go(func() error {
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-			}
-
-			customer, err := svc.repository.GetCustomer(customerId)
-			if err != nil {
-				return err
-			}
-
-			message := svc.renderTemplate(campaign.BaseTemplate, customer)
-			arg := repository.CreateOutboundMessageParams{
-				CampaignID:      campaignID,
-				CustomerID:      customerId,
-				Status:          "pending",
-				RenderedContent: message,
-			}
-
-			err = svc.repository.ExecTx(context.Background(), func(q *repository.Queries) error {
-				msg, err := svc.repository.CreateOutboundMessage(&arg)
-				if err != nil {
-					return err
-				}
-
-				payload := map[string]any{
-					"message_id": msg.ID,
-				}
-				out, err := json.Marshal(payload)
-				if err != nil {
-					return err
-				}
-
-				task := asynq.NewTask(domain.SendMessageTask, out)
-				if campaign.ScheduledAt.Valid {
-					_, err = svc.broker.Enqueue(task, asynq.Queue(svc.queue), asynq.ProcessAt(campaign.ScheduledAt.Time))
-				} else {
-					_, err = svc.broker.Enqueue(task, asynq.Queue(svc.queue))
-				}
-				if err != nil {
-					return err
-				}
-
-				return nil
-			})
-
-			if err != nil {
-				return err
-			}
-
-			atomic.AddInt32(&queuedCount, 1)
-			return nil
-		})
+	if _, err := sendableTemplate(campaign); err != nil {
+		return nil, err
 	}
 
-	if err := g.Wait(); err != nil {
-		return &domain.SendCampaignResult{
-			CampaignID:     campaignID,
-			MessagesQueued: queuedCount,
-			Status:         "sending",
-		}, err
+	// A recurring campaign sends through its runs.
+	if campaign.Recurrence.Valid {
+		return nil, errors.WrapError(
+			fmt.Errorf("campaign %d recurs and cannot be sent by hand", campaign.ID),
+			errors.FailedPrecondition,
+			"CAMPAIGN_RECURRING",
+		)
 	}
 
-	return &domain.SendCampaignResult{
-		CampaignID:     campaignID,
-		MessagesQueued: queuedCount,
-		Status:         "sending",
-	}, nil
-}
+	mode := payload.Mode
+	if mode == "" {
+		mode = domain.SendFailFast
+	}
 
-func (svc *Service) renderTemplate(template string, data any) string {
-	if template == "" || data == nil {
-		return template
+	arg := repository.CreateCampaignDispatchParams{
+		CampaignID: campaign.ID,
+		Mode:       mode,
 	}
+	if payload.Resend {
+		arg.ResendReason = pgtype.Text{String: payload.ResendReason, Valid: true}
+	}
+	skipped := newOutcomes(0)
+	if payload.SegmentID != 0 {
+		if _, err := svc.repository.GetSegment(ctx, payload.SegmentID); err != nil {
+			return nil, err
+		}
+
+		total, err := svc.repository.CountSegmentCustomers(ctx, payload.SegmentID)
+		if err != nil {
+			return nil, err
+		}
 
-	val := reflect.ValueOf(data)
-	if val.Kind() == reflect.Ptr {
-		if val.IsNil() {
-			return template
+		arg.SegmentID = pgtype.Int8{Int64: payload.SegmentID, Valid: true}
+		arg.TotalCustomers = int32(total)
+	} else {
+		existing, err := svc.repository.ListExistingCustomerIds(ctx, payload.CustomerIds)
+		if err != nil {
+			return nil, err
+		}
+
+		var missing []int64
+		arg.CustomerIds, skipped, missing = splitAudience(payload.CustomerIds, existing)
+		if len(missing) > 0 && mode == domain.SendFailFast {
+			return nil, errors.WrapError(
+				fmt.Errorf("customers %v do not exist", missing),
+				errors.NotFound,
+				"CUSTOMER_NOT_FOUND",
+			)
 		}
-		val = val.Elem()
+
+		arg.TotalCustomers = int32(len(payload.CustomerIds))
+		arg.SkippedCustomers = skipped.skipped
 	}
-	if val.Kind() != reflect.Struct {
-		return template
+
+	var dispatch *repository.CampaignDispatch
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		dispatch, err = svc.createDispatch(ctx, q, &arg, skipped)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "SAVE_CAMPAIGN_DISPATCH_ERROR")
+	}
+
+	return svc.dispatchResult(ctx, dispatch)
+}
+
+// createDispatch records a dispatch job with the customers it skipped up front
+// and publishes it through the outbox, as part of the caller's transaction.
+func (svc *Service) createDispatch(
+	ctx context.Context,
+	q *repository.Queries,
+	arg *repository.CreateCampaignDispatchParams,
+	skipped *outcomes,
+) (*repository.CampaignDispatch, error) {
+	dispatch, err := q.CreateCampaignDispatch(ctx, arg)
+	if err != nil {
+		return nil, err
 	}
 
-	re := regexp.MustCompile(`\{([^}]+)\}`)
-	result := re.ReplaceAllStringFunc(template, func(match string) string {
-		fieldName := match[1 : len(match)-1]
-		field := val.FieldByName(fieldName)
-		if !field.IsValid() {
-			return match
+	if len(skipped.CustomerIds) > 0 {
+		skipped.DispatchID = dispatch.ID
+		if err := q.CreateCampaignDispatchOutcomes(ctx, &skipped.CreateCampaignDispatchOutcomesParams); err != nil {
+			return nil, err
 		}
+	}
+
+	entry, err := svc.outboxEntry(domain.DispatchCampaignTask, domain.DispatchCampaign{DispatchID: dispatch.ID}, pgtype.Timestamptz{})
+	if err != nil {
+		return nil, err
+	}
+	entry.MaxRetry = pgtype.Int4{Int32: dispatchMaxRetry, Valid: true}
+
+	if _, err := q.CreateOutboxEntry(ctx, entry); err != nil {
+		return nil, err
+	}
 
-		if field.Kind() == reflect.Ptr {
-			if field.IsNil() {
-				return ""
-			}
-			field = field.Elem()
+	return dispatch, nil
+}
+
+// sendableTemplate checks that a campaign may be sent and compiles its
+// template. Campaigns created before templates were validated may not pass the
+// checks, so the template is checked again before anything is queued.
+func sendableTemplate(campaign *repository.GetCampaignRow) (*template.Template, error) {
+	if !slices.Contains(domain.CampaignTransitionSources(domain.CampaignSending), campaign.Status) {
+		return nil, errors.WrapError(
+			fmt.Errorf("%w: campaign %d is %s", domain.ErrIllegalTransition, campaign.ID, campaign.Status),
+			errors.FailedPrecondition,
+			"CAMPAIGN_NOT_SENDABLE",
+		)
+	}
+
+	tmpl, _, err := compileTemplate(campaign.BaseTemplate, "base_template", "BaseTemplate", campaign.LenientTemplates)
+	return tmpl, err
+}
+
+// markCampaignSending moves the campaign to sending once its messages have been
+// queued, then attempts the roll-up in case the worker already delivered all of
+// them before the transition landed.
+func (svc *Service) markCampaignSending(ctx context.Context, campaignID int64) (string, error) {
+	arg := repository.TransitionCampaignParams{
+		ToStatus:   domain.CampaignSending,
+		CampaignID: campaignID,
+	}
+	campaign, err := svc.repository.TransitionCampaign(ctx, &arg)
+	if err != nil {
+		return "", transitionError(err)
+	}
+
+	finalized, err := svc.repository.FinalizeCampaign(ctx, campaignID)
+	if err != nil {
+		return "", err
+	}
+	if finalized != nil {
+		return finalized.Status, nil
+	}
+
+	return campaign.Status, nil
+}
+
+// RetryMessage puts a failed message back to pending and queues a new delivery
+// attempt for it through the outbox. The message takes the new task's ID in
+// the same transaction, so it only accepts that task.
+func (svc *Service) RetryMessage(ctx context.Context, messageID int64) (*repository.OutboundMessage, error) {
+	entry, err := svc.outboxEntry(domain.SendMessageTask, domain.SendMessage{MessageID: messageID}, pgtype.Timestamptz{})
+	if err != nil {
+		return nil, errors.WrapError(err, errors.Internal, "RETRY_MESSAGE_ERROR")
+	}
+
+	var msg *repository.OutboundMessage
+	err = svc.repository.ExecTx(ctx, func(q *repository.Queries) error {
+		var err error
+		msg, err = q.TransitionOutboundMessage(ctx, &repository.TransitionOutboundMessageParams{
+			ToStatus:     domain.MessagePending,
+			MessageID:    messageID,
+			FromStatuses: domain.MessageTransitionSources(domain.MessagePending),
+		})
+		if err != nil {
+			return err
 		}
 
-		if field.CanInterface() {
-			if s, ok := field.Interface().(fmt.Stringer); ok {
-				return s.String()
-			}
+		detail, err := json.Marshal(retriedDetail{RetryCount: msg.RetryCount})
+		if err != nil {
+			return err
 		}
 
-		if field.Kind() == reflect.Struct {
-			// Handle common pgtype structs
-			// pgtype.Text -> String
-			if sf := field.FieldByName("String"); sf.IsValid() {
-				return fmt.Sprint(sf.Interface())
-			}
-
-			// pgtype.Timestamp -> Time (format as RFC3339)
-			if tf := field.FieldByName("Time"); tf.IsValid() {
-				if tf.CanInterface() {
-					if tt, ok := tf.Interface().(time.Time); ok {
-						return tt.Format(time.RFC3339)
-					}
-					return fmt.Sprint(tf.Interface())
-				}
-			}
+		_, err = q.CreateMessageEvent(ctx, &repository.CreateMessageEventParams{
+			MessageID: msg.ID,
+			EventType: domain.MessageEventStatusChanged,
+			Status:    msg.Status,
+			Detail:    detail,
+		})
+		if err != nil {
+			return err
 		}
 
-		if field.CanInterface() {
-			return fmt.Sprint(field.Interface())
+		created, err := q.CreateOutboxEntry(ctx, entry)
+		if err != nil {
+			return err
 		}
 
-		return match
+		msg.TaskID = pgtype.Text{String: domain.OutboxTaskID(created.ID), Valid: true}
+		return q.SetOutboundMessageTaskIds(ctx, &repository.SetOutboundMessageTaskIdsParams{
+			MessageIds: []int64{msg.ID},
+			TaskIds:    []string{msg.TaskID.String},
+		})
 	})
+	if err == nil {
+		return msg, nil
+	}
+
+	if !stderrors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.WrapError(err, errors.Internal, "RETRY_MESSAGE_ERROR")
+	}
+
+	current, err := svc.repository.GetOutboundMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, transitionError(fmt.Errorf("%w: message %d cannot move from %s to %s", domain.ErrIllegalTransition, current.ID, current.Status, domain.MessagePending))
+}
+
+// outboxEntry builds the outbox row for a task on the service's queue.
+func (svc *Service) outboxEntry(taskType string, payload any, processAt pgtype.Timestamptz) (*repository.CreateOutboxEntryParams, error) {
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.CreateOutboxEntryParams{
+		TaskType:  taskType,
+		Queue:     svc.queue,
+		Payload:   out,
+		ProcessAt: processAt,
+	}, nil
+}
+
+// transitionError surfaces refused status transitions as a typed precondition
+// failure; other errors are returned unchanged.
+func transitionError(err error) error {
+	if stderrors.Is(err, domain.ErrIllegalTransition) {
+		return errors.WrapError(err, errors.FailedPrecondition, "ILLEGAL_STATUS_TRANSITION")
+	}
 
-	return result
+	return err
 }
@@ -1,239 +1,509 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/ports"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mwinyimoha/commons/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
-type MockCustomer struct {
-	ID               int64
-	FirstName        pgtype.Text
-	LastName         pgtype.Text
-	Location         pgtype.Text
-	PreferredProduct pgtype.Text
-	Phone            string
-	CreatedAt        pgtype.Timestamp
-}
-
-type SimpleStruct struct {
-	Name  string
-	Age   int
-	Email string
-}
-
-type StructWithPointers struct {
-	Name  *string
-	Email *string
-}
-
-func TestRenderTemplate_SimpleSubstitution(t *testing.T) {
-	svc := &Service{}
-
-	tests := []struct {
-		name     string
-		template string
-		data     any
-		expected string
-	}{
-		{
-			name:     "single field substitution",
-			template: "Hi {Name}",
-			data: &SimpleStruct{
-				Name: "John",
-			},
-			expected: "Hi John",
-		},
-		{
-			name:     "multiple field substitutions",
-			template: "Hello {Name}, you are {Age} years old",
-			data: &SimpleStruct{
-				Name: "Alice",
-				Age:  30,
-			},
-			expected: "Hello Alice, you are 30 years old",
-		},
-		{
-			name:     "all fields substituted",
-			template: "{Name} - {Email}",
-			data: &SimpleStruct{
-				Name:  "Bob",
-				Email: "bob@example.com",
-			},
-			expected: "Bob - bob@example.com",
-		},
-	}
+func renderFor(t *testing.T, src string, customer *repository.Customer) string {
+	t.Helper()
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := svc.renderTemplate(tt.template, tt.data)
-			assert.Equal(t, tt.expected, result)
-		})
+	tmpl, err := parseTemplate(src, "base_template", "BaseTemplate")
+	if !assert.NoError(t, err) {
+		t.FailNow()
 	}
-}
 
-func TestRenderTemplate_PgtypeTimestampFields(t *testing.T) {
-	svc := &Service{}
+	return tmpl.Render(customerContext(customer))
+}
 
+func TestRenderTemplate_CustomerFields(t *testing.T) {
 	ts := time.Date(2024, 12, 4, 15, 30, 0, 0, time.UTC)
-	customer := &MockCustomer{
+	customer := &repository.Customer{
+		ID:               1,
+		FirstName:        pgtype.Text{String: "John", Valid: true},
+		CreatedAt:        pgtype.Timestamp{Time: ts, Valid: true},
+		PreferredProduct: pgtype.Text{String: "White Sneakers", Valid: true},
+	}
+
+	assert.Equal(t, "User John created at "+ts.Format(time.RFC3339), renderFor(t, "User {FirstName} created at {CreatedAt}", customer))
+	assert.Equal(t, "John likes WHITE SNEAKERS", renderFor(t, "{FirstName} likes {PreferredProduct|upper}", customer))
+}
+
+func TestRenderTemplate_PgtypeInvalidFields(t *testing.T) {
+	customer := &repository.Customer{
 		ID:        1,
-		FirstName: pgtype.Text{String: "John", Valid: true},
-		CreatedAt: pgtype.Timestamp{Time: ts, Valid: true},
+		FirstName: pgtype.Text{String: "", Valid: false},
+		LastName:  pgtype.Text{String: "Doe", Valid: true},
 	}
 
-	result := svc.renderTemplate("User {FirstName} created at {CreatedAt}", customer)
-	expectedTime := ts.Format(time.RFC3339)
-	expected := "User John created at " + expectedTime
+	assert.Equal(t, "Name:  Doe", renderFor(t, "Name: {FirstName} {LastName}", customer))
+	assert.Equal(t, "Hi Guest", renderFor(t, "Hi {FirstName|Guest}", customer))
+}
 
-	assert.Equal(t, expected, result)
+func TestRenderTemplate_ComplexScenario(t *testing.T) {
+	customer := &repository.Customer{
+		FirstName:        pgtype.Text{String: "Mohammed", Valid: true},
+		LastName:         pgtype.Text{String: "Mwijaa", Valid: true},
+		Location:         pgtype.Text{String: "Mombasa", Valid: true},
+		PreferredProduct: pgtype.Text{String: "White Sneakers", Valid: true},
+		Phone:            "+254712832088",
+	}
+
+	template := "Hi {FirstName}, thank you for choosing us. We have {PreferredProduct} in stock at our {Location} store. Call us at {Phone} or visit {NonExistent}."
+	expected := "Hi Mohammed, thank you for choosing us. We have White Sneakers in stock at our Mombasa store. Call us at +254712832088 or visit {NonExistent}."
+
+	assert.Equal(t, expected, renderFor(t, template, customer))
 }
 
-func TestRenderTemplate_PointerFields(t *testing.T) {
-	svc := &Service{}
+func TestParseTemplate_SyntaxError(t *testing.T) {
+	_, err := parseTemplate("Hi {if FirstName}{FirstName}", "override_template", "OverrideTemplate")
 
-	name := "Charlie"
-	email := "charlie@example.com"
+	_, ok := err.(*errors.Error)
+	assert.True(t, ok)
+}
 
-	tests := []struct {
-		name     string
-		template string
-		data     any
-		expected string
-	}{
-		{
-			name:     "pointer fields with valid values",
-			template: "{Name} - {Email}",
-			data: &StructWithPointers{
-				Name:  &name,
-				Email: &email,
-			},
-			expected: "Charlie - charlie@example.com",
-		},
-		{
-			name:     "pointer field is nil",
-			template: "Name: {Name}, Email: {Email}",
-			data: &StructWithPointers{
-				Name:  &name,
-				Email: nil,
-			},
-			expected: "Name: Charlie, Email: ",
-		},
+func TestCompileTemplate_UnknownPlaceholders(t *testing.T) {
+	src := "Hi {Firstname}, visit {Store}"
+
+	_, _, err := compileTemplate(src, "base_template", "BaseTemplate", false)
+	_, ok := err.(*errors.Error)
+	assert.True(t, ok)
+
+	tmpl, warnings, err := compileTemplate(src, "base_template", "BaseTemplate", true)
+	if !assert.NoError(t, err) {
+		t.FailNow()
 	}
+	assert.Equal(t, []*domain.TemplateWarning{
+		{Placeholder: "{Firstname}", Offset: 3, Suggestion: "FirstName"},
+		{Placeholder: "{Store}", Offset: 22},
+	}, warnings)
+	assert.Equal(t, "Hi {Firstname}, visit {Store}", tmpl.Render(customerContext(&repository.Customer{})))
+
+	_, warnings, err = compileTemplate("Hi {FirstName}", "base_template", "BaseTemplate", false)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := svc.renderTemplate(tt.template, tt.data)
-			assert.Equal(t, tt.expected, result)
-		})
+func TestUnknownPlaceholder_Message(t *testing.T) {
+	tmpl, _, _ := compileTemplate("Hi {Firstname}", "base_template", "BaseTemplate", true)
+	assert.Equal(t, "unknown placeholder {Firstname} at offset 3, did you mean FirstName?", unknownPlaceholder(tmpl.UnknownFields()[0]))
+}
+
+func TestRenderMessage_SMSSegments(t *testing.T) {
+	tmpl, err := parseTemplate("Hello {FirstName}, our Festive Sale is live — get 25% off!", "base_template", "BaseTemplate")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	customer := &repository.Customer{ID: 3, FirstName: pgtype.Text{String: "Ann", Valid: true}}
+
+	msg, err := renderMessage(&repository.GetCampaignRow{Channel: "whatsapp"}, tmpl, customer)
+	if assert.NoError(t, err) {
+		assert.Nil(t, msg.SMS)
+	}
+
+	msg, err = renderMessage(&repository.GetCampaignRow{Channel: "sms"}, tmpl, customer)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "UCS-2", msg.SMS.Encoding)
+		assert.Equal(t, 1, msg.SMS.Segments)
+		assert.Equal(t, []string{"—"}, msg.SMS.Offending)
 	}
+
+	long, _ := parseTemplate(strings.Repeat("x", 161), "base_template", "BaseTemplate")
+	capped := &repository.GetCampaignRow{Channel: "sms", MaxSegments: pgtype.Int4{Int32: 1, Valid: true}}
+	_, err = renderMessage(capped, long, customer)
+	_, ok := err.(*errors.Error)
+	assert.True(t, ok)
 }
 
-func TestRenderTemplate_FallbackBehavior(t *testing.T) {
-	svc := &Service{}
+func TestCampaignPage(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	campaign := func(id int64) *repository.Campaign {
+		return &repository.Campaign{ID: id, CreatedAt: pgtype.Timestamp{Time: base.Add(time.Duration(id) * time.Minute), Valid: true}}
+	}
+	ids := func(records []*repository.Campaign) []int64 {
+		var out []int64
+		for _, rec := range records {
+			out = append(out, rec.ID)
+		}
+		return out
+	}
+
+	t.Run("first page with more", func(t *testing.T) {
+		records, cursors := campaignPage([]*repository.Campaign{campaign(9), campaign(8), campaign(7)}, 2, nil)
+		assert.Equal(t, []int64{9, 8}, ids(records))
+		assert.Nil(t, cursors.Prev)
+		if assert.NotNil(t, cursors.Next) {
+			assert.Equal(t, int64(8), cursors.Next.ID)
+			assert.False(t, cursors.Next.Backward)
+		}
+	})
+
+	t.Run("last page forward", func(t *testing.T) {
+		cursor := &domain.CampaignCursor{CreatedAt: base.Add(8 * time.Minute), ID: 8}
+		records, cursors := campaignPage([]*repository.Campaign{campaign(7)}, 2, cursor)
+		assert.Equal(t, []int64{7}, ids(records))
+		assert.Nil(t, cursors.Next)
+		if assert.NotNil(t, cursors.Prev) {
+			assert.Equal(t, int64(7), cursors.Prev.ID)
+			assert.True(t, cursors.Prev.Backward)
+		}
+	})
+
+	t.Run("backward page is reversed", func(t *testing.T) {
+		cursor := &domain.CampaignCursor{CreatedAt: base.Add(7 * time.Minute), ID: 7, Backward: true}
+		records, cursors := campaignPage([]*repository.Campaign{campaign(8), campaign(9)}, 2, cursor)
+		assert.Equal(t, []int64{9, 8}, ids(records))
+		assert.Nil(t, cursors.Prev)
+		if assert.NotNil(t, cursors.Next) {
+			assert.Equal(t, int64(8), cursors.Next.ID)
+		}
+	})
+
+	t.Run("backward page with more", func(t *testing.T) {
+		cursor := &domain.CampaignCursor{CreatedAt: base.Add(5 * time.Minute), ID: 5, Backward: true}
+		records, cursors := campaignPage([]*repository.Campaign{campaign(6), campaign(7), campaign(8)}, 2, cursor)
+		assert.Equal(t, []int64{7, 6}, ids(records))
+		if assert.NotNil(t, cursors.Prev) {
+			assert.Equal(t, int64(7), cursors.Prev.ID)
+		}
+		if assert.NotNil(t, cursors.Next) {
+			assert.Equal(t, int64(6), cursors.Next.ID)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		records, cursors := campaignPage(nil, 2, nil)
+		assert.Empty(t, records)
+		assert.Nil(t, cursors.Next)
+		assert.Nil(t, cursors.Prev)
+	})
+}
 
-	tests := []struct {
-		name     string
-		template string
-		data     any
-		expected string
-	}{
-		{
-			name:     "field not found - placeholder unchanged",
-			template: "Hi {NonExistent}",
-			data: &SimpleStruct{
-				Name: "John",
-			},
-			expected: "Hi {NonExistent}",
-		},
-		{
-			name:     "multiple placeholders, some not found",
-			template: "{Name} works at {Company} in {Location}",
-			data: &SimpleStruct{
-				Name: "John",
-			},
-			expected: "John works at {Company} in {Location}",
-		},
-		{
-			name:     "empty template",
-			template: "",
-			data: &SimpleStruct{
-				Name: "John",
-			},
-			expected: "",
-		},
-		{
-			name:     "nil data",
-			template: "Hello {Name}",
-			data:     nil,
-			expected: "Hello {Name}",
-		},
-		{
-			name:     "non-struct data",
-			template: "Hello {Name}",
-			data:     "not a struct",
-			expected: "Hello {Name}",
-		},
+// fakeDB stands in for the transaction ExecTx runs in. It records the
+// queries run by name and answers each QueryRow with the row the test set for
+// that query, or with zero values, and each Query with the rows set for it,
+// or with none.
+type fakeDB struct {
+	queries []string
+	args    map[string][]interface{}
+	rows    map[string]func(args []interface{}) fakeRow
+	results map[string][][]any
+}
+
+func (f *fakeDB) record(sql string, args []interface{}) string {
+	name := strings.Fields(strings.TrimPrefix(sql, "-- name: "))[0]
+	f.queries = append(f.queries, name)
+	f.args[name] = args
+	return name
+}
+
+func (f *fakeDB) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	f.record(sql, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func (f *fakeDB) Query(_ context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return &fakeRows{values: f.results[f.record(sql, args)]}, nil
+}
+
+func (f *fakeDB) QueryRow(_ context.Context, sql string, args ...interface{}) pgx.Row {
+	if row, ok := f.rows[f.record(sql, args)]; ok {
+		return row(args)
+	}
+	return fakeRow{}
+}
+
+// fakeRow scans its values into the leading destinations.
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	for i, v := range r.values {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// fakeRows returns its values one row at a time.
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	next   int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	return fakeRow{values: r.values[r.next-1]}.Scan(dest...)
+}
+
+func (r *fakeRows) Err() error { return nil }
+
+func (r *fakeRows) Close() {}
+
+// fakeInspector records the tasks it is asked to delete.
+type fakeInspector struct {
+	deleted []string
+}
+
+func (f *fakeInspector) DeleteTask(_, id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+// fakeRepository holds campaigns, dispatches, customers and imports in memory and moves campaigns
+// between statuses by the same rules as TransitionCampaign in the database.
+// Transactions run against db, and txs and reads count them and the reads
+// outside of them.
+type fakeRepository struct {
+	ports.AppRepository
+	db         *fakeDB
+	campaigns  map[int64]*repository.Campaign
+	dispatches map[int64]*repository.CampaignDispatch
+	customers  map[int64]*repository.Customer
+	messages   map[int64]*repository.OutboundMessage
+	messaged   map[int64][]int64
+	imports    map[int64]*repository.CustomerImport
+	importKeys map[string]*repository.CustomerImportKey
+	finalized  []int64
+	txs        int
+	reads      int
+}
+
+func (f *fakeRepository) ExecTx(_ context.Context, fn func(*repository.Queries) error) error {
+	f.txs++
+	return fn(repository.New(f.db))
+}
+
+func (f *fakeRepository) GetCampaign(_ context.Context, ID int64) (*repository.GetCampaignRow, error) {
+	f.reads++
+	c := f.campaigns[ID]
+	return &repository.GetCampaignRow{ID: c.ID, Channel: c.Channel, Status: c.Status, BaseTemplate: c.BaseTemplate}, nil
+}
+
+func (f *fakeRepository) GetCampaignStatus(_ context.Context, ID int64) (string, error) {
+	return f.campaigns[ID].Status, nil
+}
+
+func (f *fakeRepository) TransitionCampaign(_ context.Context, arg *repository.TransitionCampaignParams) (*repository.Campaign, error) {
+	c := f.campaigns[arg.CampaignID]
+	if !slices.Contains(domain.CampaignTransitionSources(arg.ToStatus), c.Status) {
+		return nil, fmt.Errorf("%w: campaign %d cannot move from %s to %s", domain.ErrIllegalTransition, c.ID, c.Status, arg.ToStatus)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := svc.renderTemplate(tt.template, tt.data)
-			assert.Equal(t, tt.expected, result)
-		})
+	c.Status = arg.ToStatus
+	return c, nil
+}
+
+func (f *fakeRepository) FinalizeCampaign(_ context.Context, ID int64) (*repository.Campaign, error) {
+	f.finalized = append(f.finalized, ID)
+	return nil, nil
+}
+
+func (f *fakeRepository) GetCampaignDispatch(_ context.Context, ID int64) (*repository.CampaignDispatch, error) {
+	d := *f.dispatches[ID]
+	return &d, nil
+}
+
+func (f *fakeRepository) StartCampaignDispatch(_ context.Context, ID int64) (*repository.CampaignDispatch, error) {
+	f.dispatches[ID].Status = domain.DispatchRunning
+	f.dispatches[ID].LastError = pgtype.Text{}
+	return f.GetCampaignDispatch(context.Background(), ID)
+}
+
+func (f *fakeRepository) FinishCampaignDispatch(_ context.Context, arg *repository.FinishCampaignDispatchParams) (*repository.CampaignDispatch, error) {
+	f.dispatches[arg.DispatchID].Status = arg.Status
+	f.dispatches[arg.DispatchID].LastError = arg.LastError
+	return f.GetCampaignDispatch(context.Background(), arg.DispatchID)
+}
+
+func (f *fakeRepository) ListExistingCustomerIds(_ context.Context, IDs []int64) ([]int64, error) {
+	var existing []int64
+	for _, id := range IDs {
+		if _, ok := f.customers[id]; ok {
+			existing = append(existing, id)
+		}
 	}
+	return existing, nil
 }
 
-func TestRenderTemplate_DirectStructValue(t *testing.T) {
-	svc := &Service{}
+func (f *fakeRepository) ListCustomersByIds(_ context.Context, arg *repository.ListCustomersByIdsParams) ([]*repository.Customer, error) {
+	ids := slices.Clone(arg.CustomerIds)
+	slices.Sort(ids)
 
-	customer := SimpleStruct{
-		Name:  "David",
-		Email: "david@example.com",
+	var customers []*repository.Customer
+	for _, id := range slices.Compact(ids) {
+		if c, ok := f.customers[id]; ok && id > arg.AfterID && len(customers) < int(arg.BatchSize) {
+			customers = append(customers, c)
+		}
 	}
+	return customers, nil
+}
 
-	result := svc.renderTemplate("Hello {Name}", customer)
-	assert.Equal(t, "Hello David", result)
+func (f *fakeRepository) ListMessagedCustomerIds(_ context.Context, arg *repository.ListMessagedCustomerIdsParams) ([]int64, error) {
+	var messaged []int64
+	for _, id := range f.messaged[arg.CampaignID] {
+		if slices.Contains(arg.CustomerIds, id) {
+			messaged = append(messaged, id)
+		}
+	}
+	return messaged, nil
 }
 
-func TestRenderTemplate_PgtypeInvalidFields(t *testing.T) {
-	svc := &Service{}
+func (f *fakeRepository) GetOutboundMessage(_ context.Context, ID int64) (*repository.OutboundMessage, error) {
+	f.reads++
+	return f.messages[ID], nil
+}
 
-	customer := &MockCustomer{
-		ID:        1,
-		FirstName: pgtype.Text{String: "", Valid: false},
-		LastName:  pgtype.Text{String: "Doe", Valid: true},
+func (f *fakeRepository) GetCustomerImport(_ context.Context, ID int64) (*repository.CustomerImport, error) {
+	return f.imports[ID], nil
+}
+
+func (f *fakeRepository) GetCustomerImportKey(_ context.Context, key string) (*repository.CustomerImportKey, error) {
+	return f.importKeys[key], nil
+}
+
+func newFakeService(campaigns ...*repository.Campaign) (*Service, *fakeRepository) {
+	repo := &fakeRepository{
+		db: &fakeDB{
+			args:    map[string][]interface{}{},
+			rows:    map[string]func([]interface{}) fakeRow{},
+			results: map[string][][]any{},
+		},
+		campaigns:  map[int64]*repository.Campaign{},
+		dispatches: map[int64]*repository.CampaignDispatch{},
+		customers:  map[int64]*repository.Customer{},
+		messages:   map[int64]*repository.OutboundMessage{},
+		messaged:   map[int64][]int64{},
+		imports:    map[int64]*repository.CustomerImport{},
+		importKeys: map[string]*repository.CustomerImportKey{},
+	}
+	for _, c := range campaigns {
+		repo.campaigns[c.ID] = c
 	}
 
-	result := svc.renderTemplate("Name: {FirstName} {LastName}", customer)
-	assert.Equal(t, "Name:  Doe", result)
+	return &Service{
+		repository:        repo,
+		inspector:         &fakeInspector{},
+		validator:         validator.New(),
+		logger:            zap.NewNop(),
+		dispatchBatchSize: 100,
+	}, repo
 }
 
-func TestRenderTemplate_NoPlaceholders(t *testing.T) {
-	svc := &Service{}
+func TestSendCampaign_SentCampaign(t *testing.T) {
+	svc, _ := newFakeService(&repository.Campaign{ID: 1, Channel: "sms", Status: domain.CampaignSent, BaseTemplate: "Hi {FirstName}"})
 
-	template := "Just plain text with no placeholders"
-	result := svc.renderTemplate(template, &SimpleStruct{Name: "John"})
+	_, err := svc.SendCampaign(context.Background(), 1, &domain.SendCampaign{CustomerIds: []int64{1}})
+	_, ok := err.(*errors.Error)
+	assert.True(t, ok, "a sent campaign is refused before anything is queued")
+}
 
-	assert.Equal(t, template, result)
+func TestMarkCampaignSending(t *testing.T) {
+	svc, repo := newFakeService(
+		&repository.Campaign{ID: 1, Status: domain.CampaignSent},
+		&repository.Campaign{ID: 2, Status: domain.CampaignDraft},
+	)
+
+	_, err := svc.markCampaignSending(context.Background(), 1)
+	_, ok := err.(*errors.Error)
+	assert.True(t, ok, "illegal transitions surface as typed errors")
+	assert.Equal(t, domain.CampaignSent, repo.campaigns[1].Status)
+	assert.Empty(t, repo.finalized)
+
+	status, err := svc.markCampaignSending(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.CampaignSending, status)
+	assert.Equal(t, []int64{2}, repo.finalized, "the roll-up is attempted once the campaign is sending")
 }
 
-func TestRenderTemplate_ComplexScenario(t *testing.T) {
-	svc := &Service{}
+func TestRetryMessage(t *testing.T) {
+	svc, repo := newFakeService()
+	repo.db.rows["TransitionOutboundMessage"] = func([]interface{}) fakeRow { return fakeRow{values: []any{int64(7)}} }
+	repo.db.rows["CreateOutboxEntry"] = func([]interface{}) fakeRow { return fakeRow{values: []any{int64(3)}} }
 
-	customer := &MockCustomer{
-		FirstName:        pgtype.Text{String: "Mohammed", Valid: true},
-		LastName:         pgtype.Text{String: "Mwijaa", Valid: true},
-		Location:         pgtype.Text{String: "Mombasa", Valid: true},
-		PreferredProduct: pgtype.Text{String: "White Sneakers", Valid: true},
-		Phone:            "+254712832088",
+	msg, err := svc.RetryMessage(context.Background(), 7)
+	if !assert.NoError(t, err) {
+		t.FailNow()
 	}
+	assert.Equal(t, 1, repo.txs)
+	assert.Equal(t, []string{
+		"TransitionOutboundMessage",
+		"CreateMessageEvent",
+		"CreateOutboxEntry",
+		"SetOutboundMessageTaskIds",
+	}, repo.db.queries, "the message, its event and its task commit together")
+	assert.Equal(t, domain.OutboxTaskID(3), msg.TaskID.String)
+	assert.Equal(t, []interface{}{[]int64{7}, []string{domain.OutboxTaskID(3)}}, repo.db.args["SetOutboundMessageTaskIds"])
+	assert.Zero(t, repo.reads)
+}
 
-	template := "Hi {FirstName}, thank you for choosing us. We have {PreferredProduct} in stock at our {Location} store. Call us at {Phone} or visit {NonExistent}."
-	result := svc.renderTemplate(template, customer)
+func TestRetryMessage_Refused(t *testing.T) {
+	svc, repo := newFakeService()
+	repo.messages[7] = &repository.OutboundMessage{ID: 7, Status: domain.MessageDelivered}
+	repo.db.rows["TransitionOutboundMessage"] = func([]interface{}) fakeRow { return fakeRow{err: pgx.ErrNoRows} }
 
-	expected := "Hi Mohammed, thank you for choosing us. We have White Sneakers in stock at our Mombasa store. Call us at +254712832088 or visit {NonExistent}."
-	assert.Equal(t, expected, result)
+	_, err := svc.RetryMessage(context.Background(), 7)
+	_, ok := err.(*errors.Error)
+	assert.True(t, ok, "a refused retry surfaces as a typed error")
+	assert.Equal(t, []string{"TransitionOutboundMessage"}, repo.db.queries, "nothing is queued")
+	assert.Equal(t, 1, repo.reads, "the current status is read once the transition matched nothing")
+}
+
+func TestNullableTimestamp(t *testing.T) {
+	ts := nullableTimestamp("2030-01-07T10:00:00+03:00")
+	assert.True(t, ts.Valid)
+	assert.Equal(t, time.Date(2030, 1, 7, 7, 0, 0, 0, time.UTC), ts.Time, "the offset is applied, not dropped")
+
+	assert.False(t, nullableTimestamp("").Valid)
+}
+
+func TestTimestampValidation(t *testing.T) {
+	v := validator.New()
+	v.RegisterValidation("valid_timestamp", validTimestamp)
+	v.RegisterValidation("valid_scheduled_at", validScheduledAt)
+	v.RegisterValidation("valid_cron", validCron)
+	v.RegisterValidation("valid_phone_prefix", validPhonePrefix)
+
+	assert.Error(t, v.Struct(&domain.MessagesFilter{CreatedAfter: "2030-01-07T10:00:00"}), "filters need an offset")
+	assert.NoError(t, v.Struct(&domain.MessagesFilter{CreatedAfter: "2030-01-07T10:00:00+03:00"}))
+
+	campaign := &domain.CreateCampaign{Name: "Launch", Channel: "sms", BaseTemplate: "Hi", ScheduledAt: "2030-01-07T10:00:00"}
+	assert.NoError(t, v.Struct(campaign), "a schedule may leave the offset to the campaign's timezone")
+}
+
+func TestPhonePrefixValidation(t *testing.T) {
+	v := validator.New()
+	v.RegisterValidation("valid_timestamp", validTimestamp)
+	v.RegisterValidation("valid_phone_prefix", validPhonePrefix)
+
+	assert.NoError(t, v.Struct(&domain.CreateSegment{Name: "Kenya", PhonePrefix: "+254"}))
+	for _, prefix := range []string{"+", "+25%", "+2_4", "254", "+1234567890123456"} {
+		assert.Error(t, v.Struct(&domain.CreateSegment{Name: "Kenya", PhonePrefix: prefix}), prefix)
+	}
 }
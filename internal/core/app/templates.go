@@ -0,0 +1,149 @@
+package app
+
+import (
+	stderrors "errors"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/sms"
+	"focus-dev-challenge/internal/core/template"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mwinyimoha/commons/pkg/errors"
+)
+
+// parseTemplate compiles a template supplied through the given payload field,
+// reporting syntax errors as validation errors that carry their position.
+func parseTemplate(src, field, structField string) (*template.Template, error) {
+	tmpl, err := template.Parse(src)
+	if err != nil {
+		var terr *template.Error
+		if !stderrors.As(err, &terr) {
+			return nil, err
+		}
+
+		return nil, validationError(&fieldError{
+			field:       field,
+			structField: structField,
+			tag:         "template",
+			param:       terr.Error(),
+			value:       src,
+		})
+	}
+
+	return tmpl, nil
+}
+
+// compileTemplate parses a template and checks that every placeholder names a
+// customer field. Unknown placeholders fail validation, one violation each,
+// unless lenient is set, in which case they are returned as warnings.
+func compileTemplate(src, field, structField string, lenient bool) (*template.Template, []*domain.TemplateWarning, error) {
+	tmpl, err := parseTemplate(src, field, structField)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unknown := tmpl.UnknownFields()
+	if len(unknown) == 0 {
+		return tmpl, nil, nil
+	}
+
+	if !lenient {
+		errs := make([]validator.FieldError, 0, len(unknown))
+		for _, ref := range unknown {
+			errs = append(errs, &fieldError{
+				field:       field,
+				structField: structField,
+				tag:         "placeholder",
+				param:       unknownPlaceholder(ref),
+				value:       ref.Raw,
+			})
+		}
+
+		return nil, nil, validationError(errs...)
+	}
+
+	warnings := make([]*domain.TemplateWarning, 0, len(unknown))
+	for _, ref := range unknown {
+		warnings = append(warnings, &domain.TemplateWarning{
+			Placeholder: ref.Raw,
+			Offset:      ref.Offset,
+			Suggestion:  template.Suggest(ref.Name),
+		})
+	}
+
+	return tmpl, warnings, nil
+}
+
+func unknownPlaceholder(ref template.Ref) string {
+	msg := fmt.Sprintf("unknown placeholder %s at offset %d", ref.Raw, ref.Offset)
+	if suggestion := template.Suggest(ref.Name); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %s?", suggestion)
+	}
+
+	return msg
+}
+
+// renderedMessage is a campaign template rendered for one customer. SMS is set
+// for campaigns delivered over SMS.
+type renderedMessage struct {
+	Content string
+	SMS     *domain.SMSInfo
+}
+
+// segments is the number of billable units the message takes: SMS segments, or
+// one for other channels.
+func (m *renderedMessage) segments() int {
+	if m.SMS != nil {
+		return m.SMS.Segments
+	}
+
+	return 1
+}
+
+// exceeds reports whether the message is longer than the campaign allows.
+func (m *renderedMessage) exceeds(campaign *repository.GetCampaignRow) bool {
+	return m.SMS != nil && campaign.MaxSegments.Valid && int32(m.SMS.Segments) > campaign.MaxSegments.Int32
+}
+
+// render renders a campaign's template for a customer and, for SMS campaigns,
+// works out its encoding.
+func render(campaign *repository.GetCampaignRow, tmpl *template.Template, customer *repository.Customer) *renderedMessage {
+	msg := renderedMessage{Content: tmpl.Render(customerContext(customer))}
+	if campaign.Channel == "sms" {
+		msg.SMS = sms.Analyze(msg.Content)
+	}
+
+	return &msg
+}
+
+// renderMessage renders a message that is about to be shown or sent, enforcing
+// the campaign's segment cap.
+func renderMessage(campaign *repository.GetCampaignRow, tmpl *template.Template, customer *repository.Customer) (*renderedMessage, error) {
+	msg := render(campaign, tmpl, customer)
+	if msg.exceeds(campaign) {
+		return nil, errors.WrapError(
+			fmt.Errorf(
+				"message for customer %d needs %d %s segments, campaign allows %d",
+				customer.ID, msg.SMS.Segments, msg.SMS.Encoding, campaign.MaxSegments.Int32,
+			),
+			errors.FailedPrecondition,
+			"MESSAGE_EXCEEDS_MAX_SEGMENTS",
+		)
+	}
+
+	return msg, nil
+}
+
+func customerContext(c *repository.Customer) *template.Customer {
+	return &template.Customer{
+		ID:               c.ID,
+		Phone:            c.Phone,
+		FirstName:        c.FirstName.String,
+		LastName:         c.LastName.String,
+		Location:         c.Location.String,
+		PreferredProduct: c.PreferredProduct.String,
+		CreatedAt:        c.CreatedAt.Time,
+		UpdatedAt:        c.UpdatedAt.Time,
+	}
+}
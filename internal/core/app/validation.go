@@ -1,12 +1,75 @@
 package app
 
 import (
+	"fmt"
+	"reflect"
+	"regexp"
 	"time"
 
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	"github.com/mwinyimoha/commons/pkg/errors"
 )
 
 func validTimestamp(fl validator.FieldLevel) bool {
 	_, err := time.Parse(time.RFC3339, fl.Field().String())
 	return err == nil
 }
+
+// validScheduledAt also accepts times without an offset, which are read in
+// the campaign's timezone.
+func validScheduledAt(fl validator.FieldLevel) bool {
+	_, err := parseScheduledAt(fl.Field().String(), defaultTimezone)
+	return err == nil
+}
+
+// validCron accepts standard five-field cron expressions and descriptors such
+// as @weekly, as long as they fire at some point.
+func validCron(fl validator.FieldLevel) bool {
+	_, err := nextOccurrence(fl.Field().String(), "UTC", time.Now())
+	return err == nil
+}
+
+// phonePrefix matches the start of an E.164 number: a "+" and up to 15 digits.
+var phonePrefix = regexp.MustCompile(`^\+[0-9]{1,15}$`)
+
+// validPhonePrefix keeps segment prefixes to digits, so they never carry
+// characters with a meaning of their own in the customer match.
+func validPhonePrefix(fl validator.FieldLevel) bool {
+	return phonePrefix.MatchString(fl.Field().String())
+}
+
+// fieldError reports a failure found outside the validator, such as a template
+// syntax error, in the same shape as a validator.FieldError so it can be turned
+// into violations like any other invalid input.
+type fieldError struct {
+	field       string
+	structField string
+	tag         string
+	param       string
+	value       string
+}
+
+func (fe *fieldError) Tag() string             { return fe.tag }
+func (fe *fieldError) ActualTag() string       { return fe.tag }
+func (fe *fieldError) Namespace() string       { return fe.field }
+func (fe *fieldError) StructNamespace() string { return fe.structField }
+func (fe *fieldError) Field() string           { return fe.field }
+func (fe *fieldError) StructField() string     { return fe.structField }
+func (fe *fieldError) Value() interface{}      { return fe.value }
+func (fe *fieldError) Param() string           { return fe.param }
+func (fe *fieldError) Kind() reflect.Kind      { return reflect.String }
+func (fe *fieldError) Type() reflect.Type      { return reflect.TypeOf(fe.value) }
+
+func (fe *fieldError) Translate(ut.Translator) string { return fe.Error() }
+
+func (fe *fieldError) Error() string {
+	return fmt.Sprintf("Key: '%s' Error:Field validation for '%s' failed on the '%s' tag: %s", fe.structField, fe.field, fe.tag, fe.param)
+}
+
+// validationError builds the same error the service returns for payloads that
+// fail struct validation.
+func validationError(errs ...validator.FieldError) error {
+	violations := errors.BuildViolations(validator.ValidationErrors(errs))
+	return errors.NewValidationError(violations, "INVALID_REQUEST_DATA")
+}
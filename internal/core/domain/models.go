@@ -1,10 +1,51 @@
 package domain
 
+import "time"
+
 type CreateCampaign struct {
 	Name         string `json:"name" validate:"required"`
 	Channel      string `json:"channel" validate:"required,oneof=sms whatsapp"`
 	BaseTemplate string `json:"base_template" validate:"required"`
-	ScheduledAt  string `json:"scheduled_at" validate:"omitempty,valid_timestamp"`
+
+	// ScheduledAt is an RFC 3339 time, or a wall-clock time without an offset
+	// that is read in Timezone.
+	ScheduledAt string `json:"scheduled_at" validate:"omitempty,valid_scheduled_at"`
+
+	// LenientTemplates accepts templates with unknown placeholders, reporting
+	// them as warnings instead of rejecting the campaign.
+	LenientTemplates bool `json:"lenient_templates"`
+
+	// MaxSegments caps the SMS segments a single rendered message may take.
+	MaxSegments int32 `json:"max_segments" validate:"omitempty,min=1"`
+
+	// The audience, a segment or a list of customers, is what the scheduler
+	// sends the campaign to once ScheduledAt comes. A scheduled campaign needs
+	// one; a draft may leave it for SendCampaign.
+	SegmentID   int64   `json:"segment_id" validate:"omitempty,min=1"`
+	CustomerIds []int64 `json:"customer_ids" validate:"excluded_with=SegmentID,omitempty,min=1"`
+
+	// Recurrence repeats the campaign on a cron schedule read in Timezone,
+	// UTC by default. Every occurrence starts a run of its own, and
+	// ScheduledAt, when given, is when the first one may start.
+	Recurrence string `json:"recurrence" validate:"omitempty,valid_cron"`
+	Timezone   string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+// UpdateCampaign changes only the fields it carries. An empty ScheduledAt
+// takes the campaign off its schedule and a MaxSegments of 0 lifts the cap.
+// A segment or a list of customers replaces the campaign's audience, and an
+// empty Recurrence stops the campaign from recurring. The channel is fixed
+// once a campaign exists.
+type UpdateCampaign struct {
+	Name             *string `json:"name" validate:"omitempty,min=1"`
+	BaseTemplate     *string `json:"base_template" validate:"omitempty,min=1"`
+	ScheduledAt      *string `json:"scheduled_at"`
+	LenientTemplates *bool   `json:"lenient_templates"`
+	MaxSegments      *int32  `json:"max_segments" validate:"omitempty,min=0"`
+	SegmentID        *int64  `json:"segment_id" validate:"omitempty,min=1"`
+	CustomerIds      []int64 `json:"customer_ids" validate:"excluded_with=SegmentID,omitempty,min=1"`
+	Recurrence       *string `json:"recurrence"`
+	Timezone         *string `json:"timezone" validate:"omitempty,timezone"`
 }
 
 type CampaignsFilter struct {
@@ -12,14 +53,144 @@ type CampaignsFilter struct {
 	Channel string
 }
 
+// CampaignCursor marks a position in the campaign listing, which runs newest
+// first. A forward cursor asks for the campaigns after it, i.e. older ones; a
+// backward cursor for the ones before it.
+type CampaignCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int64     `json:"id"`
+	Backward  bool      `json:"backward,omitempty"`
+}
+
+// CampaignCursors are the positions of the pages around a page of campaigns.
+// TotalCount is only set when it was asked for.
+type CampaignCursors struct {
+	Next       *CampaignCursor
+	Prev       *CampaignCursor
+	TotalCount *int64
+}
+
+type MessagesFilter struct {
+	Statuses      []string `json:"status" validate:"dive,oneof=pending sending sent delivered undelivered read failed cancelled"`
+	CustomerID    int64    `json:"customer_id"`
+	CreatedAfter  string   `json:"created_after" validate:"omitempty,valid_timestamp"`
+	CreatedBefore string   `json:"created_before" validate:"omitempty,valid_timestamp"`
+	Error         string   `json:"error"`
+}
+
+const (
+	SendFailFast   = "fail_fast"
+	SendBestEffort = "best_effort"
+)
+
 type SendCampaign struct {
-	CustomerIds []int64 `json:"customer_ids" validate:"required,min=1"`
+	CustomerIds []int64 `json:"customer_ids" validate:"required_without=SegmentID,excluded_with=SegmentID,omitempty,min=1"`
+	SegmentID   int64   `json:"segment_id" validate:"required_without=CustomerIds"`
+
+	// Mode decides what happens when a customer cannot be queued. fail_fast,
+	// the default, stops the send at the first such customer; best_effort
+	// records the outcome and carries on with the rest of the audience.
+	Mode string `json:"mode" validate:"omitempty,oneof=fail_fast best_effort"`
+
+	// Resend queues the campaign again for customers who already have a
+	// message for it. Without it they are skipped; with it the reason is
+	// recorded on the dispatch and on every repeated message.
+	Resend       bool   `json:"resend"`
+	ResendReason string `json:"resend_reason" validate:"required_if=Resend true,max=500"`
+}
+
+// CustomerOutcome is what a send did for one customer of its audience.
+type CustomerOutcome struct {
+	CustomerID int64  `json:"customer_id"`
+	Outcome    string `json:"outcome"`
+	Reason     string `json:"reason,omitempty"`
 }
 
+// SendCampaignResult reports the progress of a campaign dispatch. Outcomes
+// lists the customers that were skipped or failed so that a client can retry
+// only those; OutcomesTruncated is set when there are more than were returned.
 type SendCampaignResult struct {
-	CampaignID     int64  `json:"campaign_id"`
-	MessagesQueued int32  `json:"messages_queued"`
-	Status         string `json:"status"`
+	DispatchID        int64              `json:"dispatch_id"`
+	CampaignID        int64              `json:"campaign_id"`
+	Status            string             `json:"status"`
+	Mode              string             `json:"mode"`
+	TotalCustomers    int32              `json:"total_customers"`
+	Queued            int32              `json:"queued"`
+	Skipped           int32              `json:"skipped"`
+	Failed            int32              `json:"failed"`
+	LastError         string             `json:"last_error,omitempty"`
+	Outcomes          []*CustomerOutcome `json:"outcomes"`
+	OutcomesTruncated bool               `json:"outcomes_truncated"`
+}
+
+// Partial reports whether a finished dispatch left part of its audience
+// unqueued.
+func (r *SendCampaignResult) Partial() bool {
+	if r.Status != DispatchCompleted && r.Status != DispatchFailed {
+		return false
+	}
+
+	return r.Skipped > 0 || r.Failed > 0 || r.Status == DispatchFailed
+}
+
+// CampaignEstimate is the projected volume and cost of sending a campaign to an
+// audience. When Sampled is set the totals are extrapolated from SampleSize
+// rendered messages and CostMargin is the half-width of a 95% confidence
+// interval around EstimatedCost.
+type CampaignEstimate struct {
+	CampaignID           int64   `json:"campaign_id"`
+	Channel              string  `json:"channel"`
+	AudienceSize         int64   `json:"audience_size"`
+	TotalMessages        int64   `json:"total_messages"`
+	TotalSegments        int64   `json:"total_segments"`
+	EstimatedCost        float64 `json:"estimated_cost"`
+	Currency             string  `json:"currency"`
+	Sampled              bool    `json:"sampled"`
+	SampleSize           int     `json:"sample_size"`
+	CostMargin           float64 `json:"cost_margin"`
+	ExceedingMaxSegments int64   `json:"exceeding_max_segments"`
+	UnpricedMessages     int64   `json:"unpriced_messages"`
+}
+
+type CreateCustomer struct {
+	Phone            string `json:"phone" validate:"required,e164"`
+	FirstName        string `json:"first_name" validate:"max=100"`
+	LastName         string `json:"last_name" validate:"max=100"`
+	Location         string `json:"location" validate:"max=255"`
+	PreferredProduct string `json:"preferred_product" validate:"max=255"`
+}
+
+type UpdateCustomer CreateCustomer
+
+type CustomersFilter struct {
+	Location         string
+	PreferredProduct string
+	Phone            string
+}
+
+type CreateCustomerImport struct {
+	Format  string            `json:"format" validate:"required,oneof=csv ndjson"`
+	Mapping map[string]string `json:"mapping" validate:"dive,keys,oneof=phone first_name last_name location preferred_product,endkeys,required"`
+
+	// IdempotencyKey, when set, makes a retried upload return the import
+	// the first one created.
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+type CreateSegment struct {
+	Name             string `json:"name" validate:"required"`
+	Location         string `json:"location"`
+	PreferredProduct string `json:"preferred_product"`
+	PhonePrefix      string `json:"phone_prefix" validate:"omitempty,valid_phone_prefix"`
+	CreatedAfter     string `json:"created_after" validate:"omitempty,valid_timestamp"`
+	CreatedBefore    string `json:"created_before" validate:"omitempty,valid_timestamp"`
+}
+
+type UpdateSegment CreateSegment
+
+type SegmentCount struct {
+	SegmentID int64 `json:"segment_id"`
+	Customers int64 `json:"customers"`
 }
 
 type PreviewMessage struct {
@@ -33,7 +204,46 @@ type MinimalCustomer struct {
 }
 
 type PreviewResponse struct {
-	Message  string           `json:"rendered_message"`
-	Template string           `json:"used_template"`
-	Customer *MinimalCustomer `json:"customer"`
+	Message  string             `json:"rendered_message"`
+	Template string             `json:"used_template"`
+	Customer *MinimalCustomer   `json:"customer"`
+	Warnings []*TemplateWarning `json:"warnings,omitempty"`
+	SMS      *SMSInfo           `json:"sms,omitempty"`
+}
+
+// SMSInfo describes how a message is encoded over SMS. Units are septets for
+// GSM-7 and UTF-16 code units for UCS-2; Offending lists the characters that
+// forced UCS-2.
+type SMSInfo struct {
+	Encoding  string   `json:"encoding"`
+	Units     int      `json:"units"`
+	Segments  int      `json:"segments"`
+	Offending []string `json:"offending_characters,omitempty"`
+}
+
+// TemplateWarning describes a placeholder naming an unknown customer field.
+type TemplateWarning struct {
+	Placeholder string `json:"placeholder"`
+	Offset      int    `json:"offset"`
+	Suggestion  string `json:"suggestion,omitempty"`
+}
+
+type Message struct {
+	ID        int64  `json:"id"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient"`
+	Content   string `json:"content"`
+}
+
+type DeliveryResult struct {
+	ProviderMessageID string `json:"provider_message_id"`
+}
+
+// DeliveryReceipt is a gateway's report on a message it accepted earlier,
+// normalised across providers. MessageID is the provider's own message ID.
+type DeliveryReceipt struct {
+	MessageID  string     `json:"message_id" validate:"required,max=255"`
+	Status     string     `json:"status" validate:"required,oneof=delivered undelivered read"`
+	Reason     string     `json:"reason" validate:"max=1000"`
+	OccurredAt *time.Time `json:"occurred_at"`
 }
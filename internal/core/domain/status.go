@@ -0,0 +1,115 @@
+package domain
+
+import "errors"
+
+const (
+	CampaignDraft     = "draft"
+	CampaignScheduled = "scheduled"
+	CampaignSending   = "sending"
+	CampaignPaused    = "paused"
+	CampaignSent      = "sent"
+	CampaignFailed    = "failed"
+	CampaignCancelled = "cancelled"
+	CampaignArchived  = "archived"
+)
+
+const (
+	MessagePending     = "pending"
+	MessageSending     = "sending"
+	MessageSent        = "sent"
+	MessageDelivered   = "delivered"
+	MessageUndelivered = "undelivered"
+	MessageRead        = "read"
+	MessageFailed      = "failed"
+	MessageCancelled   = "cancelled"
+)
+
+const (
+	MessageEventQueued           = "queued"
+	MessageEventStatusChanged    = "status_changed"
+	MessageEventAttempt          = "attempt"
+	MessageEventProviderResponse = "provider_response"
+	MessageEventReceipt          = "receipt"
+	MessageEventDeferred         = "deferred"
+)
+
+const (
+	ImportPending    = "pending"
+	ImportProcessing = "processing"
+	ImportCompleted  = "completed"
+	ImportFailed     = "failed"
+)
+
+const (
+	DispatchPending   = "pending"
+	DispatchRunning   = "running"
+	DispatchCompleted = "completed"
+	DispatchFailed    = "failed"
+)
+
+const (
+	OutcomeQueued             = "queued"
+	OutcomeSkippedNotFound    = "skipped_not_found"
+	OutcomeSkippedDuplicate   = "skipped_duplicate"
+	OutcomeSkippedAlreadySent = "skipped_already_sent"
+	OutcomeFailed             = "failed"
+)
+
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// campaignTransitions lists, for every target status, the statuses a campaign
+// may move from. A campaign that is already sending may receive further sends;
+// a sent campaign is final. Scheduled and sending campaigns can be paused or
+// cancelled, and any campaign that is at rest can be archived. Ending the
+// recurrence of a scheduled or paused campaign returns it to draft. Resuming a
+// paused campaign returns it to scheduled or sending, depending on whether its
+// time has come, and is not listed here.
+var campaignTransitions = map[string][]string{
+	CampaignDraft:     {CampaignScheduled, CampaignPaused},
+	CampaignSending:   {CampaignDraft, CampaignScheduled, CampaignSending, CampaignFailed},
+	CampaignPaused:    {CampaignScheduled, CampaignSending},
+	CampaignSent:      {CampaignSending},
+	CampaignFailed:    {CampaignSending},
+	CampaignCancelled: {CampaignScheduled, CampaignSending, CampaignPaused},
+	CampaignArchived:  {CampaignDraft, CampaignScheduled, CampaignSent, CampaignFailed, CampaignCancelled},
+}
+
+// campaignEditable lists the statuses in which a campaign's content and
+// schedule may still change.
+var campaignEditable = []string{CampaignDraft, CampaignScheduled}
+
+// messageTransitions lists, for every target status, the statuses an outbound
+// message may move from. Anything not listed here is refused so that duplicate
+// task deliveries can never move a sent message backwards. Once sent, a message
+// only moves forward on delivery receipts; a read receipt may overtake the
+// delivery receipt it implies. Only the task that holds a message may claim
+// it again from sending or failed, after dying mid-delivery, or put it back
+// from sending to pending while it has retries left.
+var messageTransitions = map[string][]string{
+	MessageSending:     {MessagePending, MessageSending, MessageFailed},
+	MessageSent:        {MessageSending},
+	MessageFailed:      {MessagePending, MessageSending},
+	MessagePending:     {MessageSending, MessageFailed},
+	MessageDelivered:   {MessageSent},
+	MessageUndelivered: {MessageSent},
+	MessageRead:        {MessageSent, MessageDelivered},
+	MessageCancelled:   {MessagePending},
+}
+
+// MessageTransitionSources returns the statuses from which an outbound message
+// may legally move to the given status.
+func MessageTransitionSources(to string) []string {
+	return messageTransitions[to]
+}
+
+// CampaignTransitionSources returns the statuses from which a campaign may
+// legally move to the given status.
+func CampaignTransitionSources(to string) []string {
+	return campaignTransitions[to]
+}
+
+// CampaignEditableStatuses returns the statuses in which a campaign may be
+// edited.
+func CampaignEditableStatuses() []string {
+	return campaignEditable
+}
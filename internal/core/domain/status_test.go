@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageTransitionSources(t *testing.T) {
+	tests := []struct {
+		to       string
+		expected []string
+	}{
+		{to: MessageSending, expected: []string{MessagePending, MessageSending, MessageFailed}},
+		{to: MessageSent, expected: []string{MessageSending}},
+		{to: MessageFailed, expected: []string{MessagePending, MessageSending}},
+		{to: MessagePending, expected: []string{MessageSending, MessageFailed}},
+		{to: MessageDelivered, expected: []string{MessageSent}},
+		{to: MessageUndelivered, expected: []string{MessageSent}},
+		{to: MessageRead, expected: []string{MessageSent, MessageDelivered}},
+		{to: MessageCancelled, expected: []string{MessagePending}},
+		{to: "unknown", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MessageTransitionSources(tt.to))
+		})
+	}
+}
+
+func TestMessageTransitionSources_SentOnlyMovesOnReceipts(t *testing.T) {
+	receipts := []string{MessageDelivered, MessageUndelivered, MessageRead}
+	for to := range messageTransitions {
+		if slices.Contains(receipts, to) {
+			continue
+		}
+		assert.NotContains(t, MessageTransitionSources(to), MessageSent, "sent must not move to %s", to)
+	}
+}
+
+func TestCampaignTransitionSources(t *testing.T) {
+	tests := []struct {
+		to       string
+		expected []string
+	}{
+		{to: CampaignDraft, expected: []string{CampaignScheduled, CampaignPaused}},
+		{to: CampaignSending, expected: []string{CampaignDraft, CampaignScheduled, CampaignSending, CampaignFailed}},
+		{to: CampaignPaused, expected: []string{CampaignScheduled, CampaignSending}},
+		{to: CampaignSent, expected: []string{CampaignSending}},
+		{to: CampaignFailed, expected: []string{CampaignSending}},
+		{to: CampaignCancelled, expected: []string{CampaignScheduled, CampaignSending, CampaignPaused}},
+		{to: CampaignArchived, expected: []string{CampaignDraft, CampaignScheduled, CampaignSent, CampaignFailed, CampaignCancelled}},
+		{to: CampaignScheduled, expected: nil},
+		{to: "unknown", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CampaignTransitionSources(tt.to))
+		})
+	}
+}
+
+func TestCampaignTransitionSources_SentIsFinal(t *testing.T) {
+	for to := range campaignTransitions {
+		if to == CampaignArchived {
+			continue
+		}
+		assert.NotContains(t, CampaignTransitionSources(to), CampaignSent, "sent must not move to %s", to)
+	}
+}
+
+func TestCampaignTransitionSources_HeldCampaignsCannotBeSent(t *testing.T) {
+	sources := CampaignTransitionSources(CampaignSending)
+	assert.NotContains(t, sources, CampaignPaused)
+	assert.NotContains(t, sources, CampaignCancelled)
+	assert.NotContains(t, CampaignTransitionSources(CampaignArchived), CampaignPaused)
+}
+
+func TestSendCampaignResult_Partial(t *testing.T) {
+	running := &SendCampaignResult{Status: DispatchRunning, Skipped: 1}
+	assert.False(t, running.Partial())
+
+	clean := &SendCampaignResult{Status: DispatchCompleted, Queued: 3}
+	assert.False(t, clean.Partial())
+
+	skipped := &SendCampaignResult{Status: DispatchCompleted, Queued: 2, Skipped: 1}
+	assert.True(t, skipped.Partial())
+
+	stopped := &SendCampaignResult{Status: DispatchFailed}
+	assert.True(t, stopped.Partial())
+}
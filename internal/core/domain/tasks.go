@@ -1,7 +1,27 @@
 package domain
 
-const SendMessageTask = "task:send_message"
+import "fmt"
+
+const (
+	SendMessageTask      = "task:send_message"
+	ImportCustomersTask  = "task:import_customers"
+	DispatchCampaignTask = "task:dispatch_campaign"
+)
 
 type SendMessage struct {
 	MessageID int64 `json:"message_id"`
 }
+
+type ImportCustomers struct {
+	ImportID int64 `json:"import_id"`
+}
+
+type DispatchCampaign struct {
+	DispatchID int64 `json:"dispatch_id"`
+}
+
+// OutboxTaskID is the asynq task ID under which the relay publishes an outbox
+// entry. Messages remember it so that tasks they no longer expect are refused.
+func OutboxTaskID(entryID int64) string {
+	return fmt.Sprintf("outbox:%d", entryID)
+}
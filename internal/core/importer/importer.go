@@ -0,0 +1,210 @@
+// Package importer loads customer lists uploaded as CSV or NDJSON files into
+// the customers table, recording every row it has to reject.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"focus-dev-challenge/internal/adapters/repository"
+	"focus-dev-challenge/internal/core/domain"
+	"focus-dev-challenge/internal/core/ports"
+	"io"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// batchSize is the number of rows upserted per transaction.
+const batchSize = 1000
+
+type Importer struct {
+	repository ports.AppRepository
+	validator  *validator.Validate
+}
+
+func NewImporter(repo ports.AppRepository, val *validator.Validate) *Importer {
+	return &Importer{
+		repository: repo,
+		validator:  val,
+	}
+}
+
+// Run processes a staged import from the beginning. Earlier progress is reset
+// first, and customers are upserted on phone, so running the same import twice
+// leaves the same result.
+func (im *Importer) Run(ctx context.Context, importID int64) (*repository.CustomerImport, error) {
+	record, err := im.repository.StartCustomerImport(ctx, importID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := im.process(ctx, record); err != nil {
+		// Record the failure even when ctx was what stopped the import.
+		_, _ = im.repository.FinishCustomerImport(context.WithoutCancel(ctx), &repository.FinishCustomerImportParams{
+			Status:    domain.ImportFailed,
+			LastError: pgtype.Text{String: err.Error(), Valid: true},
+			ImportID:  importID,
+		})
+
+		return nil, err
+	}
+
+	return im.repository.FinishCustomerImport(ctx, &repository.FinishCustomerImportParams{
+		Status:   domain.ImportCompleted,
+		ImportID: importID,
+	})
+}
+
+func (im *Importer) process(ctx context.Context, record *repository.CustomerImport) error {
+	mapping := map[string]string{}
+	if len(record.ColumnMapping) > 0 {
+		if err := json.Unmarshal(record.ColumnMapping, &mapping); err != nil {
+			return fmt.Errorf("invalid column mapping: %w", err)
+		}
+	}
+
+	source := im.repository.OpenCustomerImportSource(ctx, record.ID)
+	reader, err := newRowReader(record.Format, source, mapping)
+	if err != nil {
+		return err
+	}
+
+	b := newBatch(record.ID)
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		b.add(row, im.validate(row))
+		if b.processed >= batchSize {
+			if err := im.flush(ctx, b); err != nil {
+				return err
+			}
+			b = newBatch(record.ID)
+		}
+	}
+
+	return im.flush(ctx, b)
+}
+
+// validate returns the reason a row must be rejected, or an empty string.
+func (im *Importer) validate(row *Row) string {
+	if row.Err != nil {
+		return row.Err.Error()
+	}
+
+	customer := domain.CreateCustomer{
+		Phone:            row.Values["phone"],
+		FirstName:        row.Values["first_name"],
+		LastName:         row.Values["last_name"],
+		Location:         row.Values["location"],
+		PreferredProduct: row.Values["preferred_product"],
+	}
+	if err := im.validator.Struct(&customer); err != nil {
+		verr, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err.Error()
+		}
+
+		return describe(verr[0])
+	}
+
+	return ""
+}
+
+func (im *Importer) flush(ctx context.Context, b *batch) error {
+	if b.processed == 0 {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := im.repository.SaveCustomerImportBatch(ctx, b.customers(), &b.rejections, &repository.RecordCustomerImportProgressParams{
+		Processed: int32(b.processed),
+		Imported:  int32(len(b.order)),
+		Rejected:  int32(len(b.rejections.RowNumbers)),
+		ImportID:  b.importID,
+	})
+	return err
+}
+
+var fieldColumns = map[string]string{
+	"Phone":            "phone",
+	"FirstName":        "first_name",
+	"LastName":         "last_name",
+	"Location":         "location",
+	"PreferredProduct": "preferred_product",
+}
+
+func describe(fe validator.FieldError) string {
+	column := fieldColumns[fe.Field()]
+
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", column)
+	case "e164":
+		return fmt.Sprintf("%s is not a valid E.164 phone number", column)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", column, fe.Param())
+	default:
+		return fmt.Sprintf("%s failed %s validation", column, fe.Tag())
+	}
+}
+
+// batch accumulates accepted and rejected rows between flushes. Rows sharing a
+// phone number are collapsed so that the last occurrence wins, since a single
+// upsert statement cannot touch the same row twice.
+type batch struct {
+	importID   int64
+	processed  int
+	rows       map[string]map[string]string
+	order      []string
+	rejections repository.CreateCustomerImportRejectionsParams
+}
+
+func newBatch(importID int64) *batch {
+	return &batch{
+		importID:   importID,
+		rows:       make(map[string]map[string]string),
+		rejections: repository.CreateCustomerImportRejectionsParams{ImportID: importID},
+	}
+}
+
+func (b *batch) add(row *Row, reason string) {
+	b.processed++
+
+	if reason != "" {
+		b.rejections.RowNumbers = append(b.rejections.RowNumbers, int32(row.Line))
+		b.rejections.Raws = append(b.rejections.Raws, row.Raw)
+		b.rejections.Reasons = append(b.rejections.Reasons, reason)
+		return
+	}
+
+	phone := row.Values["phone"]
+	if _, seen := b.rows[phone]; !seen {
+		b.order = append(b.order, phone)
+	}
+	b.rows[phone] = row.Values
+}
+
+func (b *batch) customers() *repository.UpsertCustomersParams {
+	params := repository.UpsertCustomersParams{}
+	for _, phone := range b.order {
+		values := b.rows[phone]
+		params.Phones = append(params.Phones, phone)
+		params.FirstNames = append(params.FirstNames, values["first_name"])
+		params.LastNames = append(params.LastNames, values["last_name"])
+		params.Locations = append(params.Locations, values["location"])
+		params.PreferredProducts = append(params.PreferredProducts, values["preferred_product"])
+	}
+
+	return &params
+}
@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImporter_Validate(t *testing.T) {
+	im := NewImporter(nil, validator.New())
+
+	assert.Equal(t, "", im.validate(&Row{Values: map[string]string{"phone": "+254700111222"}}))
+	assert.Equal(t, "phone is required", im.validate(&Row{Values: map[string]string{"first_name": "Alice"}}))
+	assert.Equal(t, "phone is not a valid E.164 phone number", im.validate(&Row{Values: map[string]string{"phone": "0700111222"}}))
+	assert.Equal(t, "first_name must be at most 100 characters", im.validate(&Row{Values: map[string]string{
+		"phone":      "+254700111222",
+		"first_name": string(make([]byte, 101)),
+	}}))
+}
+
+func TestBatch_LastDuplicatePhoneWins(t *testing.T) {
+	b := newBatch(9)
+	b.add(&Row{Line: 2, Values: map[string]string{"phone": "+254700111222", "first_name": "Alice"}}, "")
+	b.add(&Row{Line: 3, Raw: "bad", Values: map[string]string{"phone": "bad"}}, "phone is not a valid E.164 phone number")
+	b.add(&Row{Line: 4, Values: map[string]string{"phone": "+254700333444", "first_name": "Bob"}}, "")
+	b.add(&Row{Line: 5, Values: map[string]string{"phone": "+254700111222", "first_name": "Alicia"}}, "")
+
+	assert.Equal(t, 4, b.processed)
+
+	params := b.customers()
+	assert.Equal(t, []string{"+254700111222", "+254700333444"}, params.Phones)
+	assert.Equal(t, []string{"Alicia", "Bob"}, params.FirstNames)
+
+	assert.Equal(t, int64(9), b.rejections.ImportID)
+	assert.Equal(t, []int32{3}, b.rejections.RowNumbers)
+	assert.Equal(t, []string{"bad"}, b.rejections.Raws)
+}
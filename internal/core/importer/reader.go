@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Fields lists the customer columns an import can populate.
+var Fields = []string{"phone", "first_name", "last_name", "location", "preferred_product"}
+
+// Row is a single record read from an import file. Values is keyed by customer
+// field name; Err is set when the record itself could not be parsed.
+type Row struct {
+	Line   int
+	Raw    string
+	Values map[string]string
+	Err    error
+}
+
+type rowReader interface {
+	// Next returns the next row, or io.EOF once the source is exhausted.
+	Next() (*Row, error)
+}
+
+// FormatFromFilename infers the import format from a file extension.
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	default:
+		return ""
+	}
+}
+
+func newRowReader(format string, r io.Reader, mapping map[string]string) (rowReader, error) {
+	columns := make(map[string]string, len(Fields))
+	for _, field := range Fields {
+		columns[field] = field
+		if col, ok := mapping[field]; ok && col != "" {
+			columns[field] = col
+		}
+	}
+
+	switch format {
+	case "csv":
+		return newCSVReader(r, columns)
+	case "ndjson":
+		return newNDJSONReader(r, columns), nil
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	indexes map[string]int
+}
+
+func newCSVReader(r io.Reader, columns map[string]string) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("csv file is empty")
+		}
+
+		return nil, fmt.Errorf("could not read csv header: %w", err)
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	indexes := make(map[string]int, len(columns))
+	for field, col := range columns {
+		if i, ok := positions[strings.ToLower(col)]; ok {
+			indexes[field] = i
+		}
+	}
+
+	if _, ok := indexes["phone"]; !ok {
+		return nil, fmt.Errorf("csv header has no column %q for phone", columns["phone"])
+	}
+
+	return &csvReader{reader: reader, indexes: indexes}, nil
+}
+
+func (cr *csvReader) Next() (*Row, error) {
+	record, err := cr.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	if err != nil {
+		if perr, ok := err.(*csv.ParseError); ok {
+			return &Row{Line: perr.Line, Err: perr.Err}, nil
+		}
+
+		return nil, err
+	}
+
+	line, _ := cr.reader.FieldPos(0)
+	row := Row{
+		Line:   line,
+		Raw:    joinCSV(record),
+		Values: make(map[string]string, len(cr.indexes)),
+	}
+	for field, i := range cr.indexes {
+		if i < len(record) {
+			row.Values[field] = strings.TrimSpace(record[i])
+		}
+	}
+
+	return &row, nil
+}
+
+func joinCSV(record []string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(record)
+	w.Flush()
+
+	return strings.TrimRight(buf.String(), "\r\n")
+}
+
+type ndjsonReader struct {
+	reader  *bufio.Reader
+	columns map[string]string
+	line    int
+}
+
+func newNDJSONReader(r io.Reader, columns map[string]string) *ndjsonReader {
+	return &ndjsonReader{
+		reader:  bufio.NewReader(r),
+		columns: columns,
+	}
+}
+
+func (nr *ndjsonReader) Next() (*Row, error) {
+	for {
+		data, err := nr.reader.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return nil, err
+		}
+
+		nr.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		row := Row{Line: nr.line, Raw: string(data)}
+
+		var record map[string]any
+		if uerr := json.Unmarshal(data, &record); uerr != nil {
+			row.Err = fmt.Errorf("invalid json: %w", uerr)
+			return &row, nil
+		}
+
+		row.Values = make(map[string]string, len(nr.columns))
+		for field, key := range nr.columns {
+			if v, ok := record[key]; ok {
+				row.Values[field] = strings.TrimSpace(stringify(v))
+			}
+		}
+
+		return &row, nil
+	}
+}
+
+func stringify(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
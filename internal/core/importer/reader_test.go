@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, r rowReader) []*Row {
+	t.Helper()
+
+	var rows []*Row
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			return rows
+		}
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	assert.Equal(t, "csv", FormatFromFilename("partners.CSV"))
+	assert.Equal(t, "ndjson", FormatFromFilename("export.ndjson"))
+	assert.Equal(t, "ndjson", FormatFromFilename("export.jsonl"))
+	assert.Equal(t, "", FormatFromFilename("export.xlsx"))
+}
+
+func TestCSVReader_MapsColumns(t *testing.T) {
+	data := "\ufeffMobile,First Name,city\n+254700111222,Alice,Nairobi\n\"+254700333444\",Bob,\"Mombasa, Coast\"\n"
+	mapping := map[string]string{"phone": "mobile", "first_name": "first name", "location": "City"}
+
+	r, err := newRowReader("csv", strings.NewReader(data), mapping)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	rows := readAll(t, r)
+	if !assert.Len(t, rows, 2) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, "+254700111222", rows[0].Values["phone"])
+	assert.Equal(t, "Alice", rows[0].Values["first_name"])
+	assert.Equal(t, "Nairobi", rows[0].Values["location"])
+	assert.NotContains(t, rows[0].Values, "last_name")
+
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Equal(t, "Mombasa, Coast", rows[1].Values["location"])
+	assert.Equal(t, `+254700333444,Bob,"Mombasa, Coast"`, rows[1].Raw)
+}
+
+func TestCSVReader_MissingPhoneColumn(t *testing.T) {
+	_, err := newRowReader("csv", strings.NewReader("name,city\nAlice,Nairobi\n"), nil)
+	assert.ErrorContains(t, err, "phone")
+
+	_, err = newRowReader("csv", strings.NewReader(""), nil)
+	assert.ErrorContains(t, err, "empty")
+}
+
+func TestCSVReader_MalformedRow(t *testing.T) {
+	data := "phone,first_name\n+254700111222,\"Alice\n"
+
+	r, err := newRowReader("csv", strings.NewReader(data), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	rows := readAll(t, r)
+	if assert.Len(t, rows, 1) {
+		assert.Error(t, rows[0].Err)
+		assert.Equal(t, 2, rows[0].Line)
+	}
+}
+
+func TestNDJSONReader(t *testing.T) {
+	data := `{"msisdn": "+254700111222", "first_name": "Alice"}
+
+{"msisdn": 254700333444, "location": null}
+not json
+`
+	r, err := newRowReader("ndjson", strings.NewReader(data), map[string]string{"phone": "msisdn"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	rows := readAll(t, r)
+	if !assert.Len(t, rows, 3) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, 1, rows[0].Line)
+	assert.Equal(t, "+254700111222", rows[0].Values["phone"])
+	assert.Equal(t, "Alice", rows[0].Values["first_name"])
+
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Equal(t, "254700333444", rows[1].Values["phone"])
+	assert.Equal(t, "", rows[1].Values["location"])
+
+	assert.Equal(t, 4, rows[2].Line)
+	assert.Error(t, rows[2].Err)
+	assert.Equal(t, "not json", rows[2].Raw)
+}
+
+func TestNewRowReader_UnsupportedFormat(t *testing.T) {
+	_, err := newRowReader("xlsx", strings.NewReader(""), nil)
+	assert.Error(t, err)
+}
@@ -3,17 +3,74 @@ package ports
 import (
 	"context"
 	"focus-dev-challenge/internal/adapters/repository"
+	"io"
 )
 
 type AppRepository interface {
 	Close() error
 	ExecTx(ctx context.Context, fn func(*repository.Queries) error) error
 
-	AddCampaign(arg *repository.CreateCampaignParams) (*repository.Campaign, error)
-	ListCampaigns(arg *repository.ListCampaignsParams) ([]*repository.ListCampaignsRow, error)
-	GetCampaign(ID int64) (*repository.GetCampaignRow, error)
+	AddCampaign(ctx context.Context, arg *repository.CreateCampaignParams) (*repository.Campaign, error)
+	ListCampaigns(ctx context.Context, arg *repository.ListCampaignsParams) ([]*repository.ListCampaignsRow, error)
+	ListCampaignsAfter(ctx context.Context, arg *repository.ListCampaignsAfterParams) ([]*repository.Campaign, error)
+	ListCampaignsBefore(ctx context.Context, arg *repository.ListCampaignsBeforeParams) ([]*repository.Campaign, error)
+	CountCampaigns(ctx context.Context, arg *repository.CountCampaignsParams) (int64, error)
+	GetCampaign(ctx context.Context, ID int64) (*repository.GetCampaignRow, error)
+	GetCampaignStatus(ctx context.Context, ID int64) (string, error)
+	TransitionCampaign(ctx context.Context, arg *repository.TransitionCampaignParams) (*repository.Campaign, error)
+	FinalizeCampaign(ctx context.Context, ID int64) (*repository.Campaign, error)
+	StartScheduledCampaign(ctx context.Context, arg *repository.StartScheduledCampaignParams) (*repository.Campaign, error)
+	AdvanceCampaignRecurrence(ctx context.Context, arg *repository.AdvanceCampaignRecurrenceParams) (*repository.Campaign, error)
+	EndCampaignRecurrence(ctx context.Context, arg *repository.EndCampaignRecurrenceParams) (*repository.Campaign, error)
+	ListCampaignRuns(ctx context.Context, arg *repository.ListCampaignRunsParams) ([]*repository.ListCampaignRunsRow, error)
 
-	GetCustomer(ID int64) (*repository.Customer, error)
+	GetCampaignDispatch(ctx context.Context, ID int64) (*repository.CampaignDispatch, error)
+	StartCampaignDispatch(ctx context.Context, ID int64) (*repository.CampaignDispatch, error)
+	FinishCampaignDispatch(ctx context.Context, arg *repository.FinishCampaignDispatchParams) (*repository.CampaignDispatch, error)
+	ListCampaignDispatchOutcomes(ctx context.Context, arg *repository.ListCampaignDispatchOutcomesParams) ([]*repository.ListCampaignDispatchOutcomesRow, error)
 
-	CreateOutboundMessage(arg *repository.CreateOutboundMessageParams) (*repository.OutboundMessage, error)
+	GetCustomer(ctx context.Context, ID int64) (*repository.Customer, error)
+	AddCustomer(ctx context.Context, arg *repository.CreateCustomerParams) (*repository.Customer, error)
+	ListCustomers(ctx context.Context, arg *repository.ListCustomersParams) ([]*repository.ListCustomersRow, error)
+	UpdateCustomer(ctx context.Context, arg *repository.UpdateCustomerParams) (*repository.Customer, error)
+	DeleteCustomer(ctx context.Context, ID int64) error
+	ListExistingCustomerIds(ctx context.Context, IDs []int64) ([]int64, error)
+	ListCustomersByIds(ctx context.Context, arg *repository.ListCustomersByIdsParams) ([]*repository.Customer, error)
+	ListMessagedCustomerIds(ctx context.Context, arg *repository.ListMessagedCustomerIdsParams) ([]int64, error)
+
+	GetCustomerImport(ctx context.Context, ID int64) (*repository.CustomerImport, error)
+	GetCustomerImportKey(ctx context.Context, key string) (*repository.CustomerImportKey, error)
+	OpenCustomerImportSource(ctx context.Context, ID int64) io.Reader
+	StartCustomerImport(ctx context.Context, ID int64) (*repository.CustomerImport, error)
+	SaveCustomerImportBatch(
+		ctx context.Context,
+		customers *repository.UpsertCustomersParams,
+		rejections *repository.CreateCustomerImportRejectionsParams,
+		progress *repository.RecordCustomerImportProgressParams,
+	) (*repository.CustomerImport, error)
+	FinishCustomerImport(ctx context.Context, arg *repository.FinishCustomerImportParams) (*repository.CustomerImport, error)
+	ListCustomerImportRejections(ctx context.Context, ID int64) ([]*repository.CustomerImportRejection, error)
+
+	AddSegment(ctx context.Context, arg *repository.CreateSegmentParams) (*repository.Segment, error)
+	ListSegments(ctx context.Context, arg *repository.ListSegmentsParams) ([]*repository.ListSegmentsRow, error)
+	GetSegment(ctx context.Context, ID int64) (*repository.Segment, error)
+	UpdateSegment(ctx context.Context, arg *repository.UpdateSegmentParams) (*repository.Segment, error)
+	DeleteSegment(ctx context.Context, ID int64) error
+	CountSegmentCustomers(ctx context.Context, ID int64) (int64, error)
+	ListSegmentCustomers(ctx context.Context, arg *repository.ListSegmentCustomersParams) ([]*repository.Customer, error)
+	SampleSegmentCustomers(ctx context.Context, arg *repository.SampleSegmentCustomersParams) ([]*repository.Customer, error)
+
+	CreateOutboundMessage(ctx context.Context, arg *repository.CreateOutboundMessageParams) (*repository.OutboundMessage, error)
+	GetOutboundMessage(ctx context.Context, ID int64) (*repository.OutboundMessage, error)
+	GetOutboundMessageByProviderID(ctx context.Context, arg *repository.GetOutboundMessageByProviderIDParams) (*repository.OutboundMessage, error)
+	GetOutboundMessageDetail(ctx context.Context, ID int64) (*repository.GetOutboundMessageDetailRow, error)
+	GetOutboundMessageForDelivery(ctx context.Context, ID int64) (*repository.GetOutboundMessageForDeliveryRow, error)
+	ListCampaignMessages(ctx context.Context, arg *repository.ListCampaignMessagesParams) ([]*repository.ListCampaignMessagesRow, error)
+	TransitionOutboundMessage(ctx context.Context, arg *repository.TransitionOutboundMessageParams) (*repository.OutboundMessage, error)
+	SetOutboundMessageTaskIds(ctx context.Context, arg *repository.SetOutboundMessageTaskIdsParams) error
+
+	CreateMessageEvent(ctx context.Context, arg *repository.CreateMessageEventParams) (*repository.MessageEvent, error)
+	ListMessageEvents(ctx context.Context, messageID int64) ([]*repository.MessageEvent, error)
+
+	DispatchOutbox(ctx context.Context, batchSize int32, publish func(*repository.Outbox) error) (int, error)
 }
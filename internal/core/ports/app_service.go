@@ -1,14 +1,54 @@
 package ports
 
 import (
+	"context"
 	"focus-dev-challenge/internal/adapters/repository"
 	"focus-dev-challenge/internal/core/domain"
+	"io"
+	"time"
 )
 
 type AppService interface {
-	AddCampaign(payload *domain.CreateCampaign) (*repository.Campaign, error)
-	ListCampaigns(pageNumber, pageSize int, filters *domain.CampaignsFilter) ([]*repository.ListCampaignsRow, error)
-	RetrieveCampaign(campaignID int64) (*repository.GetCampaignRow, error)
-	PreviewMessage(campaignID int64, payload *domain.PreviewMessage) (*domain.PreviewResponse, error)
-	SendCampaign(campaignID int64, payload *domain.SendCampaign) (*domain.SendCampaignResult, error)
+	AddCampaign(ctx context.Context, payload *domain.CreateCampaign) (*repository.Campaign, []*domain.TemplateWarning, error)
+	ListCampaigns(ctx context.Context, pageNumber, pageSize int, filters *domain.CampaignsFilter) ([]*repository.ListCampaignsRow, error)
+	ListCampaignsByCursor(ctx context.Context, pageSize int, filters *domain.CampaignsFilter, cursor *domain.CampaignCursor, withTotal bool) ([]*repository.Campaign, *domain.CampaignCursors, error)
+	RetrieveCampaign(ctx context.Context, campaignID int64) (*repository.GetCampaignRow, error)
+	UpdateCampaign(ctx context.Context, campaignID int64, payload *domain.UpdateCampaign, version *time.Time) (*repository.Campaign, []*domain.TemplateWarning, error)
+	CloneCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	ArchiveCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	CancelCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	PauseCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	ResumeCampaign(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	ListCampaignRuns(ctx context.Context, campaignID int64, pageNumber, pageSize int) ([]*repository.ListCampaignRunsRow, error)
+	SkipCampaignOccurrence(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	EndCampaignRecurrence(ctx context.Context, campaignID int64) (*repository.Campaign, error)
+	PreviewMessage(ctx context.Context, campaignID int64, payload *domain.PreviewMessage) (*domain.PreviewResponse, error)
+	SendCampaign(ctx context.Context, campaignID int64, payload *domain.SendCampaign) (*domain.SendCampaignResult, error)
+	RetrieveCampaignDispatch(ctx context.Context, campaignID, dispatchID int64) (*domain.SendCampaignResult, error)
+	ListDispatchOutcomes(ctx context.Context, campaignID, dispatchID int64, pageNumber, pageSize int, outcomes []string) ([]*repository.ListCampaignDispatchOutcomesRow, error)
+	EstimateCampaign(ctx context.Context, campaignID int64, payload *domain.SendCampaign) (*domain.CampaignEstimate, error)
+	ListCampaignMessages(ctx context.Context, campaignID int64, filter *domain.MessagesFilter, afterID int64, limit int) ([]*repository.ListCampaignMessagesRow, error)
+	ExportCampaignMessages(ctx context.Context, campaignID int64, filter *domain.MessagesFilter, write func([]*repository.ListCampaignMessagesRow) error) error
+
+	AddCustomer(ctx context.Context, payload *domain.CreateCustomer) (*repository.Customer, error)
+	ListCustomers(ctx context.Context, pageNumber, pageSize int, filters *domain.CustomersFilter) ([]*repository.ListCustomersRow, error)
+	RetrieveCustomer(ctx context.Context, customerID int64) (*repository.Customer, error)
+	UpdateCustomer(ctx context.Context, customerID int64, payload *domain.UpdateCustomer) (*repository.Customer, error)
+	DeleteCustomer(ctx context.Context, customerID int64) error
+
+	ImportCustomers(ctx context.Context, payload *domain.CreateCustomerImport, file io.Reader) (*repository.CustomerImport, bool, error)
+	RetrieveCustomerImport(ctx context.Context, importID int64) (*repository.CustomerImport, error)
+	CustomerImportReport(ctx context.Context, importID int64) ([]*repository.CustomerImportRejection, error)
+
+	AddSegment(ctx context.Context, payload *domain.CreateSegment) (*repository.Segment, error)
+	ListSegments(ctx context.Context, pageNumber, pageSize int) ([]*repository.ListSegmentsRow, error)
+	RetrieveSegment(ctx context.Context, segmentID int64) (*repository.Segment, error)
+	UpdateSegment(ctx context.Context, segmentID int64, payload *domain.UpdateSegment) (*repository.Segment, error)
+	DeleteSegment(ctx context.Context, segmentID int64) error
+	CountSegment(ctx context.Context, segmentID int64) (*domain.SegmentCount, error)
+
+	RetrieveMessage(ctx context.Context, messageID int64) (*repository.GetOutboundMessageDetailRow, error)
+	ListMessageEvents(ctx context.Context, messageID int64) ([]*repository.MessageEvent, error)
+	RetryMessage(ctx context.Context, messageID int64) (*repository.OutboundMessage, error)
+	RecordDeliveryReceipt(ctx context.Context, channel string, receipt *domain.DeliveryReceipt) (*repository.OutboundMessage, error)
 }
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+	"time"
+)
+
+type CampaignDispatcher interface {
+	RunDispatch(ctx context.Context, dispatchID int64) (*repository.CampaignDispatch, error)
+	DeferMessage(ctx context.Context, messageID int64, taskID string, until time.Time) (bool, error)
+}
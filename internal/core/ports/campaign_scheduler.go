@@ -0,0 +1,7 @@
+package ports
+
+import "context"
+
+type CampaignScheduler interface {
+	DispatchDueCampaigns(ctx context.Context, limit int32) (int, error)
+}
@@ -0,0 +1,10 @@
+package ports
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+)
+
+type CustomerImporter interface {
+	Run(ctx context.Context, importID int64) (*repository.CustomerImport, error)
+}
@@ -0,0 +1,13 @@
+package ports
+
+import (
+	"context"
+	"focus-dev-challenge/internal/adapters/repository"
+)
+
+type IdempotencyStore interface {
+	ClaimIdempotencyKey(ctx context.Context, arg *repository.ClaimIdempotencyKeyParams) (*repository.IdempotencyKey, error)
+	GetIdempotencyKey(ctx context.Context, arg *repository.GetIdempotencyKeyParams) (*repository.IdempotencyKey, error)
+	SaveIdempotentResponse(ctx context.Context, arg *repository.SaveIdempotentResponseParams) error
+	ReleaseIdempotencyKey(ctx context.Context, ID int64) error
+}
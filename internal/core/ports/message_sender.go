@@ -0,0 +1,10 @@
+package ports
+
+import (
+	"context"
+	"focus-dev-challenge/internal/core/domain"
+)
+
+type MessageSender interface {
+	Send(ctx context.Context, msg *domain.Message) (*domain.DeliveryResult, error)
+}
@@ -0,0 +1,123 @@
+// Package sms works out how a message will be encoded and split into segments
+// when it is delivered over SMS.
+package sms
+
+import (
+	"focus-dev-challenge/internal/core/domain"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	GSM7 = "GSM-7"
+	UCS2 = "UCS-2"
+)
+
+// Segment capacities. A message that fits in a single segment may use the full
+// capacity; longer messages lose room to the concatenation header.
+const (
+	gsm7Single = 160
+	gsm7Multi  = 153
+	ucs2Single = 70
+	ucs2Multi  = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, excluding the escape character.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension holds characters sent as an escape sequence, costing two septets.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// Analyze returns the encoding and segment count for text. Text containing any
+// character outside the GSM-7 alphabet is sent as UCS-2; the characters that
+// forced this are listed in Offending, once each.
+func Analyze(text string) *domain.SMSInfo {
+	var offending []string
+	seen := map[rune]bool{}
+	for _, r := range text {
+		if septets(r) == 0 && !seen[r] {
+			seen[r] = true
+			offending = append(offending, string(r))
+		}
+	}
+
+	if len(offending) > 0 {
+		units, segments := countUCS2(text)
+		return &domain.SMSInfo{Encoding: UCS2, Units: units, Segments: segments, Offending: offending}
+	}
+
+	units, segments := countGSM7(text)
+	return &domain.SMSInfo{Encoding: GSM7, Units: units, Segments: segments}
+}
+
+// septets returns the GSM-7 cost of r, or 0 if r cannot be encoded.
+func septets(r rune) int {
+	switch {
+	case strings.ContainsRune(gsm7Basic, r):
+		return 1
+	case strings.ContainsRune(gsm7Extension, r):
+		return 2
+	default:
+		return 0
+	}
+}
+
+// countGSM7 counts septets and segments. Escape sequences are never split
+// across segments, so a multi-part message can need more segments than its
+// length alone suggests.
+func countGSM7(text string) (int, int) {
+	units := 0
+	for _, r := range text {
+		units += septets(r)
+	}
+
+	if units == 0 {
+		return 0, 0
+	}
+
+	if units <= gsm7Single {
+		return units, 1
+	}
+
+	segments, used := 1, 0
+	for _, r := range text {
+		n := septets(r)
+		if used+n > gsm7Multi {
+			segments++
+			used = 0
+		}
+		used += n
+	}
+
+	return units, segments
+}
+
+// countUCS2 counts UTF-16 code units and segments, keeping surrogate pairs in
+// one segment.
+func countUCS2(text string) (int, int) {
+	units := 0
+	for _, r := range text {
+		units += utf16.RuneLen(r)
+	}
+
+	if units == 0 {
+		return 0, 0
+	}
+
+	if units <= ucs2Single {
+		return units, 1
+	}
+
+	segments, used := 1, 0
+	for _, r := range text {
+		n := utf16.RuneLen(r)
+		if used+n > ucs2Multi {
+			segments++
+			used = 0
+		}
+		used += n
+	}
+
+	return units, segments
+}
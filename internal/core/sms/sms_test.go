@@ -0,0 +1,65 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		encoding  string
+		units     int
+		segments  int
+		offending []string
+	}{
+		{"empty", "", GSM7, 0, 0, nil},
+		{"plain ascii", "Hi Alice, your order is ready", GSM7, 29, 1, nil},
+		{"gsm accents", "Café £5 @ Zürich", GSM7, 16, 1, nil},
+		{"extension characters count twice", "Save 10€ [today]", GSM7, 19, 1, nil},
+		{"single segment limit", strings.Repeat("a", 160), GSM7, 160, 1, nil},
+		{"two segments", strings.Repeat("a", 161), GSM7, 161, 2, nil},
+		{"three segments", strings.Repeat("a", 307), GSM7, 307, 3, nil},
+		{"em dash forces ucs2", "Festive Sale is live — 25% off", UCS2, 30, 1, []string{"—"}},
+		{"offending listed once", "“Hi” “there”", UCS2, 12, 1, []string{"“", "”"}},
+		{"ucs2 single segment limit", strings.Repeat("ж", 70), UCS2, 70, 1, []string{"ж"}},
+		{"ucs2 two segments", strings.Repeat("ж", 71), UCS2, 71, 2, []string{"ж"}},
+		{"emoji uses surrogate pair", "Hi 👋", UCS2, 5, 1, []string{"👋"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Analyze(tt.text)
+			assert.Equal(t, tt.encoding, info.Encoding)
+			assert.Equal(t, tt.units, info.Units)
+			assert.Equal(t, tt.segments, info.Segments)
+			assert.Equal(t, tt.offending, info.Offending)
+		})
+	}
+}
+
+func TestAnalyze_EscapeNotSplitAcrossSegments(t *testing.T) {
+	// The escape after 152 septets moves to the second segment, which then
+	// overflows even though 306 septets would fit in two segments.
+	text := strings.Repeat("a", 152) + "€" + strings.Repeat("a", 10)
+
+	info := Analyze(text)
+	assert.Equal(t, 164, info.Units)
+	assert.Equal(t, 2, info.Segments)
+
+	text = strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152)
+	info = Analyze(text)
+	assert.Equal(t, 306, info.Units)
+	assert.Equal(t, 3, info.Segments)
+}
+
+func TestAnalyze_SurrogatePairNotSplit(t *testing.T) {
+	text := strings.Repeat("ж", 66) + "👋" + strings.Repeat("ж", 66)
+
+	info := Analyze(text)
+	assert.Equal(t, 134, info.Units)
+	assert.Equal(t, 3, info.Segments)
+}
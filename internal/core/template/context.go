@@ -0,0 +1,117 @@
+package template
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Customer is the data a campaign template is rendered against. Templates refer
+// to its fields by name, e.g. {FirstName}.
+type Customer struct {
+	ID               int64
+	Phone            string
+	FirstName        string
+	LastName         string
+	Location         string
+	PreferredProduct string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type field int
+
+const (
+	fieldUnknown field = iota
+	fieldID
+	fieldPhone
+	fieldFirstName
+	fieldLastName
+	fieldLocation
+	fieldPreferredProduct
+	fieldCreatedAt
+	fieldUpdatedAt
+)
+
+var fieldNames = map[string]field{
+	"ID":               fieldID,
+	"Phone":            fieldPhone,
+	"FirstName":        fieldFirstName,
+	"LastName":         fieldLastName,
+	"Location":         fieldLocation,
+	"PreferredProduct": fieldPreferredProduct,
+	"CreatedAt":        fieldCreatedAt,
+	"UpdatedAt":        fieldUpdatedAt,
+}
+
+// FieldNames lists the placeholders a template may reference.
+func FieldNames() []string {
+	return []string{"ID", "Phone", "FirstName", "LastName", "Location", "PreferredProduct", "CreatedAt", "UpdatedAt"}
+}
+
+// Suggest returns the field name that differs from name only in case, or an
+// empty string if there is none.
+func Suggest(name string) string {
+	for _, candidate := range FieldNames() {
+		if strings.EqualFold(candidate, name) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+func (f field) isTime() bool {
+	return f == fieldCreatedAt || f == fieldUpdatedAt
+}
+
+// value is the result of evaluating a placeholder. Timestamps are kept as time
+// values until a filter or the final write formats them.
+type value struct {
+	str    string
+	time   time.Time
+	isTime bool
+}
+
+func (v value) empty() bool {
+	if v.isTime {
+		return v.time.IsZero()
+	}
+
+	return v.str == ""
+}
+
+func (v value) String() string {
+	if v.isTime {
+		if v.time.IsZero() {
+			return ""
+		}
+
+		return v.time.Format(time.RFC3339)
+	}
+
+	return v.str
+}
+
+func (c *Customer) lookup(f field) value {
+	switch f {
+	case fieldID:
+		return value{str: strconv.FormatInt(c.ID, 10)}
+	case fieldPhone:
+		return value{str: c.Phone}
+	case fieldFirstName:
+		return value{str: c.FirstName}
+	case fieldLastName:
+		return value{str: c.LastName}
+	case fieldLocation:
+		return value{str: c.Location}
+	case fieldPreferredProduct:
+		return value{str: c.PreferredProduct}
+	case fieldCreatedAt:
+		return value{time: c.CreatedAt, isTime: true}
+	case fieldUpdatedAt:
+		return value{time: c.UpdatedAt, isTime: true}
+	default:
+		return value{}
+	}
+}
@@ -0,0 +1,110 @@
+package template
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// dateLayouts maps the named formats accepted by the date filter to Go time
+// layouts. Any other argument is used as a layout directly.
+var dateLayouts = map[string]string{
+	"short": "02 Jan 2006",
+	"long":  "Monday, 02 January 2006",
+	"iso":   "2006-01-02",
+	"time":  "15:04",
+}
+
+type filter struct {
+	name string
+	arg  string
+	n    int
+}
+
+var filterNames = map[string]bool{
+	"upper":    true,
+	"lower":    true,
+	"title":    true,
+	"truncate": true,
+	"date":     true,
+}
+
+func newFilter(name, arg string, hasArg bool, src field) (*filter, error) {
+	f := filter{name: name, arg: arg}
+
+	switch name {
+	case "upper", "lower", "title":
+		if hasArg {
+			return nil, fmt.Errorf("filter %q takes no argument", name)
+		}
+	case "truncate":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("filter \"truncate\" needs a positive length, e.g. truncate:20")
+		}
+		f.n = n
+	case "date":
+		if !src.isTime() {
+			return nil, fmt.Errorf("filter \"date\" only applies to CreatedAt and UpdatedAt")
+		}
+		if !hasArg {
+			f.arg = "short"
+		}
+		if layout, ok := dateLayouts[f.arg]; ok {
+			f.arg = layout
+		}
+	}
+
+	return &f, nil
+}
+
+func (f *filter) apply(v value) value {
+	if f.name == "date" {
+		if v.isTime && !v.time.IsZero() {
+			return value{str: v.time.Format(f.arg)}
+		}
+
+		return value{str: v.String()}
+	}
+
+	s := v.String()
+	switch f.name {
+	case "upper":
+		s = strings.ToUpper(s)
+	case "lower":
+		s = strings.ToLower(s)
+	case "title":
+		s = titleCase(s)
+	case "truncate":
+		if utf8.RuneCountInString(s) > f.n {
+			s = string([]rune(s)[:f.n])
+		}
+	}
+
+	return value{str: s}
+}
+
+func titleCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	start := true
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '-' {
+			start = true
+			b.WriteRune(r)
+			continue
+		}
+
+		if start {
+			b.WriteRune(unicode.ToUpper(r))
+		} else {
+			b.WriteRune(unicode.ToLower(r))
+		}
+		start = false
+	}
+
+	return b.String()
+}
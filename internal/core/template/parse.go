@@ -0,0 +1,266 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	identPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	filterPattern = regexp.MustCompile(`^[a-z]+(:|$)`)
+)
+
+// Error describes a syntax error in a template. Offset is the byte offset into
+// the source; Line and Column are 1-based and count runes.
+type Error struct {
+	Offset int
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Parse compiles a template, returning an *Error for the first syntax error.
+func Parse(src string) (*Template, error) {
+	p := parser{src: src}
+	nodes, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{source: src, nodes: nodes, unknown: p.unknown}, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(src string) *Template {
+	t, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+type parser struct {
+	src     string
+	unknown []Ref
+}
+
+func (p *parser) reference(name string, f field, start, end int) {
+	if f == fieldUnknown {
+		p.unknown = append(p.unknown, Ref{Name: name, Raw: p.src[start : end+1], Offset: start})
+	}
+}
+
+type block struct {
+	node      *ifNode
+	offset    int
+	inElse    bool
+	container *[]node
+}
+
+func (p *parser) parse() ([]node, error) {
+	var root []node
+	out := &root
+	var stack []*block
+
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			*out = append(*out, textNode(text.String()))
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(p.src); {
+		c := p.src[i]
+		switch {
+		case c == '{' && strings.HasPrefix(p.src[i:], "{{"):
+			text.WriteByte('{')
+			i += 2
+		case c == '}' && strings.HasPrefix(p.src[i:], "}}"):
+			text.WriteByte('}')
+			i += 2
+		case c == '{':
+			end := closingBrace(p.src, i+1)
+			if end < 0 {
+				return nil, p.errorf(i, "unclosed placeholder, expected }")
+			}
+
+			flush()
+
+			body := p.src[i+1 : end]
+			offset := i + 1 + len(body) - len(strings.TrimLeft(body, " \t"))
+			body = strings.TrimSpace(body)
+			words := strings.Fields(body)
+
+			switch {
+			case body == "":
+				return nil, p.errorf(i, "empty placeholder")
+			case words[0] == "if":
+				if len(words) != 2 || !identPattern.MatchString(words[1]) {
+					return nil, p.errorf(offset, "expected {if FieldName}")
+				}
+
+				n := &ifNode{field: fieldNames[words[1]]}
+				p.reference(words[1], n.field, i, end)
+				*out = append(*out, n)
+				stack = append(stack, &block{node: n, offset: i, container: out})
+				out = &n.then
+			case body == "else":
+				if len(stack) == 0 {
+					return nil, p.errorf(i, "{else} without a matching {if}")
+				}
+
+				top := stack[len(stack)-1]
+				if top.inElse {
+					return nil, p.errorf(i, "duplicate {else} in {if} block")
+				}
+				top.inElse = true
+				out = &top.node.otherwise
+			case body == "end":
+				if len(stack) == 0 {
+					return nil, p.errorf(i, "{end} without a matching {if}")
+				}
+
+				out = stack[len(stack)-1].container
+				stack = stack[:len(stack)-1]
+			default:
+				n, err := p.placeholder(body, offset)
+				if err != nil {
+					return nil, err
+				}
+
+				n.raw = p.src[i : end+1]
+				p.reference(n.name, n.field, i, end)
+				*out = append(*out, n)
+			}
+
+			i = end + 1
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, p.errorf(stack[len(stack)-1].offset, "unclosed {if} block, expected {end}")
+	}
+
+	flush()
+	return root, nil
+}
+
+// placeholder parses "Field|segment|..." where each segment is either a filter
+// or a default value.
+func (p *parser) placeholder(body string, offset int) (*placeholderNode, error) {
+	segments, offsets := splitPipes(body)
+
+	name := strings.TrimSpace(segments[0])
+	if !identPattern.MatchString(name) {
+		return nil, p.errorf(offset, fmt.Sprintf("invalid field name %q", name))
+	}
+
+	n := placeholderNode{name: name, field: fieldNames[name]}
+	for k, seg := range segments[1:] {
+		segOffset := offset + offsets[k+1]
+		seg = strings.TrimSpace(seg)
+
+		if strings.HasPrefix(seg, `"`) {
+			s, err := strconv.Unquote(seg)
+			if err != nil {
+				return nil, p.errorf(segOffset, "invalid quoted default")
+			}
+
+			n.pipeline = append(n.pipeline, defaultStep(s))
+			continue
+		}
+
+		fname, arg, hasArg := strings.Cut(seg, ":")
+		if filterNames[fname] {
+			f, err := newFilter(fname, arg, hasArg, n.field)
+			if err != nil {
+				return nil, p.errorf(segOffset, err.Error())
+			}
+
+			n.pipeline = append(n.pipeline, f)
+			continue
+		}
+
+		if hasArg && filterPattern.MatchString(seg) {
+			return nil, p.errorf(segOffset, fmt.Sprintf("unknown filter %q", fname))
+		}
+
+		n.pipeline = append(n.pipeline, defaultStep(seg))
+	}
+
+	return &n, nil
+}
+
+func (p *parser) errorf(offset int, msg string) *Error {
+	before := p.src[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+
+	return &Error{Offset: offset, Line: line, Column: col, Msg: msg}
+}
+
+// closingBrace returns the index of the } ending a placeholder that starts at
+// from, skipping braces inside double-quoted strings, or -1 if there is none.
+func closingBrace(src string, from int) int {
+	quoted := false
+	for i := from; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '{':
+			if !quoted {
+				return -1
+			}
+		case '}':
+			if !quoted {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// splitPipes splits a placeholder body on | outside double quotes, returning
+// each segment with its byte offset into body.
+func splitPipes(body string) ([]string, []int) {
+	segments := []string{}
+	offsets := []int{0}
+
+	quoted := false
+	start := 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '|':
+			if !quoted {
+				segments = append(segments, body[start:i])
+				start = i + 1
+				offsets = append(offsets, start)
+			}
+		}
+	}
+
+	return append(segments, body[start:]), offsets
+}
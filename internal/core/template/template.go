@@ -0,0 +1,126 @@
+// Package template implements the placeholder language used by campaign
+// templates. A template is parsed once into a tree and can then be rendered
+// for any number of customers.
+//
+// Syntax:
+//
+//	{FirstName}                      field value
+//	{FirstName|Guest}                default when the field is empty
+//	{FirstName|"Dear customer"}      quoted default, may contain | or }
+//	{Location|upper}                 filters: upper, lower, title, truncate:N, date[:layout]
+//	{FirstName|Guest|upper}          defaults and filters apply left to right
+//	{if FirstName}Hi {FirstName}{else}Hi there{end}
+//	{{ and }}                        literal braces
+//
+// The date filter accepts the named formats short, long, iso and time, or a Go
+// time layout. Placeholders naming an unknown field are written out verbatim
+// and {if} tags naming one take the {else} branch; UnknownFields lists them.
+package template
+
+import (
+	"strings"
+)
+
+type Template struct {
+	source  string
+	nodes   []node
+	unknown []Ref
+}
+
+// Ref points at a placeholder or {if} tag in the template source. Offset is the
+// byte offset of its opening brace.
+type Ref struct {
+	Name   string
+	Raw    string
+	Offset int
+}
+
+// UnknownFields returns every placeholder and {if} tag naming a field that is
+// not available to templates, in source order.
+func (t *Template) UnknownFields() []Ref {
+	return t.unknown
+}
+
+// Source returns the text the template was parsed from.
+func (t *Template) Source() string {
+	return t.source
+}
+
+// Render executes the template against a customer.
+func (t *Template) Render(c *Customer) string {
+	if c == nil {
+		c = &Customer{}
+	}
+
+	var b strings.Builder
+	b.Grow(len(t.source))
+	renderNodes(&b, t.nodes, c)
+
+	return b.String()
+}
+
+func renderNodes(b *strings.Builder, nodes []node, c *Customer) {
+	for _, n := range nodes {
+		n.render(b, c)
+	}
+}
+
+type node interface {
+	render(b *strings.Builder, c *Customer)
+}
+
+type textNode string
+
+func (n textNode) render(b *strings.Builder, _ *Customer) {
+	b.WriteString(string(n))
+}
+
+type step interface {
+	apply(v value) value
+}
+
+type defaultStep string
+
+func (d defaultStep) apply(v value) value {
+	if v.empty() {
+		return value{str: string(d)}
+	}
+
+	return v
+}
+
+type placeholderNode struct {
+	raw      string
+	name     string
+	field    field
+	pipeline []step
+}
+
+func (n *placeholderNode) render(b *strings.Builder, c *Customer) {
+	if n.field == fieldUnknown {
+		b.WriteString(n.raw)
+		return
+	}
+
+	v := c.lookup(n.field)
+	for _, s := range n.pipeline {
+		v = s.apply(v)
+	}
+
+	b.WriteString(v.String())
+}
+
+type ifNode struct {
+	field     field
+	then      []node
+	otherwise []node
+}
+
+func (n *ifNode) render(b *strings.Builder, c *Customer) {
+	if n.field != fieldUnknown && !c.lookup(n.field).empty() {
+		renderNodes(b, n.then, c)
+		return
+	}
+
+	renderNodes(b, n.otherwise, c)
+}
@@ -0,0 +1,175 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func render(t *testing.T, src string, c *Customer) string {
+	t.Helper()
+
+	tmpl, err := Parse(src)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return tmpl.Render(c)
+}
+
+func TestRender_Substitution(t *testing.T) {
+	ts := time.Date(2024, 12, 4, 15, 30, 0, 0, time.UTC)
+	customer := &Customer{
+		ID:               7,
+		FirstName:        "Mohammed",
+		Location:         "Mombasa",
+		PreferredProduct: "White Sneakers",
+		Phone:            "+254712832088",
+		CreatedAt:        ts,
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"single field", "Hi {FirstName}", "Hi Mohammed"},
+		{"multiple fields", "{FirstName} from {Location}", "Mohammed from Mombasa"},
+		{"numeric field", "Customer #{ID}", "Customer #7"},
+		{"timestamp field", "Joined {CreatedAt}", "Joined " + ts.Format(time.RFC3339)},
+		{"empty field", "Name: {FirstName} {LastName}.", "Name: Mohammed ."},
+		{"unknown field left verbatim", "Visit {NonExistent|x}", "Visit {NonExistent|x}"},
+		{"no placeholders", "Just plain text", "Just plain text"},
+		{"empty template", "", ""},
+		{"escaped braces", "{{FirstName}} is {FirstName}", "{FirstName} is Mohammed"},
+		{"stray closing brace", "smile :}", "smile :}"},
+		{"whitespace inside braces", "Hi { FirstName }", "Hi Mohammed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, render(t, tt.template, customer))
+		})
+	}
+}
+
+func TestRender_Defaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		customer *Customer
+		expected string
+	}{
+		{"default unused", "Hi {FirstName|Guest}", &Customer{FirstName: "Ann"}, "Hi Ann"},
+		{"default used", "Hi {FirstName|Guest}", &Customer{}, "Hi Guest"},
+		{"quoted default", `Hi {FirstName|"valued | customer"}`, &Customer{}, "Hi valued | customer"},
+		{"default then filter", "Hi {FirstName|guest|title}", &Customer{}, "Hi Guest"},
+		{"timestamp default", "Since {CreatedAt|date:iso|always}", &Customer{}, "Since always"},
+		{"nil customer", "Hi {FirstName|there}", nil, "Hi there"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, render(t, tt.template, tt.customer))
+		})
+	}
+}
+
+func TestRender_Filters(t *testing.T) {
+	customer := &Customer{
+		FirstName:        "mARY ann",
+		PreferredProduct: "White Sneakers",
+		CreatedAt:        time.Date(2024, 3, 5, 9, 7, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"upper", "{PreferredProduct|upper}", "WHITE SNEAKERS"},
+		{"lower", "{PreferredProduct|lower}", "white sneakers"},
+		{"title", "{FirstName|title}", "Mary Ann"},
+		{"truncate", "{PreferredProduct|truncate:5}", "White"},
+		{"truncate longer than value", "{PreferredProduct|truncate:50}", "White Sneakers"},
+		{"date default format", "{CreatedAt|date}", "05 Mar 2024"},
+		{"date named format", "{CreatedAt|date:long}", "Tuesday, 05 March 2024"},
+		{"date go layout", "{CreatedAt|date:2006/01/02 15:04}", "2024/03/05 09:07"},
+		{"chained", "{PreferredProduct|truncate:5|upper}", "WHITE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, render(t, tt.template, customer))
+		})
+	}
+}
+
+func TestRender_Conditionals(t *testing.T) {
+	src := "{if FirstName}Hi {FirstName}{else}Hello{end}! {if Location}See you in {Location}.{end}"
+
+	assert.Equal(t, "Hi Ann! See you in Nairobi.", render(t, src, &Customer{FirstName: "Ann", Location: "Nairobi"}))
+	assert.Equal(t, "Hello! ", render(t, src, &Customer{}))
+
+	nested := "{if FirstName}{if LastName}{FirstName} {LastName}{else}{FirstName}{end}{else}friend{end}"
+	assert.Equal(t, "Ann Njeri", render(t, nested, &Customer{FirstName: "Ann", LastName: "Njeri"}))
+	assert.Equal(t, "Ann", render(t, nested, &Customer{FirstName: "Ann"}))
+	assert.Equal(t, "friend", render(t, nested, &Customer{LastName: "Njeri"}))
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		offset   int
+		line     int
+		column   int
+		contains string
+	}{
+		{"unclosed placeholder", "Hi {FirstName", 3, 1, 4, "unclosed placeholder"},
+		{"empty placeholder", "Hi {}", 3, 1, 4, "empty placeholder"},
+		{"invalid field name", "Hi {First Name}", 4, 1, 5, "invalid field name"},
+		{"unknown filter", "Hi {FirstName|shout:2}", 14, 1, 15, "unknown filter"},
+		{"bad truncate", "{FirstName|truncate:x}", 11, 1, 12, "positive length"},
+		{"date on text field", "{FirstName|date:iso}", 11, 1, 12, "only applies"},
+		{"filter argument", "{FirstName|upper:1}", 11, 1, 12, "takes no argument"},
+		{"unclosed if", "Hi\n{if FirstName}there", 3, 2, 1, "unclosed {if}"},
+		{"else without if", "Hi{else}", 2, 1, 3, "{else} without"},
+		{"end without if", "Hi{end}", 2, 1, 3, "{end} without"},
+		{"duplicate else", "{if FirstName}a{else}b{else}c{end}", 22, 1, 23, "duplicate {else}"},
+		{"if without field", "{if}x{end}", 1, 1, 2, "expected {if FieldName}"},
+		{"column counts runes", "Héllo — {FirstName", 11, 1, 9, "unclosed placeholder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.template)
+
+			var terr *Error
+			if !assert.ErrorAs(t, err, &terr) {
+				return
+			}
+			assert.Equal(t, tt.offset, terr.Offset)
+			assert.Equal(t, tt.line, terr.Line)
+			assert.Equal(t, tt.column, terr.Column)
+			assert.Contains(t, terr.Error(), tt.contains)
+		})
+	}
+}
+
+func TestTemplate_UnknownFields(t *testing.T) {
+	tmpl, err := Parse("Hi {Firstname|Guest}, {if Town}in {Town}{end} {FirstName}")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, []Ref{
+		{Name: "Firstname", Raw: "{Firstname|Guest}", Offset: 3},
+		{Name: "Town", Raw: "{if Town}", Offset: 22},
+		{Name: "Town", Raw: "{Town}", Offset: 34},
+	}, tmpl.UnknownFields())
+
+	assert.Equal(t, "FirstName", Suggest("Firstname"))
+	assert.Equal(t, "", Suggest("Town"))
+}
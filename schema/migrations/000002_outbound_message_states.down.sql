@@ -0,0 +1,9 @@
+-- Release messages claimed by a worker back to pending
+
+UPDATE outbound_messages SET status = 'pending' WHERE status = 'sending';
+
+ALTER TABLE outbound_messages DROP CONSTRAINT IF EXISTS outbound_messages_status_check;
+
+ALTER TABLE outbound_messages
+    ADD CONSTRAINT outbound_messages_status_check
+    CHECK (status IN ('pending', 'sent', 'failed'));
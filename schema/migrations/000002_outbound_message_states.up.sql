@@ -0,0 +1,7 @@
+-- Allow outbound messages to be claimed by a worker before delivery
+
+ALTER TABLE outbound_messages DROP CONSTRAINT IF EXISTS outbound_messages_status_check;
+
+ALTER TABLE outbound_messages
+    ADD CONSTRAINT outbound_messages_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'failed'));
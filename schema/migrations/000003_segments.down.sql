@@ -0,0 +1,5 @@
+-- Drop customers index used by segment filters
+DROP INDEX IF EXISTS idx_customers_created_at;
+
+-- Drop segments table
+DROP TABLE IF EXISTS segments;
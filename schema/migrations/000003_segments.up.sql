@@ -0,0 +1,15 @@
+-- Segments table
+
+CREATE TABLE segments (
+    id                  BIGSERIAL PRIMARY KEY,
+    name                VARCHAR(255) NOT NULL,
+    location            VARCHAR(255),
+    preferred_product   VARCHAR(255),
+    phone_prefix        VARCHAR(32),
+    created_after       TIMESTAMP NULL,
+    created_before      TIMESTAMP NULL,
+    created_at          TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_customers_created_at ON customers(created_at);
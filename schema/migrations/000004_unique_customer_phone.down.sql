@@ -0,0 +1,5 @@
+-- Restore non-unique phone index
+
+DROP INDEX IF EXISTS idx_customers_phone;
+
+CREATE INDEX idx_customers_phone ON customers(phone);
@@ -0,0 +1,5 @@
+-- Customers are identified by their phone number
+
+DROP INDEX IF EXISTS idx_customers_phone;
+
+CREATE UNIQUE INDEX idx_customers_phone ON customers(phone);
@@ -0,0 +1,7 @@
+-- Drop indexes for customer_import_rejections
+DROP INDEX IF EXISTS idx_customer_import_rejections_import_id;
+
+-- Drop customer import tables
+DROP TABLE IF EXISTS customer_import_rejections;
+DROP TABLE IF EXISTS customer_import_sources;
+DROP TABLE IF EXISTS customer_imports;
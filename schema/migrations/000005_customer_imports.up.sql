@@ -0,0 +1,33 @@
+-- Customer imports table
+
+CREATE TABLE customer_imports (
+    id              BIGSERIAL PRIMARY KEY,
+    format          VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'ndjson')),
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'completed', 'failed')),
+    column_mapping  JSONB NOT NULL DEFAULT '{}',
+    processed_rows  INT NOT NULL DEFAULT 0,
+    imported_rows   INT NOT NULL DEFAULT 0,
+    rejected_rows   INT NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Uploaded files, kept apart so import status reads never load the payload
+
+CREATE TABLE customer_import_sources (
+    import_id   BIGINT PRIMARY KEY REFERENCES customer_imports(id) ON DELETE CASCADE,
+    data        BYTEA NOT NULL
+);
+
+-- Rows rejected during an import
+
+CREATE TABLE customer_import_rejections (
+    id          BIGSERIAL PRIMARY KEY,
+    import_id   BIGINT NOT NULL REFERENCES customer_imports(id) ON DELETE CASCADE,
+    row_number  INT NOT NULL,
+    raw         TEXT NOT NULL,
+    reason      TEXT NOT NULL
+);
+
+CREATE INDEX idx_customer_import_rejections_import_id ON customer_import_rejections(import_id);
@@ -0,0 +1,3 @@
+-- Lenient campaigns accept templates with unknown placeholders and report them as warnings
+
+ALTER TABLE campaigns ADD COLUMN lenient_templates BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+ALTER TABLE campaigns DROP COLUMN IF EXISTS max_segments;
+
+ALTER TABLE outbound_messages DROP COLUMN IF EXISTS segments;
+ALTER TABLE outbound_messages DROP COLUMN IF EXISTS encoding;
@@ -0,0 +1,8 @@
+-- SMS encoding and segment count of each rendered message
+
+ALTER TABLE outbound_messages ADD COLUMN encoding VARCHAR(10) CHECK (encoding IN ('GSM-7', 'UCS-2'));
+ALTER TABLE outbound_messages ADD COLUMN segments INT;
+
+-- Optional per-campaign cap on SMS segments per message
+
+ALTER TABLE campaigns ADD COLUMN max_segments INT CHECK (max_segments > 0);
@@ -0,0 +1,17 @@
+-- Tasks waiting to be published to the queue, written in the same transaction
+-- as the rows they refer to
+
+CREATE TABLE outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    task_type       VARCHAR(100) NOT NULL,
+    queue           VARCHAR(100) NOT NULL,
+    payload         BYTEA NOT NULL,
+    process_at      TIMESTAMP,
+    max_retry       INT,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    dispatched_at   TIMESTAMP,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_undispatched ON outbox(id) WHERE dispatched_at IS NULL;
@@ -0,0 +1,20 @@
+-- Campaign dispatch jobs: one per send request, fanned out by the worker tier
+
+CREATE TABLE campaign_dispatches (
+    id                  BIGSERIAL PRIMARY KEY,
+    campaign_id         BIGINT NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    status              VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    segment_id          BIGINT,
+    customer_ids        BIGINT[],
+    total_customers     INT NOT NULL DEFAULT 0,
+    queued_messages     INT NOT NULL DEFAULT 0,
+    last_customer_id    BIGINT NOT NULL DEFAULT 0,
+    last_error          TEXT,
+    started_at          TIMESTAMP,
+    finished_at         TIMESTAMP,
+    created_at          TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((segment_id IS NULL) <> (customer_ids IS NULL))
+);
+
+CREATE INDEX idx_campaign_dispatches_campaign_id ON campaign_dispatches(campaign_id);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS campaign_dispatch_outcomes;
+
+ALTER TABLE campaign_dispatches
+    DROP COLUMN IF EXISTS mode,
+    DROP COLUMN IF EXISTS skipped_customers,
+    DROP COLUMN IF EXISTS failed_customers;
@@ -0,0 +1,18 @@
+-- Per-customer outcomes of campaign dispatches
+
+ALTER TABLE campaign_dispatches
+    ADD COLUMN mode VARCHAR(20) NOT NULL DEFAULT 'fail_fast' CHECK (mode IN ('fail_fast', 'best_effort')),
+    ADD COLUMN skipped_customers INT NOT NULL DEFAULT 0,
+    ADD COLUMN failed_customers INT NOT NULL DEFAULT 0;
+
+CREATE TABLE campaign_dispatch_outcomes (
+    id              BIGSERIAL PRIMARY KEY,
+    dispatch_id     BIGINT NOT NULL REFERENCES campaign_dispatches(id) ON DELETE CASCADE,
+    customer_id     BIGINT NOT NULL,
+    outcome         VARCHAR(30) NOT NULL CHECK (outcome IN ('queued', 'skipped_not_found', 'skipped_duplicate', 'failed')),
+    reason          TEXT,
+    message_id      BIGINT REFERENCES outbound_messages(id) ON DELETE SET NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_campaign_dispatch_outcomes_dispatch_id ON campaign_dispatch_outcomes(dispatch_id, outcome);
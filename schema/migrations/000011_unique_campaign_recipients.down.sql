@@ -0,0 +1,11 @@
+DELETE FROM campaign_dispatch_outcomes WHERE outcome = 'skipped_already_sent';
+
+ALTER TABLE campaign_dispatch_outcomes
+    DROP CONSTRAINT campaign_dispatch_outcomes_outcome_check,
+    ADD CONSTRAINT campaign_dispatch_outcomes_outcome_check
+        CHECK (outcome IN ('queued', 'skipped_not_found', 'skipped_duplicate', 'failed'));
+
+DROP INDEX IF EXISTS uq_outbound_messages_campaign_customer;
+
+ALTER TABLE campaign_dispatches DROP COLUMN IF EXISTS resend_reason;
+ALTER TABLE outbound_messages DROP COLUMN IF EXISTS resend_reason;
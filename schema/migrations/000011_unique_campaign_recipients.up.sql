@@ -0,0 +1,25 @@
+-- One delivery per customer and campaign, unless explicitly resent
+
+ALTER TABLE outbound_messages ADD COLUMN resend_reason TEXT;
+ALTER TABLE campaign_dispatches ADD COLUMN resend_reason TEXT;
+
+-- Messages queued before this guarantee existed keep their history; every
+-- copy after the first is turned into a resend so that the index can build.
+UPDATE outbound_messages m
+SET resend_reason = 'duplicate send before recipient deduplication'
+WHERE EXISTS (
+    SELECT 1
+    FROM outbound_messages o
+    WHERE o.campaign_id = m.campaign_id
+        AND o.customer_id = m.customer_id
+        AND o.id < m.id
+);
+
+CREATE UNIQUE INDEX uq_outbound_messages_campaign_customer
+    ON outbound_messages(campaign_id, customer_id)
+    WHERE resend_reason IS NULL;
+
+ALTER TABLE campaign_dispatch_outcomes
+    DROP CONSTRAINT campaign_dispatch_outcomes_outcome_check,
+    ADD CONSTRAINT campaign_dispatch_outcomes_outcome_check
+        CHECK (outcome IN ('queued', 'skipped_not_found', 'skipped_duplicate', 'skipped_already_sent', 'failed'));
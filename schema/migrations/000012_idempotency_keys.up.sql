@@ -0,0 +1,17 @@
+-- Stored responses of requests sent with an Idempotency-Key header
+
+CREATE TABLE idempotency_keys (
+    id               BIGSERIAL PRIMARY KEY,
+    scope            TEXT NOT NULL,
+    idempotency_key  VARCHAR(255) NOT NULL,
+    fingerprint      VARCHAR(64) NOT NULL,
+    status_code      INT,
+    content_type     TEXT,
+    response_body    BYTEA,
+    locked_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at       TIMESTAMP NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (scope, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
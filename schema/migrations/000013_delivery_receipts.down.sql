@@ -0,0 +1,15 @@
+-- Fold receipt statuses back into sent
+
+DROP TABLE IF EXISTS message_events;
+
+UPDATE outbound_messages SET status = 'sent' WHERE status IN ('delivered', 'undelivered', 'read');
+
+ALTER TABLE outbound_messages DROP CONSTRAINT IF EXISTS outbound_messages_status_check;
+
+ALTER TABLE outbound_messages
+    ADD CONSTRAINT outbound_messages_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'failed'));
+
+DROP INDEX IF EXISTS idx_outbound_messages_provider_message_id;
+
+ALTER TABLE outbound_messages DROP COLUMN IF EXISTS provider_message_id;
@@ -0,0 +1,25 @@
+-- Delivery receipts reported by the gateways after a message was sent
+
+ALTER TABLE outbound_messages ADD COLUMN provider_message_id VARCHAR(255);
+
+CREATE INDEX idx_outbound_messages_provider_message_id
+    ON outbound_messages(provider_message_id)
+    WHERE provider_message_id IS NOT NULL;
+
+ALTER TABLE outbound_messages DROP CONSTRAINT IF EXISTS outbound_messages_status_check;
+
+ALTER TABLE outbound_messages
+    ADD CONSTRAINT outbound_messages_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'delivered', 'undelivered', 'read', 'failed'));
+
+CREATE TABLE message_events (
+    id              BIGSERIAL PRIMARY KEY,
+    message_id      BIGINT NOT NULL REFERENCES outbound_messages(id) ON DELETE CASCADE,
+    event_type      VARCHAR(30) NOT NULL CHECK (event_type IN ('receipt')),
+    status          VARCHAR(20) NOT NULL,
+    detail          JSONB NOT NULL DEFAULT '{}',
+    occurred_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_message_events_message_id ON message_events(message_id, id);
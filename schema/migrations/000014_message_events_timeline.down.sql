@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS message_events_append_only ON message_events;
+DROP FUNCTION IF EXISTS reject_message_event_update();
+
+DELETE FROM message_events WHERE event_type <> 'receipt';
+
+ALTER TABLE message_events DROP CONSTRAINT IF EXISTS message_events_event_type_check;
+
+ALTER TABLE message_events
+    ADD CONSTRAINT message_events_event_type_check
+    CHECK (event_type IN ('receipt'));
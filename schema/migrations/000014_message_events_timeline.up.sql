@@ -0,0 +1,18 @@
+-- Record every step of a message's life, not only delivery receipts
+
+ALTER TABLE message_events DROP CONSTRAINT IF EXISTS message_events_event_type_check;
+
+ALTER TABLE message_events
+    ADD CONSTRAINT message_events_event_type_check
+    CHECK (event_type IN ('queued', 'status_changed', 'attempt', 'provider_response', 'receipt'));
+
+-- The timeline is evidence for support; rows are only ever appended.
+CREATE FUNCTION reject_message_event_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'message_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER message_events_append_only
+    BEFORE UPDATE ON message_events
+    FOR EACH ROW EXECUTE FUNCTION reject_message_event_update();
@@ -0,0 +1,3 @@
+-- Keyset pagination walks campaigns newest first by (created_at, id)
+
+CREATE INDEX idx_campaigns_created_at_id ON campaigns(created_at DESC, id DESC);
@@ -0,0 +1,25 @@
+DROP INDEX IF EXISTS uq_outbound_messages_campaign_customer;
+
+DELETE FROM outbound_messages WHERE status = 'cancelled';
+
+CREATE UNIQUE INDEX uq_outbound_messages_campaign_customer
+    ON outbound_messages(campaign_id, customer_id)
+    WHERE resend_reason IS NULL;
+
+ALTER TABLE outbound_messages DROP CONSTRAINT IF EXISTS outbound_messages_status_check;
+
+ALTER TABLE outbound_messages
+    ADD CONSTRAINT outbound_messages_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'delivered', 'undelivered', 'read', 'failed'));
+
+ALTER TABLE outbound_messages DROP COLUMN IF EXISTS task_id;
+
+UPDATE campaigns SET status = 'draft' WHERE status = 'archived';
+
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_check;
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_status_check
+    CHECK (status IN ('draft', 'scheduled', 'sending', 'sent', 'failed'));
+
+ALTER TABLE campaigns DROP COLUMN IF EXISTS archived_at;
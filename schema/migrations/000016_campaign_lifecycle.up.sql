@@ -0,0 +1,27 @@
+-- Campaigns can be edited until they start sending, cloned and archived
+
+ALTER TABLE campaigns ADD COLUMN archived_at TIMESTAMP;
+
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_check;
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_status_check
+    CHECK (status IN ('draft', 'scheduled', 'sending', 'sent', 'failed', 'archived'));
+
+-- The asynq task that currently delivers a message. Tasks replaced when a
+-- campaign is rescheduled find a different ID here and do nothing.
+ALTER TABLE outbound_messages ADD COLUMN task_id VARCHAR(255);
+
+ALTER TABLE outbound_messages DROP CONSTRAINT IF EXISTS outbound_messages_status_check;
+
+ALTER TABLE outbound_messages
+    ADD CONSTRAINT outbound_messages_status_check
+    CHECK (status IN ('pending', 'sending', 'sent', 'delivered', 'undelivered', 'read', 'failed', 'cancelled'));
+
+-- A cancelled message no longer stands in the way of sending the campaign to
+-- the same customer again.
+DROP INDEX IF EXISTS uq_outbound_messages_campaign_customer;
+
+CREATE UNIQUE INDEX uq_outbound_messages_campaign_customer
+    ON outbound_messages(campaign_id, customer_id)
+    WHERE resend_reason IS NULL AND status <> 'cancelled';
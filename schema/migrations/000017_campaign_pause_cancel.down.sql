@@ -0,0 +1,11 @@
+UPDATE campaigns SET status = 'sending' WHERE status = 'paused';
+UPDATE campaigns SET status = 'failed' WHERE status = 'cancelled';
+
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_check;
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_status_check
+    CHECK (status IN ('draft', 'scheduled', 'sending', 'sent', 'failed', 'archived'));
+
+ALTER TABLE campaigns DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS paused_at;
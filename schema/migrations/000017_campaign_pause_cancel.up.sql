@@ -0,0 +1,10 @@
+-- Campaigns can be paused, resumed and cancelled while scheduled or sending
+
+ALTER TABLE campaigns ADD COLUMN paused_at TIMESTAMP;
+ALTER TABLE campaigns ADD COLUMN cancelled_at TIMESTAMP;
+
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_check;
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_status_check
+    CHECK (status IN ('draft', 'scheduled', 'sending', 'paused', 'sent', 'failed', 'cancelled', 'archived'));
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_campaigns_due;
+
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_audience_check;
+
+ALTER TABLE campaigns DROP COLUMN IF EXISTS customer_ids;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS segment_id;
@@ -0,0 +1,11 @@
+-- Scheduled campaigns carry the audience the scheduler sends them to
+
+ALTER TABLE campaigns ADD COLUMN segment_id BIGINT;
+ALTER TABLE campaigns ADD COLUMN customer_ids BIGINT[];
+
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_audience_check
+    CHECK (segment_id IS NULL OR customer_ids IS NULL);
+
+-- The scheduler looks for scheduled campaigns that are due.
+CREATE INDEX idx_campaigns_due ON campaigns(scheduled_at) WHERE status = 'scheduled';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS uq_campaigns_parent_run;
+
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_run_recurrence_check;
+
+ALTER TABLE campaigns DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS recurrence_ended_at;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS timezone;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS recurrence;
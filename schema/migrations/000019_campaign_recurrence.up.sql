@@ -0,0 +1,14 @@
+-- Recurring campaigns start a run, a campaign of their own, at every occurrence
+
+ALTER TABLE campaigns ADD COLUMN recurrence TEXT;
+ALTER TABLE campaigns ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE campaigns ADD COLUMN recurrence_ended_at TIMESTAMP;
+ALTER TABLE campaigns ADD COLUMN parent_id BIGINT REFERENCES campaigns(id) ON DELETE CASCADE;
+
+-- Runs do not recur themselves.
+ALTER TABLE campaigns
+    ADD CONSTRAINT campaigns_run_recurrence_check
+    CHECK (parent_id IS NULL OR recurrence IS NULL);
+
+-- One run per occurrence.
+CREATE UNIQUE INDEX uq_campaigns_parent_run ON campaigns(parent_id, scheduled_at) WHERE parent_id IS NOT NULL;
@@ -0,0 +1,13 @@
+DELETE FROM message_events WHERE event_type = 'deferred';
+
+ALTER TABLE message_events DROP CONSTRAINT IF EXISTS message_events_event_type_check;
+
+ALTER TABLE message_events
+    ADD CONSTRAINT message_events_event_type_check
+    CHECK (event_type IN ('queued', 'status_changed', 'attempt', 'provider_response', 'receipt'));
+
+ALTER TABLE outbox
+    ALTER COLUMN process_at TYPE TIMESTAMP USING process_at AT TIME ZONE 'UTC';
+
+ALTER TABLE campaigns
+    ALTER COLUMN scheduled_at TYPE TIMESTAMP USING scheduled_at AT TIME ZONE 'UTC';
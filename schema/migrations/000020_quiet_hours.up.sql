@@ -0,0 +1,20 @@
+-- Campaign times keep the offset they were given in, and messages held back
+-- by quiet hours say so on their timeline
+
+-- Existing values are read as UTC. Recurring occurrences and server-generated
+-- times were written in UTC, but a one-off scheduled_at kept the wall clock of
+-- the offset it was submitted in and lost the offset itself: 10:00+03:00 was
+-- stored as 10:00 and becomes 10:00 UTC, three hours after the intended time.
+-- Outbox entries copied from such a campaign shift the same way. The offset
+-- cannot be recovered here; see the upgrade note in the README.
+ALTER TABLE campaigns
+    ALTER COLUMN scheduled_at TYPE TIMESTAMPTZ USING scheduled_at AT TIME ZONE 'UTC';
+
+ALTER TABLE outbox
+    ALTER COLUMN process_at TYPE TIMESTAMPTZ USING process_at AT TIME ZONE 'UTC';
+
+ALTER TABLE message_events DROP CONSTRAINT IF EXISTS message_events_event_type_check;
+
+ALTER TABLE message_events
+    ADD CONSTRAINT message_events_event_type_check
+    CHECK (event_type IN ('queued', 'status_changed', 'attempt', 'provider_response', 'receipt', 'deferred'));
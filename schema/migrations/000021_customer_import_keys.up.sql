@@ -0,0 +1,9 @@
+-- Idempotency-Keys of import uploads, kept with the import they created so a
+-- retried upload returns it instead of importing the file again
+
+CREATE TABLE customer_import_keys (
+    idempotency_key VARCHAR(255) PRIMARY KEY,
+    import_id       BIGINT NOT NULL REFERENCES customer_imports(id) ON DELETE CASCADE,
+    fingerprint     TEXT NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,11 @@
+CREATE TABLE customer_import_sources (
+    import_id   BIGINT PRIMARY KEY REFERENCES customer_imports(id) ON DELETE CASCADE,
+    data        BYTEA NOT NULL
+);
+
+INSERT INTO customer_import_sources (import_id, data)
+SELECT import_id, string_agg(data, ''::bytea ORDER BY seq)
+FROM customer_import_chunks
+GROUP BY import_id;
+
+DROP TABLE IF EXISTS customer_import_chunks;
@@ -0,0 +1,14 @@
+-- Uploaded files are stored in chunks, so that neither the web tier nor the
+-- worker holds a whole file in memory
+
+CREATE TABLE customer_import_chunks (
+    import_id   BIGINT NOT NULL REFERENCES customer_imports(id) ON DELETE CASCADE,
+    seq         INT NOT NULL,
+    data        BYTEA NOT NULL,
+    PRIMARY KEY (import_id, seq)
+);
+
+INSERT INTO customer_import_chunks (import_id, seq, data)
+SELECT import_id, 0, data FROM customer_import_sources;
+
+DROP TABLE IF EXISTS customer_import_sources;
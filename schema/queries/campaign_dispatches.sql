@@ -0,0 +1,63 @@
+-- name: CreateCampaignDispatch :one
+INSERT INTO campaign_dispatches (campaign_id, mode, segment_id, customer_ids, total_customers, skipped_customers, resend_reason)
+VALUES (@campaign_id, @mode, @segment_id, @customer_ids, @total_customers, @skipped_customers, @resend_reason)
+RETURNING *;
+
+-- name: GetCampaignDispatch :one
+SELECT * FROM campaign_dispatches WHERE id = @dispatch_id;
+
+-- name: StartCampaignDispatch :one
+UPDATE campaign_dispatches
+SET
+    status = 'running',
+    last_error = NULL,
+    started_at = COALESCE(started_at, NOW()),
+    finished_at = NULL,
+    updated_at = NOW()
+WHERE id = @dispatch_id
+RETURNING *;
+
+-- name: RecordCampaignDispatchProgress :one
+UPDATE campaign_dispatches
+SET
+    queued_messages = queued_messages + @queued::int,
+    skipped_customers = skipped_customers + @skipped::int,
+    failed_customers = failed_customers + @failed::int,
+    last_customer_id = @last_customer_id,
+    updated_at = NOW()
+WHERE id = @dispatch_id
+RETURNING *;
+
+-- name: FinishCampaignDispatch :one
+UPDATE campaign_dispatches
+SET
+    status = @status,
+    last_error = @last_error,
+    finished_at = NOW(),
+    updated_at = NOW()
+WHERE id = @dispatch_id
+RETURNING *;
+
+-- name: CreateCampaignDispatchOutcomes :exec
+INSERT INTO campaign_dispatch_outcomes (dispatch_id, customer_id, outcome, reason, message_id)
+SELECT
+    @dispatch_id::bigint,
+    unnest(@customer_ids::bigint[]),
+    unnest(@outcomes::text[]),
+    NULLIF(unnest(@reasons::text[]), ''),
+    NULLIF(unnest(@message_ids::bigint[]), 0);
+
+-- name: ListCampaignDispatchOutcomes :many
+SELECT
+    o.*,
+    COUNT(*) OVER() AS total_count
+FROM campaign_dispatch_outcomes o
+WHERE
+    o.dispatch_id = @dispatch_id
+    AND (
+        cardinality(@outcomes::text[]) = 0
+        OR o.outcome = ANY(@outcomes::text[])
+    )
+ORDER BY o.id
+LIMIT @page_size
+OFFSET ((@page_number - 1) * @page_size);
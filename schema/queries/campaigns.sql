@@ -1,8 +1,125 @@
+-- name: ArchiveCampaign :one
+UPDATE campaigns c
+SET
+    status = 'archived',
+    archived_at = NOW(),
+    updated_at = NOW()
+WHERE c.id = @campaign_id
+    AND c.status = ANY(@from_statuses::text[])
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d
+        WHERE d.campaign_id = c.id AND d.status IN ('pending', 'running')
+    )
+RETURNING *;
+
+-- name: CancelCampaign :one
+UPDATE campaigns
+SET
+    status = 'cancelled',
+    cancelled_at = NOW(),
+    updated_at = NOW()
+WHERE id = @campaign_id
+    AND status = ANY(@from_statuses::text[])
+RETURNING *;
+
+-- name: CountCampaigns :one
+SELECT COUNT(*)
+FROM campaigns c
+WHERE
+    (
+        ((@status::text IS NULL OR @status::text = '') AND c.status <> 'archived')
+        OR c.status = @status
+    )
+    AND (
+        @channel::text IS NULL 
+        OR @channel::text = '' 
+        OR c.channel = @channel
+    )
+    AND c.parent_id IS NULL;
+
 -- name: CreateCampaign :one
-INSERT INTO campaigns (name, channel, status, base_template, scheduled_at)
-VALUES (@name, @channel, @status, @base_template, @scheduled_at) 
+INSERT INTO campaigns (name, channel, status, base_template, scheduled_at, lenient_templates, max_segments, segment_id, customer_ids, recurrence, timezone)
+VALUES (@name, @channel, @status, @base_template, @scheduled_at, @lenient_templates, @max_segments, @segment_id, @customer_ids, @recurrence, @timezone) 
+RETURNING *;
+
+-- name: CreateCampaignRun :one
+-- Starts the occurrence of a recurring campaign due at run_at as a campaign of
+-- its own, with the content and audience of its parent.
+INSERT INTO campaigns (name, channel, status, base_template, scheduled_at, lenient_templates, max_segments, segment_id, customer_ids, timezone, parent_id)
+SELECT p.name, p.channel, 'sending', p.base_template, @run_at::timestamptz, p.lenient_templates, p.max_segments, p.segment_id, p.customer_ids, p.timezone, p.id
+FROM campaigns p
+WHERE p.id = @campaign_id
+RETURNING *;
+
+-- name: AdvanceCampaignRecurrence :one
+-- Moves a recurring campaign on to its next occurrence, provided it is still
+-- due at expected_run_at.
+UPDATE campaigns
+SET
+    scheduled_at = @next_run_at::timestamptz,
+    updated_at = NOW()
+WHERE id = @campaign_id
+    AND recurrence IS NOT NULL
+    AND status = ANY(@from_statuses::text[])
+    AND scheduled_at = @expected_run_at::timestamptz
 RETURNING *;
 
+-- name: EndCampaignRecurrence :one
+-- Stops a recurring campaign from starting more runs and returns it to draft.
+-- Runs already started are left alone.
+UPDATE campaigns
+SET
+    status = 'draft',
+    scheduled_at = NULL,
+    paused_at = NULL,
+    recurrence = NULL,
+    recurrence_ended_at = NOW(),
+    updated_at = NOW()
+WHERE id = @campaign_id
+    AND recurrence IS NOT NULL
+    AND status = ANY(@from_statuses::text[])
+RETURNING *;
+
+-- name: ListCampaignRuns :many
+-- The runs a recurring campaign started, latest occurrence first, each with
+-- the stats of its own messages.
+SELECT
+    c.*,
+    jsonb_build_object(
+        'total_messages', COALESCE(COUNT(om.id), 0),
+        'pending',        COALESCE(SUM(CASE WHEN om.status = 'pending' THEN 1 ELSE 0 END), 0),
+        'sending',        COALESCE(SUM(CASE WHEN om.status = 'sending' THEN 1 ELSE 0 END), 0),
+        'sent',           COALESCE(SUM(CASE WHEN om.status = 'sent' THEN 1 ELSE 0 END), 0),
+        'delivered',      COALESCE(SUM(CASE WHEN om.status = 'delivered' THEN 1 ELSE 0 END), 0),
+        'undelivered',    COALESCE(SUM(CASE WHEN om.status = 'undelivered' THEN 1 ELSE 0 END), 0),
+        'read',           COALESCE(SUM(CASE WHEN om.status = 'read' THEN 1 ELSE 0 END), 0),
+        'failed',         COALESCE(SUM(CASE WHEN om.status = 'failed' THEN 1 ELSE 0 END), 0),
+        'cancelled',      COALESCE(SUM(CASE WHEN om.status = 'cancelled' THEN 1 ELSE 0 END), 0)
+    ) AS stats,
+    COUNT(*) OVER() AS total_count
+FROM campaigns c
+LEFT JOIN outbound_messages om ON om.campaign_id = c.id
+WHERE c.parent_id = @campaign_id
+GROUP BY c.id
+ORDER BY c.scheduled_at DESC, c.id DESC
+LIMIT @page_size
+OFFSET ((@page_number - 1) * @page_size);
+
+-- name: ListDueCampaigns :many
+-- Scheduled campaigns whose time has come and that were not sent by hand ahead
+-- of it. They stay locked until the scheduler's transaction ends.
+SELECT c.*
+FROM campaigns c
+WHERE c.status = 'scheduled'
+    AND c.scheduled_at <= @now::timestamptz
+    AND (c.segment_id IS NOT NULL OR c.customer_ids IS NOT NULL)
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d WHERE d.campaign_id = c.id
+    )
+ORDER BY c.scheduled_at, c.id
+LIMIT @batch_size
+FOR UPDATE SKIP LOCKED;
+
 -- name: ListCampaigns :many
 SELECT
     c.*,
@@ -10,8 +127,7 @@ SELECT
 FROM campaigns c
 WHERE
     (
-        @status::text IS NULL 
-        OR @status::text = '' 
+        ((@status::text IS NULL OR @status::text = '') AND c.status <> 'archived')
         OR c.status = @status
     )
     AND (
@@ -19,20 +135,177 @@ WHERE
         OR @channel::text = '' 
         OR c.channel = @channel
     )
+    AND c.parent_id IS NULL
 ORDER BY c.id DESC
 LIMIT @page_size
 OFFSET ((@page_number - 1) * @page_size);
 
+-- name: ListCampaignsAfter :many
+-- Campaigns older than the cursor, newest first. Without a cursor this is the
+-- first page.
+SELECT c.*
+FROM campaigns c
+WHERE
+    (
+        ((@status::text IS NULL OR @status::text = '') AND c.status <> 'archived')
+        OR c.status = @status
+    )
+    AND (
+        @channel::text IS NULL 
+        OR @channel::text = '' 
+        OR c.channel = @channel
+    )
+    AND c.parent_id IS NULL
+    AND (
+        sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (c.created_at, c.id) < (sqlc.narg(cursor_created_at)::timestamp, @cursor_id::bigint)
+    )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT @page_size;
+
+-- name: ListCampaignsBefore :many
+-- Campaigns newer than the cursor, oldest first.
+SELECT c.*
+FROM campaigns c
+WHERE
+    (
+        ((@status::text IS NULL OR @status::text = '') AND c.status <> 'archived')
+        OR c.status = @status
+    )
+    AND (
+        @channel::text IS NULL 
+        OR @channel::text = '' 
+        OR c.channel = @channel
+    )
+    AND c.parent_id IS NULL
+    AND (c.created_at, c.id) > (@cursor_created_at::timestamp, @cursor_id::bigint)
+ORDER BY c.created_at, c.id
+LIMIT @page_size;
+
 -- name: GetCampaign :one
 SELECT
     c.*,
     jsonb_build_object(
         'total_messages', COALESCE(COUNT(om.id), 0),
         'pending',        COALESCE(SUM(CASE WHEN om.status = 'pending' THEN 1 ELSE 0 END), 0),
+        'sending',        COALESCE(SUM(CASE WHEN om.status = 'sending' THEN 1 ELSE 0 END), 0),
         'sent',           COALESCE(SUM(CASE WHEN om.status = 'sent' THEN 1 ELSE 0 END), 0),
-        'failed',         COALESCE(SUM(CASE WHEN om.status = 'failed' THEN 1 ELSE 0 END), 0)
+        'delivered',      COALESCE(SUM(CASE WHEN om.status = 'delivered' THEN 1 ELSE 0 END), 0),
+        'undelivered',    COALESCE(SUM(CASE WHEN om.status = 'undelivered' THEN 1 ELSE 0 END), 0),
+        'read',           COALESCE(SUM(CASE WHEN om.status = 'read' THEN 1 ELSE 0 END), 0),
+        'failed',         COALESCE(SUM(CASE WHEN om.status = 'failed' THEN 1 ELSE 0 END), 0),
+        'cancelled',      COALESCE(SUM(CASE WHEN om.status = 'cancelled' THEN 1 ELSE 0 END), 0),
+        'delivery_rate',  ROUND(
+            SUM(CASE WHEN om.status IN ('delivered', 'read') THEN 1 ELSE 0 END)::numeric
+            / NULLIF(SUM(CASE WHEN om.status IN ('sent', 'delivered', 'undelivered', 'read') THEN 1 ELSE 0 END), 0),
+            4
+        ),
+        'read_rate',      ROUND(
+            SUM(CASE WHEN om.status = 'read' THEN 1 ELSE 0 END)::numeric
+            / NULLIF(SUM(CASE WHEN om.status IN ('sent', 'delivered', 'undelivered', 'read') THEN 1 ELSE 0 END), 0),
+            4
+        )
     ) AS stats
 FROM campaigns c
 LEFT JOIN outbound_messages om ON om.campaign_id = c.id
 WHERE c.id = @campaign_id
 GROUP BY c.id;
+
+-- name: GetCampaignStatus :one
+SELECT status FROM campaigns WHERE id = @campaign_id;
+
+-- name: PauseCampaign :one
+UPDATE campaigns
+SET
+    status = 'paused',
+    paused_at = NOW(),
+    updated_at = NOW()
+WHERE id = @campaign_id
+    AND status = ANY(@from_statuses::text[])
+RETURNING *;
+
+-- name: ResumeCampaign :one
+-- Puts a paused campaign back to sending, or back to scheduled when its time
+-- has not come yet or the scheduler has yet to dispatch it.
+UPDATE campaigns c
+SET
+    status = CASE
+        WHEN c.scheduled_at > @now::timestamptz THEN 'scheduled'
+        WHEN c.scheduled_at IS NOT NULL AND NOT EXISTS (
+            SELECT 1 FROM campaign_dispatches d WHERE d.campaign_id = c.id
+        ) THEN 'scheduled'
+        ELSE 'sending'
+    END,
+    paused_at = NULL,
+    updated_at = NOW()
+WHERE c.id = @campaign_id
+    AND c.status = 'paused'
+RETURNING *;
+
+-- name: StartScheduledCampaign :one
+-- Moves a scheduled campaign to sending once its time has come. Tasks of a
+-- campaign that was rescheduled to a later time leave it alone.
+UPDATE campaigns
+SET
+    status = 'sending',
+    updated_at = NOW()
+WHERE id = @campaign_id
+    AND status = 'scheduled'
+    AND scheduled_at <= @now::timestamptz
+RETURNING *;
+
+-- name: TransitionCampaign :one
+UPDATE campaigns
+SET
+    status = @to_status,
+    updated_at = NOW()
+WHERE id = @campaign_id
+    AND status = ANY(@from_statuses::text[])
+RETURNING *;
+
+-- name: FinalizeCampaign :one
+UPDATE campaigns c
+SET
+    status = CASE
+        WHEN EXISTS (
+            SELECT 1 FROM outbound_messages om
+            WHERE om.campaign_id = c.id AND om.status IN ('sent', 'delivered', 'undelivered', 'read')
+        ) THEN 'sent'
+        ELSE 'failed'
+    END,
+    updated_at = NOW()
+WHERE c.id = @campaign_id
+    AND c.status = 'sending'
+    AND NOT EXISTS (
+        SELECT 1 FROM outbound_messages om
+        WHERE om.campaign_id = c.id AND om.status IN ('pending', 'sending')
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d
+        WHERE d.campaign_id = c.id AND d.status IN ('pending', 'running')
+    )
+RETURNING *;
+
+-- name: UpdateCampaign :one
+UPDATE campaigns c
+SET
+    name = @name,
+    status = @status,
+    base_template = @base_template,
+    scheduled_at = @scheduled_at,
+    lenient_templates = @lenient_templates,
+    max_segments = @max_segments,
+    segment_id = @segment_id,
+    customer_ids = @customer_ids,
+    recurrence = @recurrence,
+    timezone = @timezone,
+    recurrence_ended_at = @recurrence_ended_at,
+    updated_at = NOW()
+WHERE c.id = @campaign_id
+    AND c.status = ANY(@from_statuses::text[])
+    AND c.updated_at = @expected_updated_at
+    AND NOT EXISTS (
+        SELECT 1 FROM campaign_dispatches d
+        WHERE d.campaign_id = c.id AND d.status IN ('pending', 'running')
+    )
+RETURNING *;
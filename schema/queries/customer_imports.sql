@@ -0,0 +1,73 @@
+-- name: CreateCustomerImport :one
+INSERT INTO customer_imports (format, column_mapping)
+VALUES (@format, @column_mapping)
+RETURNING *;
+
+-- name: CreateCustomerImportChunk :exec
+INSERT INTO customer_import_chunks (import_id, seq, data)
+VALUES (@import_id, @seq, @data);
+
+-- name: GetCustomerImport :one
+SELECT * FROM customer_imports WHERE id = @import_id;
+
+-- name: ClaimCustomerImportKey :one
+-- Ties an Idempotency-Key to the import it created. A key that is already
+-- taken returns no row; a claim racing one that has not committed yet waits
+-- for it.
+INSERT INTO customer_import_keys (idempotency_key, import_id, fingerprint)
+VALUES (@idempotency_key, @import_id, @fingerprint)
+ON CONFLICT (idempotency_key) DO NOTHING
+RETURNING *;
+
+-- name: GetCustomerImportKey :one
+SELECT * FROM customer_import_keys WHERE idempotency_key = @idempotency_key;
+
+-- name: GetCustomerImportChunk :one
+SELECT data FROM customer_import_chunks WHERE import_id = @import_id AND seq = @seq;
+
+-- name: StartCustomerImport :one
+UPDATE customer_imports
+SET
+    status = 'processing',
+    processed_rows = 0,
+    imported_rows = 0,
+    rejected_rows = 0,
+    last_error = NULL,
+    updated_at = NOW()
+WHERE id = @import_id
+RETURNING *;
+
+-- name: RecordCustomerImportProgress :one
+UPDATE customer_imports
+SET
+    processed_rows = processed_rows + @processed::int,
+    imported_rows = imported_rows + @imported::int,
+    rejected_rows = rejected_rows + @rejected::int,
+    updated_at = NOW()
+WHERE id = @import_id
+RETURNING *;
+
+-- name: FinishCustomerImport :one
+UPDATE customer_imports
+SET
+    status = @status,
+    last_error = @last_error,
+    updated_at = NOW()
+WHERE id = @import_id
+RETURNING *;
+
+-- name: CreateCustomerImportRejections :exec
+INSERT INTO customer_import_rejections (import_id, row_number, raw, reason)
+SELECT
+    @import_id::bigint,
+    unnest(@row_numbers::int[]),
+    unnest(@raws::text[]),
+    unnest(@reasons::text[]);
+
+-- name: DeleteCustomerImportRejections :exec
+DELETE FROM customer_import_rejections WHERE import_id = @import_id;
+
+-- name: ListCustomerImportRejections :many
+SELECT * FROM customer_import_rejections
+WHERE import_id = @import_id
+ORDER BY row_number;
@@ -1,2 +1,79 @@
 -- name: GetCustomerById :one
 SELECT * FROM customers WHERE id = @customer_id;
+
+-- name: CreateCustomer :one
+INSERT INTO customers (phone, first_name, last_name, location, preferred_product)
+VALUES (@phone, @first_name, @last_name, @location, @preferred_product)
+RETURNING *;
+
+-- name: ListCustomers :many
+SELECT
+    cu.*,
+    COUNT(*) OVER() AS total_count
+FROM customers cu
+WHERE
+    (
+        @location::text IS NULL
+        OR @location::text = ''
+        OR LOWER(cu.location) = LOWER(@location)
+    )
+    AND (
+        @preferred_product::text IS NULL
+        OR @preferred_product::text = ''
+        OR LOWER(cu.preferred_product) = LOWER(@preferred_product)
+    )
+    AND (
+        @phone::text IS NULL
+        OR @phone::text = ''
+        OR starts_with(cu.phone, @phone)
+    )
+ORDER BY cu.id DESC
+LIMIT @page_size
+OFFSET ((@page_number - 1) * @page_size);
+
+-- name: UpdateCustomer :one
+UPDATE customers
+SET
+    phone = @phone,
+    first_name = @first_name,
+    last_name = @last_name,
+    location = @location,
+    preferred_product = @preferred_product,
+    updated_at = NOW()
+WHERE id = @customer_id
+RETURNING *;
+
+-- name: DeleteCustomer :execrows
+DELETE FROM customers WHERE id = @customer_id;
+
+-- name: UpsertCustomers :execrows
+INSERT INTO customers (phone, first_name, last_name, location, preferred_product)
+SELECT
+    t.phone,
+    NULLIF(t.first_name, ''),
+    NULLIF(t.last_name, ''),
+    NULLIF(t.location, ''),
+    NULLIF(t.preferred_product, '')
+FROM unnest(
+    @phones::text[],
+    @first_names::text[],
+    @last_names::text[],
+    @locations::text[],
+    @preferred_products::text[]
+) AS t(phone, first_name, last_name, location, preferred_product)
+ON CONFLICT (phone) DO UPDATE
+SET
+    first_name = COALESCE(EXCLUDED.first_name, customers.first_name),
+    last_name = COALESCE(EXCLUDED.last_name, customers.last_name),
+    location = COALESCE(EXCLUDED.location, customers.location),
+    preferred_product = COALESCE(EXCLUDED.preferred_product, customers.preferred_product),
+    updated_at = NOW();
+
+-- name: ListExistingCustomerIds :many
+SELECT id FROM customers WHERE id = ANY(@customer_ids::bigint[]);
+
+-- name: ListCustomersByIds :many
+SELECT * FROM customers
+WHERE id = ANY(@customer_ids::bigint[]) AND id > @after_id
+ORDER BY id
+LIMIT @batch_size;
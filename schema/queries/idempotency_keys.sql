@@ -0,0 +1,44 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (scope, idempotency_key, fingerprint, expires_at)
+VALUES (@scope, @idempotency_key, @fingerprint, NOW() + make_interval(secs => @ttl_seconds::int))
+ON CONFLICT (scope, idempotency_key) DO UPDATE
+SET
+    id = nextval(pg_get_serial_sequence('idempotency_keys', 'id')),
+    fingerprint = EXCLUDED.fingerprint,
+    status_code = NULL,
+    content_type = NULL,
+    response_body = NULL,
+    locked_at = NOW(),
+    expires_at = EXCLUDED.expires_at,
+    created_at = NOW()
+WHERE idempotency_keys.expires_at < NOW()
+    OR (
+        idempotency_keys.status_code IS NULL
+        AND idempotency_keys.locked_at < NOW() - make_interval(secs => @lock_timeout_seconds::int)
+    )
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE scope = @scope AND idempotency_key = @idempotency_key;
+
+-- name: PurgeIdempotencyKeys :exec
+DELETE FROM idempotency_keys
+WHERE id IN (
+    SELECT id FROM idempotency_keys
+    WHERE expires_at < NOW()
+    ORDER BY expires_at
+    LIMIT @batch_size
+);
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE id = @id AND status_code IS NULL;
+
+-- name: SaveIdempotentResponse :exec
+UPDATE idempotency_keys
+SET
+    status_code = @status_code,
+    content_type = @content_type,
+    response_body = @response_body
+WHERE id = @id;
@@ -0,0 +1,4 @@
+-- name: TryAdvisoryXactLock :one
+-- Takes a lock that is released when the transaction ends, or reports that
+-- another transaction holds it.
+SELECT pg_try_advisory_xact_lock(@lock_key::bigint);
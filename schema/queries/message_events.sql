@@ -0,0 +1,13 @@
+-- name: CreateMessageEvent :one
+INSERT INTO message_events (message_id, event_type, status, detail, occurred_at)
+VALUES (@message_id, @event_type, @status, @detail, COALESCE(sqlc.narg(occurred_at)::timestamp, NOW()))
+RETURNING *;
+
+-- name: CreateMessageEvents :exec
+INSERT INTO message_events (message_id, event_type, status, detail)
+SELECT unnest(@message_ids::bigint[]), @event_type, @status, @detail;
+
+-- name: ListMessageEvents :many
+SELECT * FROM message_events
+WHERE message_id = @message_id
+ORDER BY id;
@@ -1,4 +1,163 @@
+-- name: CancelCampaignMessages :many
+UPDATE outbound_messages
+SET
+    status = 'cancelled',
+    updated_at = NOW()
+WHERE campaign_id = @campaign_id
+    AND status = 'pending'
+RETURNING id, task_id;
+
 -- name: CreateOutboundMessage :one
-INSERT INTO outbound_messages (campaign_id, customer_id, status, rendered_content, last_error, retry_count)
-VALUES (@campaign_id, @customer_id, @status, @rendered_content, @last_error, @retry_count)
+INSERT INTO outbound_messages (campaign_id, customer_id, status, rendered_content, last_error, retry_count, encoding, segments, resend_reason)
+VALUES (@campaign_id, @customer_id, @status, @rendered_content, @last_error, @retry_count, @encoding, @segments, @resend_reason)
+ON CONFLICT (campaign_id, customer_id) WHERE resend_reason IS NULL AND status <> 'cancelled' DO NOTHING
 RETURNING *;
+
+-- name: DeferOutboundMessage :one
+-- A message held back by quiet hours moves to the task that delivers it once
+-- they end. It must still be pending and expect the task that defers it.
+UPDATE outbound_messages
+SET
+    task_id = @new_task_id,
+    updated_at = NOW()
+WHERE id = @message_id
+    AND status = 'pending'
+    AND (sqlc.narg(task_id)::text IS NULL OR task_id IS NULL OR task_id = sqlc.narg(task_id))
+RETURNING id;
+
+-- name: GetOutboundMessageDetail :one
+SELECT
+    om.*,
+    c.name AS campaign_name,
+    c.channel,
+    cu.phone,
+    cu.first_name,
+    cu.last_name
+FROM outbound_messages om
+JOIN campaigns c ON c.id = om.campaign_id
+JOIN customers cu ON cu.id = om.customer_id
+WHERE om.id = @message_id;
+
+-- name: GetOutboundMessageForDelivery :one
+SELECT
+    om.*,
+    c.channel,
+    c.status AS campaign_status,
+    c.timezone,
+    cu.phone
+FROM outbound_messages om
+JOIN campaigns c ON c.id = om.campaign_id
+JOIN customers cu ON cu.id = om.customer_id
+WHERE om.id = @message_id;
+
+-- name: GetOutboundMessage :one
+SELECT * FROM outbound_messages WHERE id = @message_id;
+
+-- name: GetOutboundMessageByProviderID :one
+SELECT om.*
+FROM outbound_messages om
+JOIN campaigns c ON c.id = om.campaign_id
+WHERE om.provider_message_id = @provider_message_id
+    AND c.channel = @channel
+ORDER BY om.id DESC
+LIMIT 1;
+
+-- name: ListCampaignMessages :many
+SELECT
+    om.id,
+    om.customer_id,
+    cu.phone,
+    cu.first_name,
+    cu.last_name,
+    om.status,
+    om.last_error,
+    om.retry_count,
+    om.encoding,
+    om.segments,
+    om.resend_reason,
+    om.provider_message_id,
+    om.created_at,
+    om.updated_at
+FROM outbound_messages om
+JOIN customers cu ON cu.id = om.customer_id
+WHERE
+    om.campaign_id = @campaign_id
+    AND om.id > @after_id
+    AND (
+        cardinality(@statuses::text[]) = 0
+        OR om.status = ANY(@statuses::text[])
+    )
+    AND (sqlc.narg(customer_id)::bigint IS NULL OR om.customer_id = sqlc.narg(customer_id))
+    AND (sqlc.narg(created_after)::timestamp IS NULL OR om.created_at >= sqlc.narg(created_after))
+    AND (sqlc.narg(created_before)::timestamp IS NULL OR om.created_at < sqlc.narg(created_before))
+    AND (sqlc.narg(error)::text IS NULL OR strpos(LOWER(om.last_error), LOWER(sqlc.narg(error))) > 0)
+ORDER BY om.id
+LIMIT @page_size;
+
+-- name: ListMessagedCustomerIds :many
+-- The customers among customer_ids who already have a message for the campaign
+-- that a send without resend would not repeat.
+SELECT DISTINCT customer_id
+FROM outbound_messages
+WHERE campaign_id = @campaign_id
+    AND customer_id = ANY(@customer_ids::bigint[])
+    AND resend_reason IS NULL
+    AND status <> 'cancelled';
+
+-- name: ListPendingCampaignMessages :many
+SELECT id, customer_id, task_id
+FROM outbound_messages
+WHERE campaign_id = @campaign_id
+    AND status = 'pending'
+    AND id > @after_id
+ORDER BY id
+LIMIT @batch_size
+FOR UPDATE;
+
+-- name: ListPendingCampaignTaskIds :many
+SELECT task_id::text
+FROM outbound_messages
+WHERE campaign_id = @campaign_id
+    AND status = 'pending'
+    AND task_id IS NOT NULL;
+
+-- name: SetOutboundMessageTaskIds :exec
+UPDATE outbound_messages om
+SET
+    task_id = t.task_id,
+    updated_at = NOW()
+FROM unnest(@message_ids::bigint[], @task_ids::text[]) AS t(message_id, task_id)
+WHERE om.id = t.message_id;
+
+-- name: TransitionOutboundMessage :one
+-- A message is claimed again from sending or failed, or put back from sending
+-- to pending, only by the task that holds it. A claim from failed is a retry.
+UPDATE outbound_messages
+SET
+    status = @to_status,
+    last_error = COALESCE(sqlc.narg(last_error)::text, last_error),
+    provider_message_id = COALESCE(sqlc.narg(provider_message_id)::text, provider_message_id),
+    retry_count = retry_count + CASE WHEN @to_status = 'pending' OR status = 'failed' THEN 1 ELSE 0 END,
+    updated_at = NOW()
+WHERE id = @message_id
+    AND status = ANY(@from_statuses::text[])
+    AND (sqlc.narg(task_id)::text IS NULL OR task_id IS NULL OR task_id = sqlc.narg(task_id))
+    AND (@to_status <> 'sending' OR status = 'pending' OR task_id = sqlc.narg(task_id))
+    AND (@to_status <> 'pending' OR status = 'failed' OR task_id = sqlc.narg(task_id))
+RETURNING *;
+
+-- name: UpdateOutboundMessageContents :exec
+UPDATE outbound_messages om
+SET
+    rendered_content = t.rendered_content,
+    encoding = NULLIF(t.encoding, ''),
+    segments = NULLIF(t.segments, 0),
+    updated_at = NOW()
+FROM unnest(
+    @message_ids::bigint[],
+    @rendered_contents::text[],
+    @encodings::text[],
+    @segments::int[]
+) AS t(message_id, rendered_content, encoding, segments)
+WHERE om.id = t.message_id
+    AND om.status = 'pending';
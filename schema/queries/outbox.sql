@@ -0,0 +1,25 @@
+-- name: CreateOutboxEntry :one
+INSERT INTO outbox (task_type, queue, payload, process_at, max_retry)
+VALUES (@task_type, @queue, @payload, @process_at, @max_retry)
+RETURNING *;
+
+-- name: ClaimOutboxEntries :many
+SELECT * FROM outbox
+WHERE dispatched_at IS NULL
+ORDER BY id
+LIMIT @batch_size
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxDispatched :exec
+UPDATE outbox
+SET
+    attempts = attempts + 1,
+    dispatched_at = NOW()
+WHERE id = @entry_id;
+
+-- name: RecordOutboxFailure :exec
+UPDATE outbox
+SET
+    attempts = attempts + 1,
+    last_error = @last_error
+WHERE id = @entry_id;
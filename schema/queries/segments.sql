@@ -0,0 +1,70 @@
+-- name: CreateSegment :one
+INSERT INTO segments (name, location, preferred_product, phone_prefix, created_after, created_before)
+VALUES (@name, @location, @preferred_product, @phone_prefix, @created_after, @created_before)
+RETURNING *;
+
+-- name: ListSegments :many
+SELECT
+    s.*,
+    COUNT(*) OVER() AS total_count
+FROM segments s
+ORDER BY s.id DESC
+LIMIT @page_size
+OFFSET ((@page_number - 1) * @page_size);
+
+-- name: GetSegment :one
+SELECT * FROM segments WHERE id = @segment_id;
+
+-- name: UpdateSegment :one
+UPDATE segments
+SET
+    name = @name,
+    location = @location,
+    preferred_product = @preferred_product,
+    phone_prefix = @phone_prefix,
+    created_after = @created_after,
+    created_before = @created_before,
+    updated_at = NOW()
+WHERE id = @segment_id
+RETURNING *;
+
+-- name: DeleteSegment :execrows
+DELETE FROM segments WHERE id = @segment_id;
+
+-- name: CountSegmentCustomers :one
+SELECT COUNT(cu.id)
+FROM customers cu
+JOIN segments s ON s.id = @segment_id
+WHERE
+    (s.location IS NULL OR LOWER(cu.location) = LOWER(s.location))
+    AND (s.preferred_product IS NULL OR LOWER(cu.preferred_product) = LOWER(s.preferred_product))
+    AND (s.phone_prefix IS NULL OR starts_with(cu.phone, s.phone_prefix))
+    AND (s.created_after IS NULL OR cu.created_at >= s.created_after)
+    AND (s.created_before IS NULL OR cu.created_at < s.created_before);
+
+-- name: ListSegmentCustomers :many
+SELECT cu.*
+FROM customers cu
+JOIN segments s ON s.id = @segment_id
+WHERE
+    cu.id > @after_id
+    AND (s.location IS NULL OR LOWER(cu.location) = LOWER(s.location))
+    AND (s.preferred_product IS NULL OR LOWER(cu.preferred_product) = LOWER(s.preferred_product))
+    AND (s.phone_prefix IS NULL OR starts_with(cu.phone, s.phone_prefix))
+    AND (s.created_after IS NULL OR cu.created_at >= s.created_after)
+    AND (s.created_before IS NULL OR cu.created_at < s.created_before)
+ORDER BY cu.id
+LIMIT @batch_size;
+
+-- name: SampleSegmentCustomers :many
+SELECT cu.*
+FROM customers cu
+JOIN segments s ON s.id = @segment_id
+WHERE
+    (s.location IS NULL OR LOWER(cu.location) = LOWER(s.location))
+    AND (s.preferred_product IS NULL OR LOWER(cu.preferred_product) = LOWER(s.preferred_product))
+    AND (s.phone_prefix IS NULL OR starts_with(cu.phone, s.phone_prefix))
+    AND (s.created_after IS NULL OR cu.created_at >= s.created_after)
+    AND (s.created_before IS NULL OR cu.created_at < s.created_before)
+ORDER BY random()
+LIMIT @sample_size;